@@ -3,10 +3,14 @@ package api
 import (
 	"cronspy/backend/pkg/api/job"
 	jt "cronspy/backend/pkg/api/job/transport"
+	"cronspy/backend/pkg/api/notification"
+	nt "cronspy/backend/pkg/api/notification/transport"
 	"cronspy/backend/pkg/api/user"
 	ut "cronspy/backend/pkg/api/user/transport"
 	"cronspy/backend/pkg/util/config"
 	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/mail"
+	"cronspy/backend/pkg/util/model"
 	"cronspy/backend/pkg/util/server"
 	"fmt"
 	"time"
@@ -33,11 +37,74 @@ func Start(cfg *config.Configuration) (err error) {
 	// http server
 	e := server.New(cfg.Server.Debug)
 
+	// the client IP is only read from the forwarding headers of trusted proxies
+	realIP, err := server.RealIP(cfg.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	e.Pre(realIP)
+
 	// +++++++++++ SERVICES ++++++++++++
 	//
 
-	ut.NewHTTP(user.Initialize(ds, nil, logger, cfg.Server.TokenExpiration), jwtSigningKey, jwtSigningMethod, e)
-	jt.NewHTTP(job.Initialize(ds, nil, logger), jwtSigningKey, jwtSigningMethod, e)
+	// mail is optional; without it, email channels and password resets don't send emails
+	var mailer mail.Mailer
+	if cfg.Mail.Host != "" {
+		mailer = mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			TLS:      cfg.Mail.TLS,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+			Timeout:  time.Duration(cfg.Mail.Timeout) * time.Second,
+		})
+	}
+
+	notificationService := notification.Initialize(ds, nil, logger, cfg.Web.BaseURL)
+	notificationService.SetDeliveryTimeout(time.Duration(cfg.Notification.Timeout) * time.Second)
+	notificationService.SetMaxAttempts(cfg.Notification.MaxAttempts)
+	notificationService.RegisterNotifier(model.ChannelTypeSlack, notification.NewSlackNotifier(nil))
+	notificationService.RegisterNotifier(model.ChannelTypeWebHook, notification.NewWebHookNotifier(nil))
+	if mailer != nil {
+		notificationService.RegisterNotifier(model.ChannelTypeEmail, notification.NewEmailNotifier(mailer))
+	}
+
+	dispatcher := notification.NewDispatcher(notificationService, cfg.Notification.Workers,
+		time.Duration(cfg.Notification.PollInterval)*time.Second)
+
+	jobService := job.Initialize(ds, nil, logger, dispatcher)
+	jobService.SetMaxPingBodySize(cfg.Monitor.MaxPingBodySize)
+
+	ut.NewHTTP(user.Initialize(ds, nil, logger, cfg.Server.TokenExpiration, mailer, cfg.Web.BaseURL), jwtSigningKey, jwtSigningMethod, e)
+	jt.NewHTTP(jobService, jwtSigningKey, jwtSigningMethod, e)
+	nt.NewHTTP(notificationService, jwtSigningKey, jwtSigningMethod, e)
+
+	//
+	// +++++++++++++++++++++++++++++++++
+
+	// +++++++++++ WORKERS +++++++++++++
+	//
+
+	evaluator := job.NewEvaluator(jobService, time.Duration(cfg.Monitor.EvaluationInterval)*time.Second)
+	workers := []server.Worker{evaluator, dispatcher}
+
+	// inbound mail is optional; without it, jobs can only be pinged over HTTP
+	if cfg.InboundMail.Address != "" {
+		mailListener, errMail := jt.NewSMTP(jobService, jt.SMTPConfig{
+			Address:        cfg.InboundMail.Address,
+			Domain:         cfg.InboundMail.Domain,
+			FailKeywords:   cfg.InboundMail.FailKeywords,
+			MaxMailSize:    cfg.InboundMail.MaxSize,
+			MaxSessions:    cfg.InboundMail.MaxSessions,
+			Timeout:        time.Duration(cfg.InboundMail.Timeout) * time.Second,
+			SessionTimeout: time.Duration(cfg.InboundMail.SessionTimeout) * time.Second,
+		}, logger)
+		if errMail != nil {
+			return errMail
+		}
+		workers = append(workers, mailListener)
+	}
 
 	//
 	// +++++++++++++++++++++++++++++++++
@@ -50,6 +117,7 @@ func Start(cfg *config.Configuration) (err error) {
 			ReadTimeoutSeconds:  cfg.Server.ReadTimeout,
 			WriteTimeoutSeconds: cfg.Server.WriteTimeout,
 			Debug:               cfg.Server.Debug,
+			Workers:             workers,
 		},
 		logger)
 
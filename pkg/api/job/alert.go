@@ -0,0 +1,215 @@
+package job
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetJobAlerts returns the alerts configured for a job of the user
+func (j *Job) GetJobAlerts(idJob string, idUser int) (alerts []model.JobAlert, err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	alerts, err = j.database.GetJobAlerts(idJob)
+	if err != nil {
+		j.logger.Error("error loading job alerts", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+
+	return
+}
+
+// SaveJobAlert adds an alert to a job of the user; the channel must
+// belong to the same user
+func (j *Job) SaveJobAlert(idJob string, idUser int, alert *model.JobAlert) (err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	if err = j.checkAlertChannel(alert.IDChannel, idUser); err != nil {
+		return
+	}
+
+	alert.ID = 0
+	alert.IDJob = idJob
+	alert.DateLastNotified = nil
+
+	if errSave := j.database.SaveJobAlert(alert); errSave != nil {
+		j.logger.Error("error saving job alert", errSave, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errSave.Error()))
+	}
+
+	return
+}
+
+// UpdateJobAlert changes the configuration of an alert of a job of the user
+func (j *Job) UpdateJobAlert(idJob string, idAlert, idUser int, alert *model.JobAlert) (err error) {
+
+	a, err := j.getUserJobAlert(idJob, idAlert, idUser)
+	if err != nil {
+		return
+	}
+
+	if err = j.checkAlertChannel(alert.IDChannel, idUser); err != nil {
+		return
+	}
+
+	a.Target = alert.Target
+	a.MinutesBeforeNotification = alert.MinutesBeforeNotification
+	a.IDChannel = alert.IDChannel
+
+	if errUpdate := j.database.UpdateJobAlert(&a); errUpdate != nil {
+		j.logger.Error("error updating job alert", errUpdate, map[string]interface{}{"id_job": idJob, "id_alert": idAlert})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+		return
+	}
+
+	*alert = a
+	return
+}
+
+// DeleteJobAlert removes an alert of a job of the user
+func (j *Job) DeleteJobAlert(idJob string, idAlert, idUser int) (err error) {
+
+	if _, err = j.getUserJobAlert(idJob, idAlert, idUser); err != nil {
+		return
+	}
+
+	if errDelete := j.database.DeleteJobAlert(idAlert); errDelete != nil {
+		j.logger.Error("error deleting job alert", errDelete, map[string]interface{}{"id_job": idJob, "id_alert": idAlert})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errDelete.Error()))
+	}
+
+	return
+}
+
+// returns an alert of a job, checking that the user is the owner of the job
+func (j *Job) getUserJobAlert(idJob string, idAlert, idUser int) (alert model.JobAlert, err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	alert, err = j.database.GetJobAlert(idAlert)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			j.logger.Error("error loading job alert", err, map[string]interface{}{"id_job": idJob, "id_alert": idAlert})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// the alert must belong to the job in the path
+	if alert.IDJob != idJob {
+		err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+	}
+
+	return
+}
+
+// checks that the channel of an alert exists and belongs to the user
+func (j *Job) checkAlertChannel(idChannel, idUser int) (err error) {
+
+	c, err := j.database.GetChannel(idChannel, false)
+	if err != nil && err != exception.ErrRecordNotFound {
+		j.logger.Error("error loading channel", err, map[string]interface{}{"id_channel": idChannel, "id_user": idUser})
+		return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+
+	// channels of other users are reported as unknown
+	if err == exception.ErrRecordNotFound || c.IDUser != idUser {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, "id_channel: unknown channel", "id_channel"))
+	}
+
+	return nil
+}
+
+// notifies the alerts of a job that went down; every alert is notified only
+// once until the job recovers, and only after its configured minutes
+// have passed (`overdue` is ignored when nil, e.g. for failures). `ping` is
+// the ping that caused the event, if any.
+func (j *Job) notifyJobDown(job *model.Job, eventType string, expected *time.Time, overdue *time.Duration, ping *model.JobPing) {
+
+	alerts, err := j.database.GetJobAlerts(job.ID)
+	if err != nil {
+		j.logger.Error("error loading job alerts", err, map[string]interface{}{"id_job": job.ID})
+		return
+	}
+
+	now := time.Now()
+	for _, a := range alerts {
+		if a.DateLastNotified != nil {
+			continue
+		}
+
+		if overdue != nil && *overdue < alertGracePeriod(a) {
+			continue
+		}
+
+		updated, err := j.database.MarkJobAlertNotified(a.ID, now)
+		if err != nil {
+			j.logger.Error("error marking job alert as notified", err, map[string]interface{}{"id_job": job.ID, "id_alert": a.ID})
+			continue
+		}
+
+		// another process could have notified it
+		if updated {
+			j.emitAlert(model.AlertEvent{Type: eventType, DateCreated: now, Job: *job, Alert: a, DateExpected: expected, Ping: ping})
+		}
+	}
+}
+
+// notifies the alerts of a job that recovered, if they were notified
+// when the job went down
+func (j *Job) notifyJobRecovered(job *model.Job, ping *model.JobPing) {
+
+	alerts, err := j.database.GetJobAlerts(job.ID)
+	if err != nil {
+		j.logger.Error("error loading job alerts", err, map[string]interface{}{"id_job": job.ID})
+		return
+	}
+
+	now := time.Now()
+	for _, a := range alerts {
+		if a.DateLastNotified == nil {
+			continue
+		}
+
+		updated, err := j.database.ClearJobAlertNotified(a.ID)
+		if err != nil {
+			j.logger.Error("error clearing job alert notification", err, map[string]interface{}{"id_job": job.ID, "id_alert": a.ID})
+			continue
+		}
+
+		if updated {
+			j.emitAlert(model.AlertEvent{Type: model.AlertEventJobRecovered, DateCreated: now, Job: *job, Alert: a, Ping: ping})
+		}
+	}
+}
+
+// sends the event to the configured alert handler
+func (j *Job) emitAlert(e model.AlertEvent) {
+	j.logger.Info("job alert event", map[string]interface{}{"type": e.Type, "id_job": e.Job.ID, "id_alert": e.Alert.ID})
+
+	if j.alerts != nil {
+		j.alerts.HandleAlert(e)
+	}
+}
+
+// returns the time to wait before notifying an alert
+func alertGracePeriod(a model.JobAlert) time.Duration {
+	minutes := a.MinutesBeforeNotification
+	if minutes < DefaultGraceMinutes {
+		minutes = DefaultGraceMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
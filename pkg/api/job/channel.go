@@ -3,13 +3,30 @@ package job
 import (
 	"cronspy/backend/pkg/util/exception"
 	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/webhook"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
-// SaveChannel saves a channel in the database
-func (j *Job) SaveChannel(c *model.Channel) (err error) {
+// SaveChannel saves a new channel of the user in the database
+func (j *Job) SaveChannel(idUser int, c *model.Channel) (err error) {
+
+	// an ID sent by the client would overwrite an existing channel
+	c.ID = 0
+	c.IDUser = idUser
+
+	// web hooks are signed with a secret generated by us
+	if c.Type == model.ChannelTypeWebHook {
+		if c.Configuration != nil {
+			delete(c.Configuration, "signing_secret")
+		}
+		if _, err = setChannelSigningSecret(c); err != nil {
+			j.logger.Error("error generating channel signing secret", err, nil)
+			return
+		}
+	}
+
 	err = j.database.SaveChannel(c)
 	if err != nil {
 		j.logger.Error("error saving channel", err, nil)
@@ -75,10 +92,20 @@ func (j *Job) UpdateChannel(idChannel, idUser int, channel *model.Channel) (err
 		return
 	}
 
-	// update channel
+	// update channel; the signing secret can only be changed by rotating it
+	secret := c.GetChannelWebHook().SigningSecret
 	c.Name = channel.Name
 	c.Configuration = channel.Configuration
 
+	if c.Type == model.ChannelTypeWebHook {
+		if c.Configuration != nil {
+			delete(c.Configuration, "signing_secret")
+		}
+		cwh := c.GetChannelWebHook()
+		cwh.SigningSecret = secret
+		c.SetChannelWebHook(cwh)
+	}
+
 	if errUpdate := j.database.UpdateChannel(&c); errUpdate != nil {
 		j.logger.Error("error updating channel data", errUpdate, map[string]interface{}{"id_channel": idChannel})
 		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
@@ -98,3 +125,57 @@ func (j *Job) GetChannels(idUser int) (channels []model.Channel, err error) {
 
 	return
 }
+
+// RotateChannelSecret replaces the signing secret of a web hook channel; the
+// previous secret stops working right away
+func (j *Job) RotateChannelSecret(idChannel, idUser int) (secret string, err error) {
+
+	// get channel
+	c, err := j.database.GetChannel(idChannel, true)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			j.logger.Error("error loading channel", err, map[string]interface{}{"id_channel": idChannel, "id_user": idUser})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if c.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	if c.Type != model.ChannelTypeWebHook {
+		err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidChannelType, ""))
+		return
+	}
+
+	secret, err = setChannelSigningSecret(&c)
+	if err != nil {
+		j.logger.Error("error generating channel signing secret", err, map[string]interface{}{"id_channel": idChannel})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	if errUpdate := j.database.UpdateChannelSigningSecret(idChannel, secret); errUpdate != nil {
+		j.logger.Error("error updating channel signing secret", errUpdate, map[string]interface{}{"id_channel": idChannel})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+	}
+
+	return
+}
+
+// sets a new signing secret in the configuration of a web hook channel
+func setChannelSigningSecret(c *model.Channel) (secret string, err error) {
+	if secret, err = webhook.GenerateSecret(); err != nil {
+		return
+	}
+
+	cwh := c.GetChannelWebHook()
+	cwh.SigningSecret = &secret
+	c.SetChannelWebHook(cwh)
+	return
+}
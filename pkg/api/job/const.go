@@ -1 +1,24 @@
 package job
+
+import "time"
+
+// MaxUserAgentLength is the max number of characters stored for the user agent of a ping
+const MaxUserAgentLength = 255
+
+// DefaultMaxPingBodySize is the default max number of bytes stored for the body of a ping
+const DefaultMaxPingBodySize = 10 * 1024
+
+// DefaultGraceMinutes is the min number of minutes a job can be late before it's marked as ERROR
+const DefaultGraceMinutes = 1
+
+// DefaultEvaluationInterval is the default time between job evaluations
+const DefaultEvaluationInterval = time.Minute
+
+// AutoIntervalWindow is the number of recent pings used to detect the interval of AUTO jobs
+const AutoIntervalWindow = 12
+
+// AutoIntervalMinSamples is the min number of intervals needed before AUTO jobs are evaluated
+const AutoIntervalMinSamples = 5
+
+// AutoIntervalTolerance is the fraction of the detected interval an AUTO job can be late
+const AutoIntervalTolerance = 0.2
@@ -0,0 +1,177 @@
+package job
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"time"
+)
+
+// Evaluator periodically compares the active jobs against their expected
+// schedule, moving late jobs to ERROR and notifying their alerts
+type Evaluator struct {
+	svc      *Job
+	interval time.Duration
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewEvaluator creates a new evaluator that runs every `interval`
+func NewEvaluator(svc *Job, interval time.Duration) *Evaluator {
+	if interval <= 0 {
+		interval = DefaultEvaluationInterval
+	}
+
+	return &Evaluator{
+		svc:      svc,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts evaluating jobs in background
+func (e *Evaluator) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	go func() {
+		defer close(e.done)
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.svc.EvaluateJobs(ctx, now)
+			}
+		}
+	}()
+}
+
+// Stop stops the evaluator, waiting for the current evaluation to finish
+func (e *Evaluator) Stop(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+	e.cancel()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EvaluateJobs checks every active job against its expected schedule
+func (j *Job) EvaluateJobs(ctx context.Context, now time.Time) {
+
+	jobs, err := j.database.GetActiveJobs()
+	if err != nil {
+		j.logger.Error("error loading active jobs", err, nil)
+		return
+	}
+
+	for i := range jobs {
+		// stop early on shutdown
+		if ctx.Err() != nil {
+			return
+		}
+		j.evaluateJob(&jobs[i], now)
+	}
+}
+
+// checks if the job missed its last expected run and notifies its alerts
+func (j *Job) evaluateJob(job *model.Job, now time.Time) {
+
+	expected, ok := j.getExpectedRun(job, now)
+	if !ok {
+		return
+	}
+
+	// a ping after the expected run means the job is on time
+	if job.DateLastPing != nil && !job.DateLastPing.Before(expected) {
+		return
+	}
+
+	// alerts wait from the first run that was missed, not from the last one
+	overdue := now.Sub(j.getFirstMissedRun(job, expected))
+	if overdue < time.Duration(DefaultGraceMinutes)*time.Minute {
+		return
+	}
+
+	if job.Status != model.JobStatusError {
+		updated, err := j.database.MarkJobAsLate(job.ID, expected)
+		if err != nil {
+			j.logger.Error("error updating job status", err, map[string]interface{}{"id_job": job.ID})
+			return
+		}
+
+		// a ping could have been received in the meantime
+		if !updated {
+			return
+		}
+		job.Status = model.JobStatusError
+	}
+
+	j.notifyJobDown(job, model.AlertEventJobDown, &expected, &overdue, nil)
+}
+
+// returns the first run the job missed since its last ping or update;
+// `expected` is the last run it missed
+func (j *Job) getFirstMissedRun(job *model.Job, expected time.Time) time.Time {
+	if job.JobType != model.JobTypeCron {
+		return expected
+	}
+
+	from := job.DateUpdated
+	if job.DateLastPing != nil && job.DateLastPing.After(from) {
+		from = *job.DateLastPing
+	}
+
+	first, err := job.GetNextRunAfter(from)
+	if err != nil || first.After(expected) {
+		return expected
+	}
+	return first
+}
+
+// returns the last time the job was expected to run; `ok` is false if
+// no run was expected yet
+func (j *Job) getExpectedRun(job *model.Job, now time.Time) (expected time.Time, ok bool) {
+
+	switch job.JobType {
+
+	case model.JobTypeCron:
+		prev, err := job.GetPreviousRunBefore(now)
+		if err != nil {
+			j.logger.Error("error calculating previous job run", err, map[string]interface{}{"id_job": job.ID})
+			return
+		}
+
+		// runs scheduled before the job was created or updated are not expected
+		expected, ok = prev, !prev.Before(job.DateUpdated)
+
+	case model.JobTypeAuto:
+		// wait until the interval is detected; some tolerance is
+		// added since the interval is just an estimation
+		if job.DetectedIntervalMinutes == nil || job.DateLastPing == nil {
+			return
+		}
+
+		interval := time.Duration(*job.DetectedIntervalMinutes) * time.Minute
+		wait := interval + time.Duration(float64(interval)*AutoIntervalTolerance)
+		expected = job.DateLastPing.Add(wait)
+
+		// after an update, e.g. when a paused job is resumed, the job
+		// has a whole interval to check in again
+		if updated := job.DateUpdated.Add(wait); expected.Before(updated) {
+			expected = updated
+		}
+		ok = !expected.After(now)
+	}
+
+	return
+}
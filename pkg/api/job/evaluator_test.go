@@ -0,0 +1,378 @@
+package job
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ping key of the evaluated job
+const testPingKey = "8d3f4b1c2a9e4f6b8c7d5e3a1b2c4d6e"
+
+// ****************************************************
+//
+// DATABASE MOCK
+//
+// ****************************************************
+
+// evaluatorDBMock implements the methods used by the evaluator and the
+// ping registration; any other call panics
+type evaluatorDBMock struct {
+	DB
+
+	jobs   []model.Job
+	alerts []model.JobAlert
+}
+
+func (db *evaluatorDBMock) GetActiveJobs() (jobs []model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].Active {
+			jobs = append(jobs, db.jobs[i])
+		}
+	}
+	return
+}
+
+func (db *evaluatorDBMock) GetJobByID(id string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == id {
+			return db.jobs[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *evaluatorDBMock) GetJobByPingKey(pingKey string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].PingKey == pingKey {
+			return db.jobs[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *evaluatorDBMock) MarkJobAsLate(idJob string, expected time.Time) (updated bool, err error) {
+	for i := range db.jobs {
+		j := &db.jobs[i]
+		if j.ID == idJob && j.Status != model.JobStatusError && (j.DateLastPing == nil || j.DateLastPing.Before(expected)) {
+			j.Status = model.JobStatusError
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *evaluatorDBMock) SavePing(job *model.Job, ping *model.JobPing, run *model.JobRun) (err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == job.ID {
+			db.jobs[i].Status = job.Status
+			db.jobs[i].DateLastPing = job.DateLastPing
+		}
+	}
+	return
+}
+
+func (db *evaluatorDBMock) GetRunningJobRun(idJob string) (run model.JobRun, err error) {
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *evaluatorDBMock) GetJobAlerts(idJob string) (alerts []model.JobAlert, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].IDJob == idJob {
+			alerts = append(alerts, db.alerts[i])
+		}
+	}
+	return
+}
+
+func (db *evaluatorDBMock) MarkJobAlertNotified(idAlert int, date time.Time) (updated bool, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert && db.alerts[i].DateLastNotified == nil {
+			db.alerts[i].DateLastNotified = &date
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *evaluatorDBMock) ClearJobAlertNotified(idAlert int) (updated bool, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert && db.alerts[i].DateLastNotified != nil {
+			db.alerts[i].DateLastNotified = nil
+			updated = true
+		}
+	}
+	return
+}
+
+// alertRecorder keeps the emitted alert events
+type alertRecorder struct {
+	events []model.AlertEvent
+	mux    sync.Mutex
+}
+
+func (r *alertRecorder) HandleAlert(e model.AlertEvent) {
+	r.mux.Lock()
+	r.events = append(r.events, e)
+	r.mux.Unlock()
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// a job that must run every hour, created yesterday
+func getEvaluatorMock(lastPing *time.Time) (*evaluatorDBMock, *alertRecorder, *Job) {
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	db := &evaluatorDBMock{
+		jobs: []model.Job{{
+			ID:                     "job-1",
+			IDUser:                 1,
+			PingKey:                testPingKey,
+			JobType:                model.JobTypeCron,
+			Active:                 true,
+			Status:                 model.JobStatusOK,
+			CronExpression:         strPtr("0 * * * *"),
+			CronExpressionTimezone: strPtr("UTC"),
+			DateCreated:            yesterday,
+			DateUpdated:            yesterday,
+			DateLastPing:           lastPing,
+		}},
+		alerts: []model.JobAlert{
+			{ID: 1, IDJob: "job-1", MinutesBeforeNotification: 0, IDChannel: 1},
+			{ID: 2, IDJob: "job-1", MinutesBeforeNotification: 30, IDChannel: 2},
+		},
+	}
+
+	recorder := &alertRecorder{}
+	return db, recorder, new(db, log.New(), recorder)
+}
+
+func TestEvaluateLateJob(t *testing.T) {
+	// 10 minutes after the hour, with the last ping before it
+	now := time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	db, recorder, svc := getEvaluatorMock(timePtr(now.Add(-70 * time.Minute)))
+
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+	if assert.Len(t, recorder.events, 1) {
+		e := recorder.events[0]
+		assert.Equal(t, model.AlertEventJobDown, e.Type)
+		assert.Equal(t, 1, e.Alert.ID)
+		if assert.NotNil(t, e.DateExpected) {
+			assert.True(t, now.Add(-10*time.Minute).Equal(*e.DateExpected))
+		}
+	}
+
+	// the same alert is not notified twice
+	svc.EvaluateJobs(context.Background(), now.Add(time.Minute))
+	assert.Len(t, recorder.events, 1)
+
+	// the second alert is notified after its minutes
+	svc.EvaluateJobs(context.Background(), now.Add(25*time.Minute))
+	if assert.Len(t, recorder.events, 2) {
+		assert.Equal(t, 2, recorder.events[1].Alert.ID)
+	}
+}
+
+func TestEvaluateAlertLongerThanInterval(t *testing.T) {
+	// a job that runs every 5 minutes, with an alert after 10 minutes;
+	// the last ping was before the run at :15
+	start := time.Now().Truncate(time.Hour)
+	db, recorder, svc := getEvaluatorMock(timePtr(start.Add(14 * time.Minute)))
+	db.jobs[0].CronExpression = strPtr("*/5 * * * *")
+	db.alerts = []model.JobAlert{{ID: 1, IDJob: "job-1", MinutesBeforeNotification: 10, IDChannel: 1}}
+
+	// 7 minutes late
+	svc.EvaluateJobs(context.Background(), start.Add(22*time.Minute))
+	assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 0)
+
+	// 12 minutes late, although the last missed run was just 2 minutes ago
+	svc.EvaluateJobs(context.Background(), start.Add(27*time.Minute))
+	if assert.Len(t, recorder.events, 1) {
+		assert.Equal(t, 1, recorder.events[0].Alert.ID)
+	}
+}
+
+func TestEvaluateJobOnTime(t *testing.T) {
+	now := time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	db, recorder, svc := getEvaluatorMock(timePtr(now.Add(-9 * time.Minute)))
+
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 0)
+}
+
+func TestEvaluateWithinGracePeriod(t *testing.T) {
+	now := time.Now().Truncate(time.Hour).Add(30 * time.Second)
+	db, recorder, svc := getEvaluatorMock(timePtr(now.Add(-time.Hour)))
+
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 0)
+}
+
+func TestEvaluateJobUpdatedAfterExpectedRun(t *testing.T) {
+	now := time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	db, recorder, svc := getEvaluatorMock(nil)
+	db.jobs[0].DateUpdated = now.Add(-5 * time.Minute)
+
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 0)
+}
+
+func TestEvaluateInactiveJob(t *testing.T) {
+	now := time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	db, recorder, svc := getEvaluatorMock(nil)
+	db.jobs[0].Active = false
+
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 0)
+}
+
+func TestEvaluateAutoJob(t *testing.T) {
+	now := time.Now()
+	db, recorder, svc := getEvaluatorMock(timePtr(now.Add(-20 * time.Minute)))
+	db.jobs[0].JobType = model.JobTypeAuto
+	db.jobs[0].CronExpression = nil
+
+	// the interval is not detected yet
+	svc.EvaluateJobs(context.Background(), now)
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+
+	// every 15 minutes, so 5 minutes late
+	db.jobs[0].DetectedIntervalMinutes = intPtr(15)
+	svc.EvaluateJobs(context.Background(), now)
+
+	// assertions
+	assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 1)
+}
+
+func TestEvaluateResumedAutoJob(t *testing.T) {
+	now := time.Now()
+	db, recorder, svc := getEvaluatorMock(timePtr(now.Add(-3 * time.Hour)))
+	db.jobs[0].JobType = model.JobTypeAuto
+	db.jobs[0].CronExpression = nil
+	db.jobs[0].DetectedIntervalMinutes = intPtr(15)
+
+	// resumed 10 minutes ago, so the job still has time to check in
+	db.jobs[0].DateUpdated = now.Add(-10 * time.Minute)
+	svc.EvaluateJobs(context.Background(), now)
+	assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+
+	// once the interval passes, it's late
+	svc.EvaluateJobs(context.Background(), now.Add(10*time.Minute))
+	assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+	assert.Len(t, recorder.events, 1)
+}
+
+func TestPingPausedJob(t *testing.T) {
+	db, recorder, svc := getEvaluatorMock(nil)
+	db.jobs[0].Active = false
+
+	err := svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindFail})
+
+	// the ping is recorded, but alerts are not notified
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+		assert.Len(t, recorder.events, 0)
+	}
+}
+
+func TestPingNotifiesFailureAndRecovery(t *testing.T) {
+	db, recorder, svc := getEvaluatorMock(nil)
+
+	// a failure notifies every alert right away
+	err := svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindFail})
+	if assert.NoError(t, err) && assert.Len(t, recorder.events, 2) {
+		assert.Equal(t, model.AlertEventJobFailed, recorder.events[0].Type)
+		assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+	}
+
+	// a new failure doesn't notify again
+	err = svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindFail})
+	if assert.NoError(t, err) {
+		assert.Len(t, recorder.events, 2)
+	}
+
+	// a success notifies the recovery
+	err = svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindSuccess})
+	if assert.NoError(t, err) && assert.Len(t, recorder.events, 4) {
+		assert.Equal(t, model.AlertEventJobRecovered, recorder.events[2].Type)
+		assert.Equal(t, model.AlertEventJobRecovered, recorder.events[3].Type)
+		assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	}
+}
+
+func TestPingExitCodeAndOutput(t *testing.T) {
+	db, recorder, svc := getEvaluatorMock(nil)
+	svc.SetMaxPingBodySize(5)
+
+	// a success with a non-zero exit code is a failure; the output is
+	// truncated without splitting characters
+	exitCode := 3
+	body := "abcdé"
+	err := svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindSuccess, ExitCode: &exitCode, Body: &body})
+	if assert.NoError(t, err) && assert.Len(t, recorder.events, 2) {
+		assert.Equal(t, model.JobStatusError, db.jobs[0].Status)
+
+		e := recorder.events[0]
+		assert.Equal(t, model.AlertEventJobFailed, e.Type)
+		if assert.NotNil(t, e.Ping) {
+			assert.Equal(t, model.PingKindFail, e.Ping.Kind)
+			assert.Equal(t, "abcd", *e.Ping.Body)
+		}
+	}
+
+	// a zero exit code is a success
+	exitCode = 0
+	err = svc.RegisterPingByKey(testPingKey, &model.JobPing{Kind: model.PingKindSuccess, ExitCode: &exitCode})
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.JobStatusOK, db.jobs[0].Status)
+	}
+}
+
+func TestEvaluatorStop(t *testing.T) {
+	_, _, svc := getEvaluatorMock(nil)
+
+	e := NewEvaluator(svc, time.Millisecond)
+	e.Start()
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, e.Stop(ctx))
+}
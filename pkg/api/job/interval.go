@@ -0,0 +1,62 @@
+package job
+
+import (
+	"cronspy/backend/pkg/util/model"
+	"math"
+	"sort"
+	"time"
+)
+
+// DetectInterval returns the typical interval, in minutes, between the provided
+// ping dates; the median is used so that a few late or extra pings don't affect
+// the result. `ok` is false when there are not enough samples.
+func DetectInterval(dates []time.Time) (minutes int, ok bool) {
+	if len(dates) < AutoIntervalMinSamples+1 {
+		return
+	}
+
+	sorted := make([]time.Time, len(dates))
+	copy(sorted, dates)
+	sort.Slice(sorted, func(i, k int) bool { return sorted[i].Before(sorted[k]) })
+
+	intervals := make([]float64, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		intervals = append(intervals, sorted[i].Sub(sorted[i-1]).Minutes())
+	}
+	sort.Float64s(intervals)
+
+	median := intervals[len(intervals)/2]
+	if len(intervals)%2 == 0 {
+		median = (intervals[len(intervals)/2-1] + median) / 2
+	}
+
+	minutes = int(math.Round(median))
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	return minutes, true
+}
+
+// learns the interval of an AUTO job from its latest pings, so that
+// it can be evaluated as a CRON job would be
+func (j *Job) updateDetectedInterval(job *model.Job) {
+
+	dates, err := j.database.GetJobPingDates(job.ID, []string{model.PingKindSuccess, model.PingKindFail}, AutoIntervalWindow)
+	if err != nil {
+		j.logger.Error("error loading job ping dates", err, map[string]interface{}{"id_job": job.ID})
+		return
+	}
+
+	minutes, ok := DetectInterval(dates)
+	if !ok || (job.DetectedIntervalMinutes != nil && *job.DetectedIntervalMinutes == minutes) {
+		return
+	}
+
+	if err = j.database.UpdateJobDetectedInterval(job.ID, minutes); err != nil {
+		j.logger.Error("error updating job detected interval", err, map[string]interface{}{"id_job": job.ID})
+		return
+	}
+
+	job.DetectedIntervalMinutes = &minutes
+}
@@ -0,0 +1,53 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// builds ping dates from the minutes elapsed between them
+func pingDates(intervals ...float64) (dates []time.Time) {
+	t := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates = append(dates, t)
+	for _, m := range intervals {
+		t = t.Add(time.Duration(m * float64(time.Minute)))
+		dates = append(dates, t)
+	}
+	return
+}
+
+func TestDetectInterval(t *testing.T) {
+	cases := []struct {
+		name    string
+		dates   []time.Time
+		want    int
+		wantOK  bool
+		reverse bool
+	}{
+		{name: "Not enough samples", dates: pingDates(5, 5, 5, 5)},
+		{name: "Regular", dates: pingDates(5, 5, 5, 5, 5), want: 5, wantOK: true},
+		{name: "Newest first", dates: pingDates(60, 60, 60, 60, 60), want: 60, wantOK: true, reverse: true},
+		{name: "Jitter", dates: pingDates(14.5, 15.2, 15, 14.8, 15.6, 15.1), want: 15, wantOK: true},
+		{name: "Outliers", dates: pingDates(10, 10, 240, 10, 0.1, 10, 10), want: 10, wantOK: true},
+		{name: "Even number of samples", dates: pingDates(10, 10, 20, 20, 20, 10), want: 15, wantOK: true},
+		{name: "Sub-minute", dates: pingDates(0.2, 0.2, 0.2, 0.2, 0.2), want: 1, wantOK: true},
+		{name: "Cadence change", dates: pingDates(60, 60, 60, 60, 60, 30, 30, 30, 30, 30, 30, 30), want: 30, wantOK: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dates := tt.dates
+			if tt.reverse {
+				for i, k := 0, len(dates)-1; i < k; i, k = i+1, k-1 {
+					dates[i], dates[k] = dates[k], dates[i]
+				}
+			}
+
+			minutes, ok := DetectInterval(dates)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, minutes)
+		})
+	}
+}
@@ -3,14 +3,16 @@ package job
 import (
 	"cronspy/backend/pkg/util/exception"
 	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/pingkey"
+	"cronspy/backend/pkg/webhook"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
-// GetJobs returns the list of configured monitors for a user
-func (j *Job) GetJobs(idUser int, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
-	jobs, p, err = j.database.GetJobs(idUser, pageSize, page)
+// GetJobs returns the list of configured monitors for a user that match the filter
+func (j *Job) GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
+	jobs, p, err = j.database.GetJobs(idUser, filter, pageSize, page)
 	if err != nil {
 		j.logger.Error("error loading user jobs", err, map[string]interface{}{"id_user": idUser})
 		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
@@ -20,28 +22,294 @@ func (j *Job) GetJobs(idUser int, pageSize, page int) (jobs []model.Job, p model
 	return
 }
 
-// GetJob return a job data by the ID
-func (j *Job) GetJob(id string) (job model.Job, err error) {
-	job, err = j.database.GetJobByID(id)
+// GetJobsAfter returns the jobs of a user that match the filter, starting
+// after the `after` cursor; `next` is nil when there are no more jobs
+func (j *Job) GetJobsAfter(idUser int, filter model.JobFilter, after *model.JobCursor, pageSize int) (jobs []model.Job, next *model.JobCursor, err error) {
+	jobs, next, err = j.database.GetJobsAfter(idUser, filter, after, pageSize)
 	if err != nil {
-		if err == exception.ErrRecordNotFound {
-			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
-		} else {
-			j.logger.Error("error loading job", err, map[string]interface{}{"id_job": id})
-			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
-		}
+		j.logger.Error("error loading user jobs", err, map[string]interface{}{"id_user": idUser})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
 	}
 	return
 }
 
-// SaveJob saves a new job in the database
-func (j *Job) SaveJob(job *model.Job) (err error) {
+// GetJob return a job of the user by the ID, with its alerts
+func (j *Job) GetJob(idJob string, idUser int) (job model.Job, err error) {
+	if job, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
 
-	err = j.database.SaveJob(job)
+	job.Alerts, err = j.database.GetJobAlerts(idJob)
 	if err != nil {
+		j.logger.Error("error loading job alerts", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+	return
+}
+
+// SaveJob saves a new job of the user in the database
+func (j *Job) SaveJob(idUser int, job *model.Job) (err error) {
+
+	// an ID sent by the client would overwrite an existing job
+	job.ID = ""
+	job.IDUser = idUser
+
+	// an empty slug is the same as no slug
+	if job.Slug != nil && *job.Slug == "" {
+		job.Slug = nil
+	}
+
+	// new jobs have no pings yet
+	job.DateLastPing = nil
+	job.LastRunOutcome = nil
+	job.LastRunDurationMs = nil
+
+	if err = j.checkJobSlug(job); err != nil {
+		return
+	}
+
+	if job.PingKey, err = pingkey.Generate(); err != nil {
+		j.logger.Error("error generating job ping key", err, map[string]interface{}{"id_user": job.IDUser})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	// ping secrets sent by the client are ignored
+	signed := job.UsesSignedPings()
+	job.SignedPings = &signed
+	job.PingSecret = nil
+	job.RejectedPings = 0
+	if err = setJobPingSecret(job); err != nil {
+		j.logger.Error("error generating job ping secret", err, map[string]interface{}{"id_user": job.IDUser})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	err = j.database.SaveJob(job)
+	if err == exception.ErrDuplicateRecord {
+		// another job took the slug after it was checked
+		err = slugExistsError()
+	} else if err != nil {
 		j.logger.Error("error saving job", err, map[string]interface{}{"id_user": job.IDUser})
 		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
 	}
 
 	return
 }
+
+// UpdateJob changes the name, slug, schedule, labels and ping protections of a job of the user
+func (j *Job) UpdateJob(idJob string, idUser int, job *model.Job) (err error) {
+
+	current, err := j.getUserJob(idJob, idUser)
+	if err != nil {
+		return
+	}
+
+	current.Name = job.Name
+	current.JobType = job.JobType
+	current.CronExpression = job.CronExpression
+	current.CronExpressionTimezone = job.CronExpressionTimezone
+
+	// the slug, labels, allowed IPs and signed pings are kept when they are
+	// not sent; an empty value removes them
+	if job.Slug != nil {
+		current.Slug = job.Slug
+		if *job.Slug == "" {
+			current.Slug = nil
+		}
+	}
+	if job.SignedPings != nil {
+		current.SignedPings = job.SignedPings
+	}
+	if job.Labels != nil {
+		current.Labels = job.Labels
+	}
+	if job.AllowedIPs != nil {
+		current.AllowedIPs = job.AllowedIPs
+	}
+
+	if err = j.checkJobSlug(&current); err != nil {
+		return
+	}
+
+	if errSecret := setJobPingSecret(&current); errSecret != nil {
+		j.logger.Error("error generating job ping secret", errSecret, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errSecret.Error()))
+		return
+	}
+
+	if errUpdate := j.database.UpdateJob(&current); errUpdate == exception.ErrDuplicateRecord {
+		err = slugExistsError()
+		return
+	} else if errUpdate != nil {
+		j.logger.Error("error updating job", errUpdate, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+		return
+	}
+
+	*job = current
+	return
+}
+
+// DeleteJob removes a job of the user, along with its alerts and ping history
+func (j *Job) DeleteJob(idJob string, idUser int) (err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	if errDelete := j.database.DeleteJob(idJob); errDelete != nil {
+		j.logger.Error("error deleting job", errDelete, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errDelete.Error()))
+	}
+
+	return
+}
+
+// PauseJob stops evaluating a job of the user; pings are still recorded,
+// but its alerts are not notified
+func (j *Job) PauseJob(idJob string, idUser int) (job model.Job, err error) {
+	return j.setJobActive(idJob, idUser, false)
+}
+
+// ResumeJob starts evaluating a paused job of the user again
+func (j *Job) ResumeJob(idJob string, idUser int) (job model.Job, err error) {
+	return j.setJobActive(idJob, idUser, true)
+}
+
+// pauses or resumes a job of the user
+func (j *Job) setJobActive(idJob string, idUser int, active bool) (job model.Job, err error) {
+
+	job, err = j.getUserJob(idJob, idUser)
+	if err != nil {
+		return
+	}
+
+	// nothing to do
+	if job.Active == active {
+		return
+	}
+
+	if errUpdate := j.database.UpdateJobActive(&job, active); errUpdate != nil {
+		j.logger.Error("error updating job active flag", errUpdate, map[string]interface{}{"id_job": idJob, "active": active})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+	}
+
+	return
+}
+
+// RotatePingKey replaces the ping key of a job of the user; URLs with the
+// previous key stop working right away
+func (j *Job) RotatePingKey(idJob string, idUser int) (pingKey string, err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	if pingKey, err = pingkey.Generate(); err != nil {
+		j.logger.Error("error generating job ping key", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	if errUpdate := j.database.UpdateJobPingKey(idJob, pingKey); errUpdate != nil {
+		j.logger.Error("error updating job ping key", errUpdate, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+		pingKey = ""
+	}
+
+	return
+}
+
+// RotatePingSecret replaces the secret used to sign the pings of a job of
+// the user; signatures made with the previous secret stop working right away
+func (j *Job) RotatePingSecret(idJob string, idUser int) (secret string, err error) {
+
+	job, err := j.getUserJob(idJob, idUser)
+	if err != nil {
+		return
+	}
+
+	if !job.UsesSignedPings() {
+		err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, "signed_pings: the job doesn't use signed pings", "signed_pings"))
+		return
+	}
+
+	job.PingSecret = nil
+	if err = setJobPingSecret(&job); err != nil {
+		j.logger.Error("error generating job ping secret", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	if errUpdate := j.database.UpdateJobPingSecret(idJob, *job.PingSecret); errUpdate != nil {
+		j.logger.Error("error updating job ping secret", errUpdate, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errUpdate.Error()))
+		return
+	}
+
+	secret = *job.PingSecret
+	return
+}
+
+// jobs with signed pings keep their secret, or get a new one; it's
+// removed when signed pings are disabled
+func setJobPingSecret(job *model.Job) error {
+	if !job.UsesSignedPings() {
+		job.PingSecret = nil
+		return nil
+	}
+	if job.PingSecret != nil {
+		return nil
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	job.PingSecret = &secret
+	return nil
+}
+
+// checks that no other job of the user has the slug of the job
+func (j *Job) checkJobSlug(job *model.Job) error {
+	if job.Slug == nil {
+		return nil
+	}
+
+	exists, err := j.database.SlugExists(job.IDUser, *job.Slug, job.ID)
+	if err != nil {
+		j.logger.Error("error checking job slug", err, map[string]interface{}{"id_user": job.IDUser})
+		return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+
+	if exists {
+		return slugExistsError()
+	}
+	return nil
+}
+
+// returns the error of a slug used by another job of the user
+func slugExistsError() error {
+	return echo.NewHTTPError(http.StatusConflict, exception.GetErrorMapWithFields(exception.CodeSlugExists, "", "slug"))
+}
+
+// returns a job, checking that the user is the owner
+func (j *Job) getUserJob(idJob string, idUser int) (job model.Job, err error) {
+	job, err = j.database.GetJobByID(idJob)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			j.logger.Error("error loading job", err, map[string]interface{}{"id_job": idJob, "id_user": idUser})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if job.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+	}
+	return
+}
@@ -0,0 +1,222 @@
+package job
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/webhook"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterPingByKey records a check-in for a job, by its ping key
+func (j *Job) RegisterPingByKey(pingKey string, ping *model.JobPing) (err error) {
+	job, err := j.database.GetJobByPingKey(pingKey)
+	if err != nil {
+		return j.pingJobError(err)
+	}
+	return j.registerPing(job, ping)
+}
+
+// RegisterPingBySlug records a check-in for a job, by its slug and the ping key of its user
+func (j *Job) RegisterPingBySlug(userPingKey, slug string, ping *model.JobPing) (err error) {
+	job, err := j.database.GetJobBySlug(userPingKey, slug)
+	if err != nil {
+		return j.pingJobError(err)
+	}
+	return j.registerPing(job, ping)
+}
+
+// SignedPingContent returns the content signed by pings to jobs with signed
+// pings: the method, the URI with its query string, the ping kind and the
+// body, as in
+//
+//	POST /ping/<key>/fail?exit_code=1
+//	FAIL
+//	<body>
+//
+// so a signed ping can't be replayed to another URL or as another kind
+func SignedPingContent(method, uri, kind string, body []byte) []byte {
+	content := []byte(method + " " + uri + "\n" + kind + "\n")
+	return append(content, body...)
+}
+
+// returns why the ping isn't allowed by the protections of the job, if it
+// isn't; the signature covers the request as it was received
+func checkPingAllowed(job *model.Job, ping *model.JobPing) string {
+	if len(job.AllowedIPs) > 0 && !job.AllowedIPs.Contains(ping.SourceIP) {
+		return "source IP not allowed"
+	}
+
+	if job.UsesSignedPings() {
+		if job.PingSecret == nil {
+			return "missing ping secret"
+		}
+
+		var body []byte
+		if ping.Body != nil {
+			body = []byte(*ping.Body)
+		}
+		kind := ping.Kind
+		if kind == "" {
+			kind = model.PingKindSuccess
+		}
+		content := SignedPingContent(ping.Method, ping.URI, kind, body)
+		if err := webhook.Verify(*job.PingSecret, ping.Signature, content, webhook.DefaultTolerance); err != nil {
+			return err.Error()
+		}
+	}
+
+	return ""
+}
+
+// returns the error of a ping whose job couldn't be loaded; keys are not logged
+func (j *Job) pingJobError(err error) error {
+	if err == exception.ErrRecordNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+	}
+
+	j.logger.Error("error loading ping job", err, nil)
+	return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+}
+
+// records a check-in for a job; start pings open a new run, while success
+// and fail pings close the run they belong to and update the job status
+// accordingly. Success pings with a non-zero exit code are recorded as failures.
+//
+// Pings that don't pass the protections of the job are rejected; they are
+// only counted, so they never change the job status.
+func (j *Job) registerPing(job model.Job, ping *model.JobPing) (err error) {
+	idJob := job.ID
+
+	if reason := checkPingAllowed(&job, ping); reason != "" {
+		j.logger.Warn("ping rejected", map[string]interface{}{"id_job": idJob, "source_ip": ping.SourceIP, "reason": reason})
+		if errCount := j.database.IncrementRejectedPings(idJob); errCount != nil {
+			j.logger.Error("error counting rejected ping", errCount, map[string]interface{}{"id_job": idJob})
+		}
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	now := time.Now()
+	prevStatus := job.Status
+
+	ping.IDJob = job.ID
+	ping.DateCreated = now
+	if ping.Kind == "" {
+		ping.Kind = model.PingKindSuccess
+	}
+	if ping.Kind == model.PingKindSuccess && ping.ExitCode != nil && *ping.ExitCode != 0 {
+		ping.Kind = model.PingKindFail
+	}
+	if len(ping.UserAgent) > MaxUserAgentLength {
+		ping.UserAgent = ping.UserAgent[:MaxUserAgentLength]
+	}
+	if ping.Body != nil {
+		body := *ping.Body
+		if len(body) > j.maxPingBodySize {
+			body = body[:j.maxPingBodySize]
+		}
+		// the truncation could split a character
+		body = strings.ToValidUTF8(body, "")
+		ping.Body = &body
+	}
+
+	job.DateLastPing = &now
+
+	// build the run this ping belongs to
+	run, errRun := j.getPingRun(&job, ping)
+	if errRun != nil {
+		j.logger.Error("error loading job run", errRun, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errRun.Error()))
+		return
+	}
+
+	if errSave := j.database.SavePing(&job, ping, run); errSave != nil {
+		j.logger.Error("error saving ping", errSave, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errSave.Error()))
+		return
+	}
+
+	// AUTO jobs learn their interval from finished runs
+	if job.JobType == model.JobTypeAuto && ping.Kind != model.PingKindStart {
+		j.updateDetectedInterval(&job)
+	}
+
+	// paused jobs record their pings, but their alerts are not notified
+	if !job.Active {
+		return
+	}
+
+	// failures are notified right away; recoveries only if the job was down
+	switch {
+	case ping.Kind == model.PingKindFail:
+		j.notifyJobDown(&job, model.AlertEventJobFailed, nil, nil, ping)
+	case job.Status == model.JobStatusOK && prevStatus == model.JobStatusError:
+		j.notifyJobRecovered(&job, ping)
+	}
+
+	return
+}
+
+// GetJobPings returns the ping history of a job of the user, newest first
+func (j *Job) GetJobPings(idJob string, idUser int, filter model.PingFilter, after *model.PingCursor, pageSize int) (pings []model.JobPing, next *model.PingCursor, err error) {
+
+	if _, err = j.getUserJob(idJob, idUser); err != nil {
+		return
+	}
+
+	pings, next, err = j.database.GetJobPings(idJob, filter, after, pageSize)
+	if err != nil {
+		j.logger.Error("error loading job pings", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+	return
+}
+
+// returns the run that must be saved along with the ping; a start ping creates a
+// new run, and a finish ping closes the last running one (or creates it, if the job
+// never reported the start). The job status and last run data are also updated.
+func (j *Job) getPingRun(job *model.Job, ping *model.JobPing) (run *model.JobRun, err error) {
+
+	if ping.Kind == model.PingKindStart {
+		run = &model.JobRun{
+			IDJob:       job.ID,
+			DateStarted: &ping.DateCreated,
+			Outcome:     model.RunOutcomeRunning,
+		}
+		return
+	}
+
+	r, err := j.database.GetRunningJobRun(job.ID)
+	if err != nil {
+		if err != exception.ErrRecordNotFound {
+			return
+		}
+		err = nil
+		r = model.JobRun{IDJob: job.ID}
+	}
+	run = &r
+
+	run.DateFinished = &ping.DateCreated
+	if run.DateStarted != nil {
+		duration := ping.DateCreated.Sub(*run.DateStarted).Milliseconds()
+		run.DurationMs = &duration
+		ping.DurationMs = &duration
+	}
+
+	if ping.Kind == model.PingKindFail {
+		run.Outcome = model.RunOutcomeFail
+		job.Status = model.JobStatusError
+	} else {
+		run.Outcome = model.RunOutcomeSuccess
+		job.Status = model.JobStatusOK
+	}
+
+	job.LastRunOutcome = &run.Outcome
+	job.LastRunDurationMs = run.DurationMs
+
+	return
+}
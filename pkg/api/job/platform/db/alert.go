@@ -0,0 +1,67 @@
+package db
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// GetJobAlerts returns the alerts configured for a job
+func (j *JobDB) GetJobAlerts(idJob string) (alerts []model.JobAlert, err error) {
+	err = j.ds.Model(model.JobAlert{}).Where("id_job = ?", idJob).Order("id_alert asc").Find(&alerts).Error
+	return
+}
+
+// GetJobAlert returns an alert by ID
+func (j *JobDB) GetJobAlert(idAlert int) (alert model.JobAlert, err error) {
+	if err = j.ds.Model(model.JobAlert{}).Where("id_alert = ?", idAlert).First(&alert).Error; err == gorm.ErrRecordNotFound {
+		err = exception.ErrRecordNotFound
+	}
+	return
+}
+
+// SaveJobAlert creates an alert; the channel is not saved with it
+func (j *JobDB) SaveJobAlert(alert *model.JobAlert) (err error) {
+	err = j.ds.Set("gorm:save_associations", false).Create(alert).Error
+	return
+}
+
+// UpdateJobAlert saves the configuration of an existing alert
+func (j *JobDB) UpdateJobAlert(alert *model.JobAlert) (err error) {
+	err = j.ds.Model(model.JobAlert{}).Where("id_alert = ?", alert.ID).Updates(map[string]interface{}{
+		"target":                      alert.Target,
+		"minutes_before_notification": alert.MinutesBeforeNotification,
+		"id_channel":                  alert.IDChannel,
+	}).Error
+	return
+}
+
+// DeleteJobAlert removes an alert
+func (j *JobDB) DeleteJobAlert(idAlert int) (err error) {
+	err = j.ds.Where("id_alert = ?", idAlert).Delete(model.JobAlert{}).Error
+	return
+}
+
+// MarkJobAlertNotified sets the notification date of an alert, only if it was not
+// notified yet; `updated` is false when another process already notified it
+func (j *JobDB) MarkJobAlertNotified(idAlert int, date time.Time) (updated bool, err error) {
+	q := j.ds.Model(model.JobAlert{}).Where("id_alert = ? AND date_last_notified IS NULL", idAlert)
+	if q = q.Update("date_last_notified", date); q.Error == nil {
+		updated = q.RowsAffected > 0
+	}
+	err = q.Error
+	return
+}
+
+// ClearJobAlertNotified removes the notification date of an alert, so it can be
+// notified again; `updated` is false when the alert was not notified
+func (j *JobDB) ClearJobAlertNotified(idAlert int) (updated bool, err error) {
+	q := j.ds.Model(model.JobAlert{}).Where("id_alert = ? AND date_last_notified IS NOT NULL", idAlert)
+	if q = q.Update("date_last_notified", nil); q.Error == nil {
+		updated = q.RowsAffected > 0
+	}
+	err = q.Error
+	return
+}
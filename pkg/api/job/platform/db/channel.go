@@ -167,6 +167,12 @@ func (j *JobDB) UpdateChannel(channel *model.Channel) (err error) {
 	return
 }
 
+// UpdateChannelSigningSecret sets the signing secret of a web hook channel
+func (j *JobDB) UpdateChannelSigningSecret(idChannel int, secret string) (err error) {
+	err = j.ds.Model(&model.ChannelWebHook{}).Where("id_channel = ?", idChannel).Update("signing_secret", secret).Error
+	return
+}
+
 // saves channel configuration into the database
 func (j *JobDB) saveCahnnelConfig(channel *model.Channel) (err error) {
 	err = j.ds.Save(channel.Configuration).Error
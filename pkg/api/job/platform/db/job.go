@@ -4,13 +4,30 @@ import (
 	"cronspy/backend/pkg/util/exception"
 	"cronspy/backend/pkg/util/model"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 )
 
-// GetJobs returns the list of jobs for a user
-func (j *JobDB) GetJobs(idUser int, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
+// MySQL error returned when a unique index is violated
+const errDuplicateEntry = 1062
+
+// columns used to sort jobs
+var jobSortColumns = map[string]string{
+	model.JobSortName:        "name",
+	model.JobSortDateCreated: "date_created",
+	model.JobSortStatus:      "status",
+	model.JobSortLastPing:    "date_last_ping",
+}
+
+// escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetJobs returns a page of the list of jobs for a user that match the filter
+func (j *JobDB) GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
 
 	offset := 0
 	if page > 1 {
@@ -19,37 +36,185 @@ func (j *JobDB) GetJobs(idUser int, pageSize, page int) (jobs []model.Job, p mod
 
 	// get total records
 	totalRecords := 0
-	if err = j.ds.Model(model.Job{}).Where("id_user = ?", idUser).Count(&totalRecords).Error; err != nil {
+	if err = j.filterJobs(idUser, filter).Count(&totalRecords).Error; err != nil {
 		return
 	}
 
+	p.Page = page
+	p.PageSize = pageSize
+	p.TotalRows = totalRecords
+
 	// if page requested is invalid, return no results
-	if page > totalRecords/pageSize {
+	if offset >= totalRecords {
 		return
 	}
 
 	// get jobs
-	q := j.ds.Model(model.Job{}).Where("id_user = ?", idUser).Order("date_created asc")
+	q := j.sortJobs(j.filterJobs(idUser, filter), filter)
 	q = q.Offset(offset).Limit(pageSize)
-	err = q.Find(&jobs).Error
+	if err = q.Find(&jobs).Error; err == nil {
+		err = j.loadJobLabels(jobs)
+	}
+
+	return
+}
 
-	if err == nil {
-		p.Page = page
-		p.PageSize = pageSize
-		p.TotalRows = totalRecords
+// GetJobsAfter returns up to `pageSize` jobs for a user that match the filter,
+// starting after the `after` cursor (or from the start when nil); `next` is
+// the cursor of the last job, or nil if there are no more jobs
+func (j *JobDB) GetJobsAfter(idUser int, filter model.JobFilter, after *model.JobCursor, pageSize int) (jobs []model.Job, next *model.JobCursor, err error) {
+
+	q := j.filterJobs(idUser, filter)
+	if after != nil {
+		q = whereAfterJob(q, filter, after)
 	}
 
+	// one more job is requested to know if there's a next page
+	q = j.sortJobs(q, filter).Limit(pageSize + 1)
+	if err = q.Find(&jobs).Error; err != nil {
+		return
+	}
+
+	if len(jobs) > pageSize {
+		jobs = jobs[:pageSize]
+		next = model.NewJobCursor(&jobs[pageSize-1], filter)
+	}
+
+	err = j.loadJobLabels(jobs)
 	return
 }
 
-// GetJobByID return a job data by the ID
+// returns the query of the jobs of a user that match the filter
+func (j *JobDB) filterJobs(idUser int, filter model.JobFilter) *gorm.DB {
+	q := j.ds.Model(model.Job{}).Where("id_user = ?", idUser)
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.JobType != "" {
+		q = q.Where("job_type = ?", filter.JobType)
+	}
+	if filter.Active != nil {
+		q = q.Where("active = ?", *filter.Active)
+	}
+	if filter.Search != "" {
+		q = q.Where("name LIKE ?", "%"+likeEscaper.Replace(filter.Search)+"%")
+	}
+	for k, v := range filter.Labels {
+		labels := j.ds.Model(model.JobLabel{}).Select("id_job").Where("label_key = ? AND label_value = ?", k, v)
+		q = q.Where("id_job IN ?", labels.SubQuery())
+	}
+	return q
+}
+
+// sorts the jobs as indicated by the filter; the ID keeps the order stable
+func (j *JobDB) sortJobs(q *gorm.DB, filter model.JobFilter) *gorm.DB {
+	column, direction := jobSortColumn(filter), "asc"
+	if filter.SortDesc {
+		direction = "desc"
+	}
+	return q.Order(column + " " + direction).Order("id_job " + direction)
+}
+
+// returns the column used to sort the jobs
+func jobSortColumn(filter model.JobFilter) string {
+	if column, ok := jobSortColumns[filter.Sort]; ok {
+		return column
+	}
+	return jobSortColumns[model.JobSortDateCreated]
+}
+
+// returns `exception.ErrDuplicateRecord` when the error is caused by a
+// unique index, such as the one of the job slugs of a user
+func duplicateError(err error) error {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == errDuplicateEntry {
+		return exception.ErrDuplicateRecord
+	}
+	return err
+}
+
+// adds the conditions to get the jobs sorted after the cursor; MySQL sorts
+// NULL values first, so they are the lowest values of nullable columns
+func whereAfterJob(q *gorm.DB, filter model.JobFilter, after *model.JobCursor) *gorm.DB {
+	column := jobSortColumn(filter)
+	value := after.Value()
+
+	switch {
+	case !filter.SortDesc && value == nil:
+		return q.Where(fmt.Sprintf("(%[1]s IS NULL AND id_job > ?) OR %[1]s IS NOT NULL", column), after.ID)
+	case !filter.SortDesc:
+		return q.Where(fmt.Sprintf("%[1]s > ? OR (%[1]s = ? AND id_job > ?)", column), value, value, after.ID)
+	case value == nil:
+		return q.Where(fmt.Sprintf("%s IS NULL AND id_job < ?", column), after.ID)
+	default:
+		return q.Where(fmt.Sprintf("%[1]s < ? OR (%[1]s = ? AND id_job < ?) OR %[1]s IS NULL", column), value, value, after.ID)
+	}
+}
+
+// GetJobByID return a job data by the ID, with its labels
 func (j *JobDB) GetJobByID(id string) (job model.Job, err error) {
-	if err = j.ds.Model(model.Job{}).Where("id_job = ?", id).First(&job).Error; err == gorm.ErrRecordNotFound {
-		err = exception.ErrRecordNotFound
+	return j.getJob(j.ds.Model(model.Job{}).Where("id_job = ?", id))
+}
+
+// returns the first job of the query, with its labels
+func (j *JobDB) getJob(q *gorm.DB) (job model.Job, err error) {
+	if err = q.First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.ErrRecordNotFound
+		}
+		return
+	}
+
+	jobs := []model.Job{job}
+	if err = j.loadJobLabels(jobs); err == nil {
+		job = jobs[0]
 	}
 	return
 }
 
+// GetJobByPingKey returns a job by its ping key, with its labels
+func (j *JobDB) GetJobByPingKey(pingKey string) (job model.Job, err error) {
+	return j.getJob(j.ds.Model(model.Job{}).Where("ping_key = ?", pingKey))
+}
+
+// GetJobBySlug returns a job by its slug and the ping key of its user, with its labels
+func (j *JobDB) GetJobBySlug(userPingKey, slug string) (job model.Job, err error) {
+	users := j.ds.Model(model.User{}).Select("id_user").Where("ping_key = ?", userPingKey)
+	return j.getJob(j.ds.Model(model.Job{}).Where("id_user IN ? AND slug = ?", users.SubQuery(), slug))
+}
+
+// SlugExists checks if the user has a job with the slug, other than `idJob`
+func (j *JobDB) SlugExists(idUser int, slug, idJob string) (exists bool, err error) {
+	var count int
+	err = j.ds.Model(model.Job{}).Where("id_user = ? AND slug = ? AND id_job <> ?", idUser, slug, idJob).Count(&count).Error
+	exists = count > 0
+	return
+}
+
+// UpdateJobPingKey replaces the ping key of a job
+func (j *JobDB) UpdateJobPingKey(idJob, pingKey string) (err error) {
+	fields := map[string]interface{}{
+		"ping_key":     pingKey,
+		"date_updated": time.Now(),
+	}
+	err = j.ds.Model(model.Job{}).Where("id_job = ?", idJob).Updates(fields).Error
+	return
+}
+
+// UpdateJobPingSecret replaces the secret used to sign the pings of a job
+func (j *JobDB) UpdateJobPingSecret(idJob, secret string) (err error) {
+	fields := map[string]interface{}{
+		"ping_secret":  secret,
+		"date_updated": time.Now(),
+	}
+	err = j.ds.Model(model.Job{}).Where("id_job = ?", idJob).Updates(fields).Error
+	return
+}
+
+// IncrementRejectedPings adds one to the number of rejected pings of a job
+func (j *JobDB) IncrementRejectedPings(idJob string) (err error) {
+	return j.ds.Model(model.Job{}).Where("id_job = ?", idJob).UpdateColumn("rejected_pings", gorm.Expr("rejected_pings + 1")).Error
+}
+
 // SaveJob saves a job in the database
 func (j *JobDB) SaveJob(job *model.Job) (err error) {
 
@@ -62,6 +227,125 @@ func (j *JobDB) SaveJob(job *model.Job) (err error) {
 		return errors.New("invalid entity")
 	}
 
-	err = j.ds.Save(job).Error
+	trx := j.ds.Begin()
+
+	if err = trx.Save(job).Error; err != nil {
+		trx.Rollback()
+		err = duplicateError(err)
+		return
+	}
+
+	if err = saveJobLabels(trx, job.ID, job.Labels); err != nil {
+		trx.Rollback()
+		return
+	}
+
+	// commit changes if everything was OK
+	trx.Commit()
+	return
+}
+
+// GetActiveJobs returns all the active jobs, for every user, with their labels
+func (j *JobDB) GetActiveJobs() (jobs []model.Job, err error) {
+	if err = j.ds.Model(model.Job{}).Where("active = ?", true).Find(&jobs).Error; err == nil {
+		err = j.loadJobLabels(jobs)
+	}
+	return
+}
+
+// MarkJobAsLate sets the job status as ERROR, only if it's active and there were
+// no pings since `expected`; `updated` is false when the job was already in ERROR
+// or a ping was received in the meantime
+func (j *JobDB) MarkJobAsLate(idJob string, expected time.Time) (updated bool, err error) {
+	q := j.ds.Model(model.Job{}).Where("id_job = ? AND active = ? AND status <> ?", idJob, true, model.JobStatusError)
+	q = q.Where("date_last_ping IS NULL OR date_last_ping < ?", expected)
+	if q = q.Update("status", model.JobStatusError); q.Error == nil {
+		updated = q.RowsAffected > 0
+	}
+	err = q.Error
+	return
+}
+
+// UpdateJobDetectedInterval saves the interval learned for an AUTO job
+func (j *JobDB) UpdateJobDetectedInterval(idJob string, minutes int) (err error) {
+	return j.ds.Model(model.Job{}).Where("id_job = ?", idJob).Update("detected_interval_minutes", minutes).Error
+}
+
+// UpdateJob saves the name, slug, schedule, labels and ping protections of an existing job
+func (j *JobDB) UpdateJob(job *model.Job) (err error) {
+	job.DateUpdated = time.Now()
+
+	fields := map[string]interface{}{
+		"name":                     job.Name,
+		"slug":                     job.Slug,
+		"job_type":                 job.JobType,
+		"cron_expression":          job.CronExpression,
+		"cron_expression_timezone": job.CronExpressionTimezone,
+		"allowed_ips":              job.AllowedIPs,
+		"signed_pings":             job.SignedPings,
+		"ping_secret":              job.PingSecret,
+		"date_updated":             job.DateUpdated,
+	}
+
+	trx := j.ds.Begin()
+
+	if err = trx.Model(model.Job{}).Where("id_job = ?", job.ID).Updates(fields).Error; err != nil {
+		trx.Rollback()
+		err = duplicateError(err)
+		return
+	}
+
+	if err = saveJobLabels(trx, job.ID, job.Labels); err != nil {
+		trx.Rollback()
+		return
+	}
+
+	// commit changes if everything was OK
+	trx.Commit()
+	return
+}
+
+// UpdateJobActive pauses or resumes a job; the update date is also changed, so
+// runs expected while the job was paused are ignored
+func (j *JobDB) UpdateJobActive(job *model.Job, active bool) (err error) {
+	job.Active = active
+	job.DateUpdated = time.Now()
+
+	fields := map[string]interface{}{
+		"active":       job.Active,
+		"date_updated": job.DateUpdated,
+	}
+
+	err = j.ds.Model(model.Job{}).Where("id_job = ?", job.ID).Updates(fields).Error
+	return
+}
+
+// DeleteJob removes a job with its labels, alerts, pings, runs and
+// notification deliveries
+func (j *JobDB) DeleteJob(idJob string) (err error) {
+
+	trx := j.ds.Begin()
+
+	// notifications of the job are removed with their attempts, so pending
+	// ones are not sent and the others can't be retried
+	deliveries := trx.Model(model.NotificationDelivery{}).Where("id_job = ?", idJob)
+	if err = trx.Where("id_delivery IN ?", deliveries.Select("id_delivery").SubQuery()).Delete(model.NotificationAttempt{}).Error; err != nil {
+		trx.Rollback()
+		return
+	}
+	if err = deliveries.Delete(model.NotificationDelivery{}).Error; err != nil {
+		trx.Rollback()
+		return
+	}
+
+	for _, m := range []interface{}{model.JobLabel{}, model.JobAlert{}, model.JobPing{}, model.JobRun{}, model.Job{}} {
+		if err = trx.Where("id_job = ?", idJob).Delete(m).Error; err != nil {
+			trx.Rollback()
+			return
+		}
+	}
+
+	// commit changes if everything was OK
+	trx.Commit()
 	return
 }
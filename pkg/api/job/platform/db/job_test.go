@@ -0,0 +1,309 @@
+package db
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordDriver is a database driver that records the queries it runs; count
+// queries return `count` and any other query returns no rows
+type recordDriver struct {
+	count   int64
+	queries []recordedQuery
+	mux     sync.Mutex
+}
+
+type recordedQuery struct {
+	sql  string
+	args []driver.Value
+}
+
+type recordConn struct{ d *recordDriver }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+type recordRows struct {
+	values []driver.Value
+}
+
+var (
+	recordDrivers    = map[string]*recordDriver{}
+	recordDriversMux sync.Mutex
+)
+
+func init() {
+	sql.Register("record", driverFunc(func(name string) (driver.Conn, error) {
+		recordDriversMux.Lock()
+		defer recordDriversMux.Unlock()
+		return &recordConn{d: recordDrivers[name]}, nil
+	}))
+}
+
+type driverFunc func(name string) (driver.Conn, error)
+
+func (f driverFunc) Open(name string) (driver.Conn, error) { return f(name) }
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{d: c.d, query: query}, nil
+}
+func (c *recordConn) Close() error              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordConn) Commit() error             { return nil }
+func (c *recordConn) Rollback() error           { return nil }
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.record(s.query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.record(s.query, args)
+	if strings.HasPrefix(strings.ToLower(s.query), "select count(") {
+		return &recordRows{values: []driver.Value{s.d.count}}, nil
+	}
+	return &recordRows{}, nil
+}
+
+func (r *recordRows) Columns() []string {
+	if r.values == nil {
+		return []string{}
+	}
+	return []string{"count(*)"}
+}
+func (r *recordRows) Close() error { return nil }
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.values = nil
+	return nil
+}
+
+func (d *recordDriver) record(query string, args []driver.Value) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.queries = append(d.queries, recordedQuery{sql: query, args: args})
+}
+
+// returns the recorded queries that select jobs, other than counts
+func (d *recordDriver) jobQueries() (queries []recordedQuery) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for _, q := range d.queries {
+		if strings.HasPrefix(q.sql, "SELECT * FROM `cronspy`.`jobs`") {
+			queries = append(queries, q)
+		}
+	}
+	return
+}
+
+// returns a job database that records its queries, instead of running them
+func getRecordJobDB(t *testing.T, count int64) (*JobDB, *recordDriver) {
+	d := &recordDriver{count: count}
+
+	recordDriversMux.Lock()
+	recordDrivers[t.Name()] = d
+	recordDriversMux.Unlock()
+
+	sqlDB, err := sql.Open("record", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := gorm.Open("mysql", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewJobDB(ds), d
+}
+
+func TestGetJobsPages(t *testing.T) {
+	cases := []struct {
+		name   string
+		page   int
+		offset string
+	}{
+		{name: "First page", page: 1, offset: "OFFSET 0"},
+		{name: "Last partial page", page: 2, offset: "OFFSET 15"},
+		{name: "After the last page", page: 3},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, d := getRecordJobDB(t, 20)
+
+			_, p, err := db.GetJobs(1, model.JobFilter{}, 15, tt.page)
+
+			// assertions
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, model.Pagination{Page: tt.page, PageSize: 15, TotalRows: 20}, p)
+
+			queries := d.jobQueries()
+			if tt.page == 3 {
+				assert.Empty(t, queries)
+				return
+			}
+			if assert.Len(t, queries, 1) {
+				assert.Contains(t, queries[0].sql, "LIMIT 15 "+tt.offset)
+			}
+		})
+	}
+}
+
+func TestGetJobsAfter(t *testing.T) {
+	date := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	name := "Backup"
+
+	cases := []struct {
+		name   string
+		filter model.JobFilter
+		after  *model.JobCursor
+		where  string
+		args   []driver.Value
+		order  string
+	}{
+		{
+			name:  "First page",
+			order: "ORDER BY date_created asc,id_job asc LIMIT 11",
+		},
+		{
+			name:   "Ascending",
+			filter: model.JobFilter{Sort: model.JobSortName},
+			after:  &model.JobCursor{Sort: model.JobSortName, ID: "job-1", Text: &name},
+			where:  "(name > ? OR (name = ? AND id_job > ?))",
+			args:   []driver.Value{name, name, "job-1"},
+			order:  "ORDER BY name asc,id_job asc",
+		},
+		{
+			name:   "Descending",
+			filter: model.JobFilter{Sort: model.JobSortDateCreated, SortDesc: true},
+			after:  &model.JobCursor{Sort: model.JobSortDateCreated, Desc: true, ID: "job-1", Time: &date},
+			where:  "(date_created < ? OR (date_created = ? AND id_job < ?) OR date_created IS NULL)",
+			args:   []driver.Value{date, date, "job-1"},
+			order:  "ORDER BY date_created desc,id_job desc",
+		},
+		{
+			// NULL values are first, so every value follows them
+			name:   "Ascending after NULL",
+			filter: model.JobFilter{Sort: model.JobSortLastPing},
+			after:  &model.JobCursor{Sort: model.JobSortLastPing, ID: "job-1"},
+			where:  "((date_last_ping IS NULL AND id_job > ?) OR date_last_ping IS NOT NULL)",
+			args:   []driver.Value{"job-1"},
+			order:  "ORDER BY date_last_ping asc,id_job asc",
+		},
+		{
+			// NULL values are last, so only NULL values follow them
+			name:   "Descending after NULL",
+			filter: model.JobFilter{Sort: model.JobSortLastPing, SortDesc: true},
+			after:  &model.JobCursor{Sort: model.JobSortLastPing, Desc: true, ID: "job-1"},
+			where:  "(date_last_ping IS NULL AND id_job < ?)",
+			args:   []driver.Value{"job-1"},
+			order:  "ORDER BY date_last_ping desc,id_job desc",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, d := getRecordJobDB(t, 0)
+
+			jobs, next, err := db.GetJobsAfter(1, tt.filter, tt.after, 10)
+
+			// assertions
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Empty(t, jobs)
+			assert.Nil(t, next)
+
+			queries := d.jobQueries()
+			if !assert.Len(t, queries, 1) {
+				return
+			}
+			q := queries[0]
+			assert.Contains(t, q.sql, tt.order)
+			assert.Contains(t, q.sql, "LIMIT 11")
+			assert.Equal(t, driver.Value(int64(1)), q.args[0])
+			if tt.where == "" {
+				assert.Len(t, q.args, 1)
+				return
+			}
+			assert.Contains(t, q.sql, "AND "+tt.where)
+			assert.Equal(t, tt.args, q.args[1:])
+		})
+	}
+}
+
+func TestDeleteJob(t *testing.T) {
+	db, d := getRecordJobDB(t, 0)
+
+	err := db.DeleteJob("job-1")
+
+	// assertions
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var deletes []string
+	for _, q := range d.queries {
+		if strings.HasPrefix(q.sql, "DELETE") {
+			deletes = append(deletes, q.sql)
+		}
+	}
+
+	// the deliveries are deleted first, with their attempts
+	if assert.Len(t, deletes, 7) {
+		assert.Contains(t, deletes[0], "DELETE FROM `cronspy`.`notification_attempts`  WHERE (id_delivery IN (SELECT id_delivery FROM `cronspy`.`notification_deliveries`  WHERE (id_job = ?)))")
+		assert.Contains(t, deletes[1], "DELETE FROM `cronspy`.`notification_deliveries`  WHERE (id_job = ?)")
+		assert.Contains(t, deletes[6], "DELETE FROM `cronspy`.`jobs`  WHERE (id_job = ?)")
+	}
+	assert.Equal(t, []driver.Value{"job-1"}, d.queries[1].args)
+}
+
+func TestGetJobsAfterLabels(t *testing.T) {
+	db, d := getRecordJobDB(t, 0)
+
+	_, _, err := db.GetJobsAfter(1, model.JobFilter{Labels: model.JobLabels{"env": "prod"}}, nil, 10)
+
+	// assertions
+	if !assert.NoError(t, err) {
+		return
+	}
+	queries := d.jobQueries()
+	if assert.Len(t, queries, 1) {
+		assert.Contains(t, queries[0].sql, "AND (id_job IN (SELECT id_job FROM `cronspy`.`job_labels`  WHERE (label_key = ? AND label_value = ?)))")
+		assert.Equal(t, []driver.Value{int64(1), "env", "prod"}, queries[0].args)
+	}
+}
+
+func TestGetJobBySlug(t *testing.T) {
+	db, d := getRecordJobDB(t, 0)
+
+	_, err := db.GetJobBySlug("user-key", "nightly-backup")
+
+	// assertions
+	assert.Equal(t, exception.ErrRecordNotFound, err)
+	queries := d.jobQueries()
+	if assert.Len(t, queries, 1) {
+		assert.Contains(t, queries[0].sql, "WHERE (id_user IN (SELECT id_user FROM `cronspy`.`users`  WHERE (ping_key = ?)) AND slug = ?)")
+		assert.Equal(t, []driver.Value{"user-key", "nightly-backup"}, queries[0].args)
+	}
+}
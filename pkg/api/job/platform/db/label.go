@@ -0,0 +1,49 @@
+package db
+
+import (
+	"cronspy/backend/pkg/util/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// loads the labels of the jobs
+func (j *JobDB) loadJobLabels(jobs []model.Job) (err error) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	index := make(map[string]*model.Job, len(jobs))
+	ids := make([]string, len(jobs))
+	for i := range jobs {
+		index[jobs[i].ID] = &jobs[i]
+		ids[i] = jobs[i].ID
+	}
+
+	var labels []model.JobLabel
+	if err = j.ds.Model(model.JobLabel{}).Where("id_job IN (?)", ids).Find(&labels).Error; err != nil {
+		return
+	}
+
+	for _, l := range labels {
+		job := index[l.IDJob]
+		if job.Labels == nil {
+			job.Labels = make(model.JobLabels)
+		}
+		job.Labels[l.Key] = l.Value
+	}
+	return
+}
+
+// replaces the labels of a job within the transaction
+func saveJobLabels(trx *gorm.DB, idJob string, labels model.JobLabels) (err error) {
+	if err = trx.Where("id_job = ?", idJob).Delete(model.JobLabel{}).Error; err != nil {
+		return
+	}
+
+	for k, v := range labels {
+		if err = trx.Create(&model.JobLabel{IDJob: idJob, Key: k, Value: v}).Error; err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SavePing stores a new ping for the job and updates the job status,
+// last ping date and last run data in the same transaction;
+// `run` is optional and it's created or updated when provided.
+func (j *JobDB) SavePing(job *model.Job, ping *model.JobPing, run *model.JobRun) (err error) {
+
+	trx := j.ds.Begin()
+
+	if run != nil {
+		if err = trx.Save(run).Error; err != nil {
+			trx.Rollback()
+			return
+		}
+	}
+
+	if err = trx.Create(ping).Error; err != nil {
+		trx.Rollback()
+		return
+	}
+
+	fields := map[string]interface{}{
+		"status":               job.Status,
+		"date_last_ping":       job.DateLastPing,
+		"last_run_duration_ms": job.LastRunDurationMs,
+		"last_run_outcome":     job.LastRunOutcome,
+	}
+
+	if err = trx.Model(model.Job{}).Where("id_job = ?", job.ID).Updates(fields).Error; err != nil {
+		trx.Rollback()
+		return
+	}
+
+	// commit changes if everything was OK
+	trx.Commit()
+	return
+}
+
+// GetRunningJobRun returns the most recent run of a job that
+// was started but hasn't finished yet
+func (j *JobDB) GetRunningJobRun(idJob string) (run model.JobRun, err error) {
+	q := j.ds.Model(model.JobRun{}).Where("id_job = ? AND outcome = ?", idJob, model.RunOutcomeRunning)
+	if err = q.Order("date_started desc").First(&run).Error; err == gorm.ErrRecordNotFound {
+		err = exception.ErrRecordNotFound
+	}
+	return
+}
+
+// GetJobPingDates returns the dates of the latest pings of a job, of
+// any of the indicated kinds, newest first
+func (j *JobDB) GetJobPingDates(idJob string, kinds []string, limit int) (dates []time.Time, err error) {
+	q := j.ds.Model(model.JobPing{}).Where("id_job = ? AND kind IN (?)", idJob, kinds)
+	err = q.Order("date_created desc").Limit(limit).Pluck("date_created", &dates).Error
+	return
+}
+
+// GetJobPings returns the pings of a job that match the filter, newest first,
+// starting after the `after` cursor; `next` is nil on the last page
+func (j *JobDB) GetJobPings(idJob string, filter model.PingFilter, after *model.PingCursor, pageSize int) (pings []model.JobPing, next *model.PingCursor, err error) {
+
+	q := j.ds.Model(model.JobPing{}).Where("id_job = ?", idJob)
+	if filter.From != nil {
+		q = q.Where("date_created >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("date_created < ?", *filter.To)
+	}
+	if len(filter.Kinds) > 0 {
+		q = q.Where("kind IN (?)", filter.Kinds)
+	}
+	if after != nil {
+		q = q.Where("date_created < ? OR (date_created = ? AND id_ping < ?)", after.DateCreated, after.DateCreated, after.ID)
+	}
+
+	// one more ping is requested to know if there's a next page
+	q = q.Order("date_created desc").Order("id_ping desc").Limit(pageSize + 1)
+	if err = q.Find(&pings).Error; err != nil {
+		return
+	}
+
+	if len(pings) > pageSize {
+		pings = pings[:pageSize]
+		last := pings[pageSize-1]
+		next = &model.PingCursor{DateCreated: last.DateCreated, ID: last.ID}
+	}
+	return
+}
@@ -4,55 +4,125 @@ import (
 	"cronspy/backend/pkg/api/job/platform/db"
 	"cronspy/backend/pkg/util/log"
 	"cronspy/backend/pkg/util/model"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 // Service holds the functions delcared in the service interface
 type Service interface {
-	GetJobs(idUser int, pageSize, page int) (jobs []model.Job, p model.Pagination, err error)
-	GetJob(id string) (job model.Job, err error)
-	SaveJob(job *model.Job) (err error)
+	GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error)
+	GetJobsAfter(idUser int, filter model.JobFilter, after *model.JobCursor, pageSize int) (jobs []model.Job, next *model.JobCursor, err error)
+	GetJob(idJob string, idUser int) (job model.Job, err error)
+	SaveJob(idUser int, job *model.Job) (err error)
+	UpdateJob(idJob string, idUser int, job *model.Job) (err error)
+	DeleteJob(idJob string, idUser int) (err error)
+	PauseJob(idJob string, idUser int) (job model.Job, err error)
+	ResumeJob(idJob string, idUser int) (job model.Job, err error)
+	RotatePingKey(idJob string, idUser int) (pingKey string, err error)
+	RotatePingSecret(idJob string, idUser int) (secret string, err error)
+
+	RegisterPingByKey(pingKey string, ping *model.JobPing) (err error)
+	RegisterPingBySlug(userPingKey, slug string, ping *model.JobPing) (err error)
+	MaxPingBodySize() int
+	GetJobPings(idJob string, idUser int, filter model.PingFilter, after *model.PingCursor, pageSize int) (pings []model.JobPing, next *model.PingCursor, err error)
+
+	GetJobAlerts(idJob string, idUser int) (alerts []model.JobAlert, err error)
+	SaveJobAlert(idJob string, idUser int, alert *model.JobAlert) (err error)
+	UpdateJobAlert(idJob string, idAlert, idUser int, alert *model.JobAlert) (err error)
+	DeleteJobAlert(idJob string, idAlert, idUser int) (err error)
 
 	GetChannels(idUser int) (channels []model.Channel, err error)
-	SaveChannel(c *model.Channel) (err error)
+	SaveChannel(idUser int, c *model.Channel) (err error)
 	DeleteChannel(idChannel, idUser int) (err error)
 	UpdateChannel(idChannel, idUser int, channel *model.Channel) (err error)
+	RotateChannelSecret(idChannel, idUser int) (secret string, err error)
 }
 
 // DB holds the functions for database access
 type DB interface {
 	Transaction() *gorm.DB
 
-	GetJobs(idUser int, count, offset int) (jobs []model.Job, p model.Pagination, err error)
+	GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error)
+	GetJobsAfter(idUser int, filter model.JobFilter, after *model.JobCursor, pageSize int) (jobs []model.Job, next *model.JobCursor, err error)
 	GetJobByID(id string) (job model.Job, err error)
+	GetJobByPingKey(pingKey string) (job model.Job, err error)
+	GetJobBySlug(userPingKey, slug string) (job model.Job, err error)
+	SlugExists(idUser int, slug, idJob string) (exists bool, err error)
+	UpdateJobPingKey(idJob, pingKey string) (err error)
+	UpdateJobPingSecret(idJob, secret string) (err error)
+	IncrementRejectedPings(idJob string) (err error)
 	SaveJob(job *model.Job) (err error)
+	UpdateJob(job *model.Job) (err error)
+	UpdateJobActive(job *model.Job, active bool) (err error)
+	DeleteJob(idJob string) (err error)
+
+	GetActiveJobs() (jobs []model.Job, err error)
+	MarkJobAsLate(idJob string, expected time.Time) (updated bool, err error)
+	UpdateJobDetectedInterval(idJob string, minutes int) (err error)
+
+	SavePing(job *model.Job, ping *model.JobPing, run *model.JobRun) (err error)
+	GetRunningJobRun(idJob string) (run model.JobRun, err error)
+	GetJobPingDates(idJob string, kinds []string, limit int) (dates []time.Time, err error)
+	GetJobPings(idJob string, filter model.PingFilter, after *model.PingCursor, pageSize int) (pings []model.JobPing, next *model.PingCursor, err error)
+
+	GetJobAlerts(idJob string) (alerts []model.JobAlert, err error)
+	GetJobAlert(idAlert int) (alert model.JobAlert, err error)
+	SaveJobAlert(alert *model.JobAlert) (err error)
+	UpdateJobAlert(alert *model.JobAlert) (err error)
+	DeleteJobAlert(idAlert int) (err error)
+	MarkJobAlertNotified(idAlert int, date time.Time) (updated bool, err error)
+	ClearJobAlertNotified(idAlert int) (updated bool, err error)
 
 	GetChannel(idChannel int, loadChannelConfig bool) (c model.Channel, err error)
 	GetChannels(idUser int, loadChannelConfig bool) (channels []model.Channel, err error)
 	SaveChannel(channel *model.Channel) (err error)
 	DeleteChannel(channel *model.Channel) (err error)
 	UpdateChannel(channel *model.Channel) (err error)
+	UpdateChannelSigningSecret(idChannel int, secret string) (err error)
+}
+
+// AlertHandler receives the alert events emitted when a job changes its status
+type AlertHandler interface {
+	HandleAlert(e model.AlertEvent)
 }
 
 // Job defines the module for user related operations
 type Job struct {
-	database DB
-	logger   *log.Log
+	database        DB
+	logger          *log.Log
+	alerts          AlertHandler
+	maxPingBodySize int
 }
 
 // creates new reseller service
-func new(database DB, l *log.Log) *Job {
+func new(database DB, l *log.Log, alerts AlertHandler) *Job {
 	return &Job{
-		database: database,
-		logger:   l,
+		database:        database,
+		logger:          l,
+		alerts:          alerts,
+		maxPingBodySize: DefaultMaxPingBodySize,
 	}
 }
 
-// Initialize initializes tax application service
-func Initialize(ds *gorm.DB, dbService DB, l *log.Log) *Job {
+// Initialize initializes tax application service; alert events are
+// only logged when `alerts` is nil
+func Initialize(ds *gorm.DB, dbService DB, l *log.Log, alerts AlertHandler) *Job {
 	if dbService == nil {
 		dbService = db.NewJobDB(ds)
 	}
-	return new(dbService, l)
+	return new(dbService, l, alerts)
+}
+
+// SetMaxPingBodySize sets the max number of bytes stored for the body of a
+// ping; longer bodies are truncated
+func (j *Job) SetMaxPingBodySize(size int) {
+	if size > 0 {
+		j.maxPingBodySize = size
+	}
+}
+
+// MaxPingBodySize returns the max number of bytes stored for the body of a ping
+func (j *Job) MaxPingBodySize() int {
+	return j.maxPingBodySize
 }
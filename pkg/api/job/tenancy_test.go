@@ -0,0 +1,317 @@
+package job
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ****************************************************
+//
+// DATABASE MOCK
+//
+// ****************************************************
+
+// tenancyDBMock holds the data of two users and records every write;
+// any other call panics
+type tenancyDBMock struct {
+	DB
+
+	jobs     []model.Job
+	alerts   []model.JobAlert
+	channels []model.Channel
+	writes   []string
+}
+
+func (db *tenancyDBMock) GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].IDUser == idUser {
+			jobs = append(jobs, db.jobs[i])
+		}
+	}
+	return
+}
+
+func (db *tenancyDBMock) GetJobByID(id string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == id {
+			return db.jobs[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *tenancyDBMock) GetJobAlerts(idJob string) (alerts []model.JobAlert, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].IDJob == idJob {
+			alerts = append(alerts, db.alerts[i])
+		}
+	}
+	return
+}
+
+func (db *tenancyDBMock) GetJobAlert(idAlert int) (alert model.JobAlert, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert {
+			return db.alerts[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *tenancyDBMock) GetChannel(idChannel int, loadChannelConfig bool) (c model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == idChannel {
+			return db.channels[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *tenancyDBMock) GetChannels(idUser int, loadChannelConfig bool) (channels []model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].IDUser == idUser {
+			channels = append(channels, db.channels[i])
+		}
+	}
+	return
+}
+
+func (db *tenancyDBMock) SaveJob(job *model.Job) (err error) {
+	db.writes = append(db.writes, "SaveJob:"+job.ID)
+	return
+}
+
+func (db *tenancyDBMock) UpdateJob(job *model.Job) (err error) {
+	db.writes = append(db.writes, "UpdateJob")
+	return
+}
+
+func (db *tenancyDBMock) SlugExists(idUser int, slug, idJob string) (exists bool, err error) {
+	for _, x := range db.jobs {
+		if x.IDUser == idUser && x.Slug != nil && *x.Slug == slug && x.ID != idJob {
+			return true, nil
+		}
+	}
+	return
+}
+
+func (db *tenancyDBMock) UpdateJobPingKey(idJob, pingKey string) (err error) {
+	db.writes = append(db.writes, "UpdateJobPingKey")
+	return
+}
+
+func (db *tenancyDBMock) UpdateJobPingSecret(idJob, secret string) (err error) {
+	db.writes = append(db.writes, "UpdateJobPingSecret")
+	return
+}
+
+func (db *tenancyDBMock) UpdateJobActive(job *model.Job, active bool) (err error) {
+	db.writes = append(db.writes, "UpdateJobActive")
+	return
+}
+
+func (db *tenancyDBMock) DeleteJob(idJob string) (err error) {
+	db.writes = append(db.writes, "DeleteJob")
+	return
+}
+
+func (db *tenancyDBMock) SaveJobAlert(alert *model.JobAlert) (err error) {
+	db.writes = append(db.writes, "SaveJobAlert")
+	return
+}
+
+func (db *tenancyDBMock) UpdateJobAlert(alert *model.JobAlert) (err error) {
+	db.writes = append(db.writes, "UpdateJobAlert")
+	return
+}
+
+func (db *tenancyDBMock) DeleteJobAlert(idAlert int) (err error) {
+	db.writes = append(db.writes, "DeleteJobAlert")
+	return
+}
+
+func (db *tenancyDBMock) SaveChannel(channel *model.Channel) (err error) {
+	db.writes = append(db.writes, "SaveChannel")
+	return
+}
+
+func (db *tenancyDBMock) UpdateChannel(channel *model.Channel) (err error) {
+	db.writes = append(db.writes, "UpdateChannel")
+	return
+}
+
+func (db *tenancyDBMock) DeleteChannel(channel *model.Channel) (err error) {
+	db.writes = append(db.writes, "DeleteChannel")
+	return
+}
+
+func (db *tenancyDBMock) UpdateChannelSigningSecret(idChannel int, secret string) (err error) {
+	db.writes = append(db.writes, "UpdateChannelSigningSecret")
+	return
+}
+
+// user 1 (A) and user 2 (B) have a job, an alert and a channel each
+func getTenancyMock() (*tenancyDBMock, *Job) {
+	db := &tenancyDBMock{
+		jobs: []model.Job{
+			{ID: "job-a", IDUser: 1, Name: "A", JobType: model.JobTypeAuto, Active: true},
+			{ID: "job-b", IDUser: 2, Name: "B", JobType: model.JobTypeAuto, Active: true},
+		},
+		alerts: []model.JobAlert{
+			{ID: 1, IDJob: "job-a", IDChannel: 1},
+			{ID: 2, IDJob: "job-b", IDChannel: 2},
+		},
+		channels: []model.Channel{
+			{ID: 1, IDUser: 1, Name: "A", Type: model.ChannelTypeWebHook, Configuration: map[string]interface{}{}},
+			{ID: 2, IDUser: 2, Name: "B", Type: model.ChannelTypeWebHook, Configuration: map[string]interface{}{}},
+		},
+	}
+
+	return db, new(db, log.New(), nil)
+}
+
+func TestTenancyUserCantAccessOtherUsersData(t *testing.T) {
+
+	// every call is made by user A against the data of user B
+	cases := []struct {
+		name   string
+		call   func(svc *Job) error
+		status int
+	}{
+		{name: "GetJob", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.GetJob("job-b", 1)
+			return err
+		}},
+		{name: "UpdateJob", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.UpdateJob("job-b", 1, &model.Job{Name: "Mine"})
+		}},
+		{name: "DeleteJob", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.DeleteJob("job-b", 1)
+		}},
+		{name: "PauseJob", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.PauseJob("job-b", 1)
+			return err
+		}},
+		{name: "ResumeJob", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.ResumeJob("job-b", 1)
+			return err
+		}},
+		{name: "RotatePingKey", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.RotatePingKey("job-b", 1)
+			return err
+		}},
+		{name: "RotatePingSecret", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.RotatePingSecret("job-b", 1)
+			return err
+		}},
+		{name: "GetJobPings", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, _, err := svc.GetJobPings("job-b", 1, model.PingFilter{}, nil, 10)
+			return err
+		}},
+		{name: "GetJobAlerts", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.GetJobAlerts("job-b", 1)
+			return err
+		}},
+		{name: "SaveJobAlert on other job", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.SaveJobAlert("job-b", 1, &model.JobAlert{IDChannel: 1})
+		}},
+		{name: "SaveJobAlert with other channel", status: http.StatusBadRequest, call: func(svc *Job) error {
+			return svc.SaveJobAlert("job-a", 1, &model.JobAlert{IDChannel: 2})
+		}},
+		{name: "UpdateJobAlert on other job", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.UpdateJobAlert("job-b", 2, 1, &model.JobAlert{IDChannel: 1})
+		}},
+		{name: "UpdateJobAlert of other job through own job", status: http.StatusNotFound, call: func(svc *Job) error {
+			return svc.UpdateJobAlert("job-a", 2, 1, &model.JobAlert{IDChannel: 1})
+		}},
+		{name: "UpdateJobAlert with other channel", status: http.StatusBadRequest, call: func(svc *Job) error {
+			return svc.UpdateJobAlert("job-a", 1, 1, &model.JobAlert{IDChannel: 2})
+		}},
+		{name: "DeleteJobAlert on other job", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.DeleteJobAlert("job-b", 2, 1)
+		}},
+		{name: "DeleteJobAlert of other job through own job", status: http.StatusNotFound, call: func(svc *Job) error {
+			return svc.DeleteJobAlert("job-a", 2, 1)
+		}},
+		{name: "UpdateChannel", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.UpdateChannel(2, 1, &model.Channel{Name: "Mine"})
+		}},
+		{name: "DeleteChannel", status: http.StatusForbidden, call: func(svc *Job) error {
+			return svc.DeleteChannel(2, 1)
+		}},
+		{name: "RotateChannelSecret", status: http.StatusForbidden, call: func(svc *Job) error {
+			_, err := svc.RotateChannelSecret(2, 1)
+			return err
+		}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			db, svc := getTenancyMock()
+
+			err := tt.call(svc)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+
+			// nothing was written
+			assert.Empty(t, db.writes)
+		})
+	}
+}
+
+func TestTenancyListsOnlyOwnData(t *testing.T) {
+	_, svc := getTenancyMock()
+
+	jobs, _, err := svc.GetJobs(1, model.JobFilter{}, 10, 1)
+	if assert.NoError(t, err) && assert.Len(t, jobs, 1) {
+		assert.Equal(t, "job-a", jobs[0].ID)
+	}
+
+	channels, err := svc.GetChannels(1)
+	if assert.NoError(t, err) && assert.Len(t, channels, 1) {
+		assert.Equal(t, 1, channels[0].ID)
+	}
+
+	job, err := svc.GetJob("job-a", 1)
+	if assert.NoError(t, err) && assert.Len(t, job.Alerts, 1) {
+		assert.Equal(t, 1, job.Alerts[0].ID)
+	}
+}
+
+func TestTenancyCreateIgnoresClientIDs(t *testing.T) {
+	db, svc := getTenancyMock()
+
+	// the IDs of user B's job and channel are ignored, as is the ping key
+	job := &model.Job{ID: "job-b", IDUser: 2, Name: "Mine", PingKey: "job-b-key"}
+	if assert.NoError(t, svc.SaveJob(1, job)) {
+		assert.Equal(t, 1, job.IDUser)
+		assert.NotEqual(t, "job-b-key", job.PingKey)
+		assert.Equal(t, []string{"SaveJob:"}, db.writes)
+	}
+
+	c := &model.Channel{ID: 2, IDUser: 2, Name: "Mine", Type: model.ChannelTypeSlack}
+	if assert.NoError(t, svc.SaveChannel(1, c)) {
+		assert.Equal(t, 0, c.ID)
+		assert.Equal(t, 1, c.IDUser)
+	}
+}
+
+func TestTenancySlugsAreScopedToTheUser(t *testing.T) {
+	db, svc := getTenancyMock()
+	slug := "nightly-backup"
+	db.jobs[1].Slug = &slug
+
+	// user B's slug is free for user A
+	job := &model.Job{Name: "Mine", Slug: &slug}
+	assert.NoError(t, svc.SaveJob(1, job))
+}
@@ -2,13 +2,25 @@ package transport
 
 import (
 	"cronspy/backend/pkg/api/job"
+	"cronspy/backend/pkg/util/cron"
+	"cronspy/backend/pkg/util/cursor"
 	"cronspy/backend/pkg/util/exception"
 	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/pingkey"
+	"cronspy/backend/pkg/webhook"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -16,13 +28,46 @@ import (
 const (
 	// DefaultPageSize configures the default number of records to return
 	DefaultPageSize = 15
+	// MaxPageSize configures the max number of records to return
+	MaxPageSize = 100
 	// DefaultJobName contains a default name for jobs that are created without one
 	DefaultJobName = "Job Monitor"
+	// DefaultPreviewRuns configures the default number of runs returned by the preview
+	DefaultPreviewRuns = 5
+	// MaxPreviewRuns configures the max number of runs returned by the preview
+	MaxPreviewRuns = 50
+	// PingExportPageSize configures the number of pings loaded at once by the CSV export
+	PingExportPageSize = 500
+	// MaxExportPings configures the max number of pings of the CSV export
+	MaxExportPings = 10000
+	// MaxSearchLength configures the max number of characters of the job search
+	MaxSearchLength = 100
+	// MaxSlugLength configures the max number of characters of a job slug
+	MaxSlugLength = 63
+	// MaxAllowedIPs configures the max number of IPs or networks allowed to ping a job
+	MaxAllowedIPs = 50
+	// MaxJobLabels configures the max number of labels of a job
+	MaxJobLabels = 20
+	// MaxLabelKeyLength configures the max number of characters of a label key
+	MaxLabelKeyLength = 63
+	// MaxLabelValueLength configures the max number of characters of a label value
+	MaxLabelValueLength = 255
+	// MaxPingRequestBodySize configures the max number of bytes read from the
+	// body of a ping; signed pings must not be bigger
+	MaxPingRequestBodySize = 1024 * 1024
 )
 
 var (
 	// IsUserLoggedIn is a middleware to restrict URL to logged user
 	IsUserLoggedIn echo.MiddlewareFunc
+
+	// label keys are made of letters, digits, `_`, `-`, `.` and `/`
+	labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+
+	// slugs are lowercase words separated by `-`; `start` and `fail` are
+	// reserved, since they are also used in ping URLs
+	slugRegexp    = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	reservedSlugs = map[string]bool{"start": true, "fail": true}
 )
 
 // HTTP represents auth http service
@@ -45,9 +90,26 @@ func NewHTTP(svc job.Service, jwtSigningKey string, jwtSigningMethod *jwt.Signin
 
 	// configure routes
 	jobs := e.Group("/jobs")
-	jobs.GET("", h.userJobsHandler, IsUserLoggedIn)       // get user jobs
-	jobs.POST("", h.createJobHandler, IsUserLoggedIn)     // create job
-	jobs.GET("/:job-id", h.getJobHandler, IsUserLoggedIn) // get job by id
+	jobs.GET("", h.userJobsHandler, IsUserLoggedIn)                // get user jobs
+	jobs.POST("", h.createJobHandler, IsUserLoggedIn)              // create job
+	jobs.POST("/preview", h.previewJobRunsHandler, IsUserLoggedIn) // preview next runs of a cron expression
+	jobs.GET("/:job-id", h.getJobHandler, IsUserLoggedIn)          // get job by id
+	jobs.PUT("/:job-id", h.updateJobHandler, IsUserLoggedIn)       // update job
+	jobs.DELETE("/:job-id", h.deleteJobHandler, IsUserLoggedIn)    // delete job
+
+	jobs.POST("/:job-id/pause", h.pauseJobHandler, IsUserLoggedIn)   // pause job evaluation
+	jobs.POST("/:job-id/resume", h.resumeJobHandler, IsUserLoggedIn) // resume job evaluation
+
+	jobs.POST("/:job-id/rotate-ping-key", h.rotatePingKeyHandler, IsUserLoggedIn)       // rotate job ping key
+	jobs.POST("/:job-id/rotate-ping-secret", h.rotatePingSecretHandler, IsUserLoggedIn) // rotate job ping signing secret
+
+	jobs.GET("/:job-id/pings", h.getJobPingsHandler, IsUserLoggedIn)           // get job ping history
+	jobs.GET("/:job-id/pings/export", h.exportJobPingsHandler, IsUserLoggedIn) // export job ping history as CSV
+
+	jobs.GET("/:job-id/alerts", h.getJobAlertsHandler, IsUserLoggedIn)                // get job alerts
+	jobs.POST("/:job-id/alerts", h.createJobAlertHandler, IsUserLoggedIn)             // create job alert
+	jobs.PUT("/:job-id/alerts/:alert-id", h.updateJobAlertHandler, IsUserLoggedIn)    // update job alert
+	jobs.DELETE("/:job-id/alerts/:alert-id", h.deleteJobAlertHandler, IsUserLoggedIn) // delete job alert
 
 	channels := e.Group("/channels")
 	channels.GET("", h.getChannelsHandler, IsUserLoggedIn)                  // get user channels
@@ -55,18 +117,488 @@ func NewHTTP(svc job.Service, jwtSigningKey string, jwtSigningMethod *jwt.Signin
 	channels.DELETE("/:channel-id", h.deleteChannelHandler, IsUserLoggedIn) // delete channel
 	channels.PUT("/:channel-id", h.updateChannelHandler, IsUserLoggedIn)    // update channel
 
-}
+	channels.POST("/:channel-id/rotate-secret", h.rotateChannelSecretHandler, IsUserLoggedIn) // rotate web hook signing secret
+
+	// --- Auth NOT required ---
+	// `key` is the ping key of the job, or the ping key of the user when followed by a job slug
+	ping := e.Group("/ping")
+	ping.GET("/:key", h.pingHandler)                   // register success ping
+	ping.POST("/:key", h.pingHandler)                  // register success ping
+	ping.GET("/:key/start", h.pingStartHandler)        // register start ping
+	ping.POST("/:key/start", h.pingStartHandler)       // register start ping
+	ping.GET("/:key/fail", h.pingFailHandler)          // register fail ping
+	ping.POST("/:key/fail", h.pingFailHandler)         // register fail ping
+	ping.GET("/:key/:slug", h.pingHandler)             // register success ping
+	ping.POST("/:key/:slug", h.pingHandler)            // register success ping
+	ping.GET("/:key/:slug/start", h.pingStartHandler)  // register start ping
+	ping.POST("/:key/:slug/start", h.pingStartHandler) // register start ping
+	ping.GET("/:key/:slug/fail", h.pingFailHandler)    // register fail ping
+	ping.POST("/:key/:slug/fail", h.pingFailHandler)   // register fail ping
+
+}
+
+func (h *HTTP) getJWTConfig() (jwtCfg middleware.JWTConfig) {
+	jwtCfg.SigningMethod = h.jwtSigningMethod.Name
+	jwtCfg.SigningKey = []byte(h.jwtSigningKey)
+	return
+}
+
+//
+// --- GET USER JOBS ---.
+//
+func (h *HTTP) userJobsHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// get page size
+	pageSize, err := h.getPageSize(c)
+	if err != nil {
+		return err
+	}
+
+	// get filters
+	filter, fields, msg := h.getJobFilter(c)
+	if fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	type response struct {
+		Jobs       []model.Job       `json:"jobs,omitempty"`
+		Pagination *model.Pagination `json:"pagination,omitempty"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}
+
+	// cursors are opt-in: an empty `cursor` asks for the first page
+	if _, ok := c.QueryParams()["cursor"]; !ok {
+		page := 1
+		if pageStr := c.QueryParam("page"); pageStr != "" {
+			var errConv error
+			if page, errConv = strconv.Atoi(pageStr); errConv != nil || page < 1 {
+				return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPage, ""))
+			}
+		}
+
+		jobs, p, err := h.svc.GetJobs(idUser, filter, pageSize, page)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, response{Jobs: jobs, Pagination: &p})
+	}
+
+	// the cursor must belong to a list with the same sort
+	var after *model.JobCursor
+	if token := c.QueryParam("cursor"); token != "" {
+		after = new(model.JobCursor)
+		if errCursor := cursor.Decode(token, after); errCursor != nil || after.ID == "" || after.Sort != filter.Sort || after.Desc != filter.SortDesc {
+			return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidCursor, ""))
+		}
+	}
+
+	// get jobs
+	jobs, next, err := h.svc.GetJobsAfter(idUser, filter, after, pageSize)
+	if err != nil {
+		return err
+	}
+
+	resp := response{Jobs: jobs}
+	if next != nil {
+		if resp.NextCursor, err = cursor.Encode(next); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+//
+// --- GET JOB ---
+//
+func (h *HTTP) getJobHandler(c echo.Context) error {
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	// get job
+	job, err := h.svc.GetJob(idJob, idUser)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+//
+// --- CREATE JOB ---
+//
+func (h *HTTP) createJobHandler(c echo.Context) error {
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	payload := new(model.Job)
+	if err := c.Bind(payload); err != nil {
+		return err
+	}
+
+	// validate input
+	if fields, msg := h.validateCreateJobInput(payload); fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	if err := h.svc.SaveJob(idUser, payload); err != nil {
+		return err
+	}
+
+	// the ping secret is only returned here and when it's rotated
+	type response struct {
+		model.Job
+		PingSecret *string `json:"ping_secret,omitempty"`
+	}
+
+	return c.JSON(http.StatusCreated, response{Job: *payload, PingSecret: payload.PingSecret})
+}
+
+//
+// --- UPDATE JOB ---
+//
+func (h *HTTP) updateJobHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	payload := new(model.Job)
+	if err := c.Bind(payload); err != nil {
+		return err
+	}
+
+	// validate input
+	if fields, msg := h.validateCreateJobInput(payload); fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	if err := h.svc.UpdateJob(idJob, idUser, payload); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, payload)
+}
+
+//
+// --- DELETE JOB ---
+//
+func (h *HTTP) deleteJobHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.svc.DeleteJob(idJob, idUser); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+//
+// --- PAUSE JOB ---
+//
+func (h *HTTP) pauseJobHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.svc.PauseJob(idJob, idUser)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+//
+// --- RESUME JOB ---
+//
+func (h *HTTP) resumeJobHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.svc.ResumeJob(idJob, idUser)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+//
+// --- ROTATE JOB PING KEY ---
+//
+func (h *HTTP) rotatePingKeyHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	pingKey, err := h.svc.RotatePingKey(idJob, idUser)
+	if err != nil {
+		return err
+	}
+
+	type response struct {
+		PingKey string `json:"ping_key"`
+	}
+
+	return c.JSON(http.StatusOK, response{PingKey: pingKey})
+}
+
+//
+// --- ROTATE JOB PING SECRET ---
+//
+func (h *HTTP) rotatePingSecretHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	secret, err := h.svc.RotatePingSecret(idJob, idUser)
+	if err != nil {
+		return err
+	}
+
+	type response struct {
+		PingSecret string `json:"ping_secret"`
+	}
+
+	return c.JSON(http.StatusOK, response{PingSecret: secret})
+}
+
+//
+// --- GET JOB PINGS ---
+//
+func (h *HTTP) getJobPingsHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	pageSize, err := h.getPageSize(c)
+	if err != nil {
+		return err
+	}
+
+	filter, fields, msg := h.getPingFilter(c)
+	if fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	var after *model.PingCursor
+	if token := c.QueryParam("cursor"); token != "" {
+		after = new(model.PingCursor)
+		if errCursor := cursor.Decode(token, after); errCursor != nil || after.ID == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidCursor, ""))
+		}
+	}
+
+	pings, next, err := h.svc.GetJobPings(idJob, idUser, filter, after, pageSize)
+	if err != nil {
+		return err
+	}
+
+	type response struct {
+		Pings      []model.JobPing `json:"pings"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+
+	resp := response{Pings: pings}
+	if resp.Pings == nil {
+		resp.Pings = []model.JobPing{}
+	}
+	if next != nil {
+		if resp.NextCursor, err = cursor.Encode(next); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+//
+// --- EXPORT JOB PINGS ---
+//
+func (h *HTTP) exportJobPingsHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
+	}
+
+	filter, fields, msg := h.getPingFilter(c)
+	if fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	// the first page is loaded before writing anything, so errors can still be returned
+	pings, next, err := h.svc.GetJobPings(idJob, idUser, filter, nil, PingExportPageSize)
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="pings-%s.csv"`, idJob))
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	w.Write([]string{"id", "date_created", "kind", "source_ip", "user_agent", "duration_ms", "exit_code", "body"})
+
+	// only the newest `MaxExportPings` are exported; older ones can be
+	// requested with the `to` filter
+	for exported := 0; ; {
+		for _, p := range pings {
+			w.Write(pingCSVRecord(&p))
+		}
+		w.Flush()
+
+		exported += len(pings)
+		if next == nil || exported >= MaxExportPings {
+			break
+		}
+
+		if pings, next, err = h.svc.GetJobPings(idJob, idUser, filter, next, PingExportPageSize); err != nil {
+			return err
+		}
+	}
+
+	return w.Error()
+}
+
+//
+// --- PREVIEW JOB RUNS ---
+//
+func (h *HTTP) previewJobRunsHandler(c echo.Context) error {
+
+	type request struct {
+		CronExpression         *string `json:"cron_expression"`
+		CronExpressionTimezone *string `json:"cron_expression_timezone"`
+		Count                  int     `json:"count"`
+	}
+
+	payload := new(request)
+	if err := c.Bind(payload); err != nil {
+		return err
+	}
+
+	if payload.Count == 0 {
+		payload.Count = DefaultPreviewRuns
+	}
+
+	// validate input
+	j := &model.Job{
+		JobType:                model.JobTypeCron,
+		CronExpression:         payload.CronExpression,
+		CronExpressionTimezone: payload.CronExpressionTimezone,
+	}
+
+	invalidFields, details := h.validateCronInput(j)
+	if payload.Count < 0 || payload.Count > MaxPreviewRuns {
+		invalidFields = append(invalidFields, "count")
+		details = append(details, fmt.Sprintf("count: must be between 1 and %d", MaxPreviewRuns))
+	}
+
+	if len(invalidFields) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, strings.Join(details, "; "), strings.Join(invalidFields, ",")))
+	}
 
-func (h *HTTP) getJWTConfig() (jwtCfg middleware.JWTConfig) {
-	jwtCfg.SigningMethod = h.jwtSigningMethod.Name
-	jwtCfg.SigningKey = []byte(h.jwtSigningKey)
-	return
+	// calculate runs
+	runs, err := j.GetNextRuns(time.Now(), payload.Count)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+
+	type response struct {
+		NextRuns []time.Time `json:"next_runs"`
+	}
+
+	return c.JSON(http.StatusOK, response{NextRuns: runs})
 }
 
 //
-// --- GET USER JOBS ---.
+// --- GET JOB ALERTS ---
 //
-func (h *HTTP) userJobsHandler(c echo.Context) error {
+func (h *HTTP) getJobAlertsHandler(c echo.Context) error {
 
 	// get user id
 	idUser, _, err := h.getUserID(c)
@@ -74,93 +606,143 @@ func (h *HTTP) userJobsHandler(c echo.Context) error {
 		return err
 	}
 
-	// get pagination data
-	var page, pageSize int
-	pageStr := c.QueryParam("page")
-	pageSizeStr := c.QueryParam("page_size")
-
-	if pageStr == "" {
-		pageStr = "1"
+	idJob, err := h.getJobID(c)
+	if err != nil {
+		return err
 	}
-	if pageSizeStr == "" {
-		pageSize = DefaultPageSize
+
+	alerts, err := h.svc.GetJobAlerts(idJob, idUser)
+	if err != nil {
+		return err
 	}
 
-	page, errConv := strconv.Atoi(pageStr)
-	if errConv != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPage, ""))
+	type response struct {
+		Alerts []model.JobAlert `json:"alerts"`
 	}
 
-	if pageSize == 0 {
-		pageSize, errConv = strconv.Atoi(pageSizeStr)
-		if errConv != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPageSize, ""))
-		}
+	return c.JSON(http.StatusOK, response{Alerts: alerts})
+}
+
+//
+// --- CREATE JOB ALERT ---
+//
+func (h *HTTP) createJobAlertHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
 	}
 
-	// get jobs
-	jobs, p, err := h.svc.GetJobs(int(idUser), pageSize, page)
+	idJob, err := h.getJobID(c)
 	if err != nil {
 		return err
 	}
 
-	type response struct {
-		Jobs       []model.Job      `json:"jobs,omitempty"`
-		Pagination model.Pagination `json:"pagination,omitempty"`
+	payload := new(model.JobAlert)
+	if err := c.Bind(payload); err != nil {
+		return err
+	}
+
+	// validate input
+	if fields, msg := h.validateJobAlertInput(payload); fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	if err := h.svc.SaveJobAlert(idJob, idUser, payload); err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, response{Jobs: jobs, Pagination: p})
+	return c.JSON(http.StatusCreated, payload)
 }
 
 //
-// --- GET JOB ---
+// --- UPDATE JOB ALERT ---
 //
-func (h *HTTP) getJobHandler(c echo.Context) error {
+func (h *HTTP) updateJobAlertHandler(c echo.Context) error {
+
 	// get user id
 	idUser, _, err := h.getUserID(c)
 	if err != nil {
 		return err
 	}
 
-	// get jobs
-	job, err := h.svc.GetJob(c.Param("job-id"))
+	idJob, err := h.getJobID(c)
 	if err != nil {
 		return err
 	}
 
-	if job.IDUser != int(idUser) {
-		return echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+	// get alert id from path
+	idAlert, errConv := strconv.Atoi(c.Param("alert-id"))
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
 	}
 
-	return c.JSON(http.StatusOK, job)
+	payload := new(model.JobAlert)
+	if err := c.Bind(payload); err != nil {
+		return err
+	}
+
+	// validate input
+	if fields, msg := h.validateJobAlertInput(payload); fields != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, msg, fields))
+	}
+
+	if err := h.svc.UpdateJobAlert(idJob, idAlert, idUser, payload); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, payload)
 }
 
 //
-// --- CREATE JOB ---
+// --- DELETE JOB ALERT ---
 //
-func (h *HTTP) createJobHandler(c echo.Context) error {
+func (h *HTTP) deleteJobAlertHandler(c echo.Context) error {
+
 	// get user id
 	idUser, _, err := h.getUserID(c)
 	if err != nil {
 		return err
 	}
 
-	payload := new(model.Job)
-	if err := c.Bind(payload); err != nil {
+	idJob, err := h.getJobID(c)
+	if err != nil {
 		return err
 	}
 
-	// validate input
-	if fields := h.validateCreateJobInput(payload); fields != "" {
-		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, "", fields))
+	// get alert id from path
+	idAlert, errConv := strconv.Atoi(c.Param("alert-id"))
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
 	}
 
-	payload.IDUser = idUser
-	if err := h.svc.SaveJob(payload); err != nil {
+	if err := h.svc.DeleteJobAlert(idJob, idAlert, idUser); err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, payload)
+	return c.NoContent(http.StatusOK)
+}
+
+//
+// --- PING ---
+//
+func (h *HTTP) pingHandler(c echo.Context) error {
+	return h.registerPing(c, model.PingKindSuccess)
+}
+
+//
+// --- PING: START ---
+//
+func (h *HTTP) pingStartHandler(c echo.Context) error {
+	return h.registerPing(c, model.PingKindStart)
+}
+
+//
+// --- PING: FAIL ---
+//
+func (h *HTTP) pingFailHandler(c echo.Context) error {
+	return h.registerPing(c, model.PingKindFail)
 }
 
 //
@@ -223,8 +805,7 @@ func (h *HTTP) createChannelHandler(c echo.Context) error {
 	}
 
 	// save channel
-	payload.IDUser = idUser
-	if err := h.svc.SaveChannel(payload); err != nil {
+	if err := h.svc.SaveChannel(idUser, payload); err != nil {
 		return err
 	}
 
@@ -290,6 +871,36 @@ func (h *HTTP) updateChannelHandler(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+//
+// --- ROTATE CHANNEL SECRET ---
+//
+func (h *HTTP) rotateChannelSecretHandler(c echo.Context) error {
+
+	// get user id
+	idUser, _, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// get channel id from path
+	idChannelStr := c.Param("channel-id")
+	idChannel, errConv := strconv.Atoi(idChannelStr)
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
+	}
+
+	secret, errRotate := h.svc.RotateChannelSecret(idChannel, idUser)
+	if errRotate != nil {
+		return errRotate
+	}
+
+	type response struct {
+		SigningSecret string `json:"signing_secret"`
+	}
+
+	return c.JSON(http.StatusOK, response{SigningSecret: secret})
+}
+
 //
 // --- private methods ---
 //
@@ -312,28 +923,390 @@ func (h *HTTP) getUserID(c echo.Context) (id int, email string, err error) {
 	return
 }
 
-// validate create job fields
-func (h *HTTP) validateCreateJobInput(j *model.Job) (fields string) {
+// get job ID from the path; it must be a valid UUID
+func (h *HTTP) getJobID(c echo.Context) (idJob string, err error) {
+	idJob = c.Param("job-id")
+	if _, errParse := uuid.Parse(idJob); errParse != nil {
+		err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, ""))
+	}
+	return
+}
+
+// register a ping of the indicated kind for the job in the path
+func (h *HTTP) registerPing(c echo.Context, kind string) error {
+
+	// keys with another format don't exist
+	key := c.Param("key")
+	if !pingkey.Valid(key) {
+		return echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+	}
+
+	ping := &model.JobPing{
+		Kind:      kind,
+		SourceIP:  c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Signature: c.Request().Header.Get(webhook.SignatureHeader),
+		Method:    c.Request().Method,
+		URI:       c.Request().RequestURI,
+	}
+
+	if exitCodeStr := c.QueryParam("exit_code"); exitCodeStr != "" {
+		exitCode, errConv := strconv.Atoi(exitCodeStr)
+		if errConv != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, "exit_code: must be an integer", "exit_code"))
+		}
+		ping.ExitCode = &exitCode
+	}
+
+	// the body contains the output of the job; it's read in full, up to a hard
+	// limit, so its signature can be checked, and truncated when it's stored
+	if req := c.Request(); req.Method == http.MethodPost && req.Body != nil {
+		body, errRead := ioutil.ReadAll(io.LimitReader(req.Body, MaxPingRequestBodySize))
+		if errRead != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMapWithFields(exception.CodeInvalidFields, "body: "+errRead.Error(), "body"))
+		}
+		if len(body) > 0 {
+			output := string(body)
+			ping.Body = &output
+		}
+	}
+
+	var err error
+	if slug := c.Param("slug"); slug != "" {
+		err = h.svc.RegisterPingBySlug(key, slug, ping)
+	} else {
+		err = h.svc.RegisterPingByKey(key, ping)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// get the page size from the query string
+func (h *HTTP) getPageSize(c echo.Context) (pageSize int, err error) {
+	pageSize = DefaultPageSize
+	if pageSizeStr := c.QueryParam("page_size"); pageSizeStr != "" {
+		var errConv error
+		if pageSize, errConv = strconv.Atoi(pageSizeStr); errConv != nil || pageSize < 1 || pageSize > MaxPageSize {
+			err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPageSize, ""))
+		}
+	}
+	return
+}
+
+// get the ping history filters from the query string; `fields` and `msg`
+// contain the invalid parameters and their details
+func (h *HTTP) getPingFilter(c echo.Context) (filter model.PingFilter, fields, msg string) {
 	invalidFields := []string{}
+	details := []string{}
 
-	// for cons, we need a con expression
-	if j.JobType == model.JobTypeCron {
-		if j.CronExpression == nil {
-			// TODO: validate cron expression too
-			invalidFields = append(invalidFields, "cron_expression")
+	for _, name := range []string{"from", "to"} {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalidFields = append(invalidFields, name)
+			details = append(details, name+": must be a RFC 3339 date")
+			continue
+		}
+
+		if name == "from" {
+			filter.From = &date
+		} else {
+			filter.To = &date
+		}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		invalidFields = append(invalidFields, "to")
+		details = append(details, "to: must be after from")
+	}
+
+	// kinds can be repeated or separated by commas
+	for _, param := range c.QueryParams()["kind"] {
+		for _, kind := range strings.Split(param, ",") {
+			switch kind = strings.ToUpper(strings.TrimSpace(kind)); kind {
+			case model.PingKindStart, model.PingKindSuccess, model.PingKindFail:
+				filter.Kinds = append(filter.Kinds, kind)
+			default:
+				invalidFields = append(invalidFields, "kind")
+				details = append(details, fmt.Sprintf("kind: must be one of %s, %s, %s", model.PingKindStart, model.PingKindSuccess, model.PingKindFail))
+			}
+		}
+	}
+
+	if len(invalidFields) > 0 {
+		fields = strings.Join(invalidFields, ",")
+		msg = strings.Join(details, "; ")
+	}
+
+	return
+}
+
+// returns the CSV columns of a ping
+func pingCSVRecord(p *model.JobPing) []string {
+	record := []string{strconv.Itoa(p.ID), p.DateCreated.UTC().Format(time.RFC3339), p.Kind, p.SourceIP, p.UserAgent, "", "", ""}
+	if p.DurationMs != nil {
+		record[5] = strconv.FormatInt(*p.DurationMs, 10)
+	}
+	if p.ExitCode != nil {
+		record[6] = strconv.Itoa(*p.ExitCode)
+	}
+	if p.Body != nil {
+		record[7] = *p.Body
+	}
+
+	// text sent by the client is not interpreted as formulas by spreadsheets;
+	// numbers, such as negative exit codes, are kept as they are
+	for _, i := range []int{3, 4, 7} {
+		if record[i] != "" && strings.ContainsRune("=+-@\t\r", rune(record[i][0])) {
+			record[i] = "'" + record[i]
+		}
+	}
+	return record
+}
+
+// get the job list filters from the query string; `fields` and `msg` contain
+// the invalid parameters and their details
+func (h *HTTP) getJobFilter(c echo.Context) (filter model.JobFilter, fields, msg string) {
+	invalidFields := []string{}
+	details := []string{}
+
+	filter.Status = c.QueryParam("status")
+	switch filter.Status {
+	case "", model.JobStatusUnknown, model.JobStatusOK, model.JobStatusError:
+	default:
+		invalidFields = append(invalidFields, "status")
+		details = append(details, fmt.Sprintf("status: must be one of %s, %s, %s", model.JobStatusUnknown, model.JobStatusOK, model.JobStatusError))
+	}
+
+	filter.JobType = c.QueryParam("job_type")
+	switch filter.JobType {
+	case "", model.JobTypeCron, model.JobTypeAuto:
+	default:
+		invalidFields = append(invalidFields, "job_type")
+		details = append(details, fmt.Sprintf("job_type: must be one of %s, %s", model.JobTypeCron, model.JobTypeAuto))
+	}
+
+	if activeStr := c.QueryParam("active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			invalidFields = append(invalidFields, "active")
+			details = append(details, "active: must be true or false")
+		} else {
+			filter.Active = &active
 		}
-		if j.CronExpressionTimezone == nil {
-			// TODO: validate timezone
-			invalidFields = append(invalidFields, "cron_expression_timezone")
+	}
+
+	filter.Search = strings.TrimSpace(c.QueryParam("search"))
+	if len(filter.Search) > MaxSearchLength {
+		invalidFields = append(invalidFields, "search")
+		details = append(details, fmt.Sprintf("search: must be at most %d characters", MaxSearchLength))
+	}
+
+	filter.Sort = c.QueryParam("sort")
+	switch filter.Sort {
+	case "":
+		filter.Sort = model.JobSortDateCreated
+	case model.JobSortName, model.JobSortDateCreated, model.JobSortStatus, model.JobSortLastPing:
+	default:
+		invalidFields = append(invalidFields, "sort")
+		details = append(details, fmt.Sprintf("sort: must be one of %s, %s, %s, %s", model.JobSortName, model.JobSortDateCreated, model.JobSortStatus, model.JobSortLastPing))
+	}
+
+	// every `label=key=value` parameter must match
+	for _, l := range c.QueryParams()["label"] {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			invalidFields = append(invalidFields, "label")
+			details = append(details, fmt.Sprintf("label: %q must be key=value", l))
+			continue
+		}
+
+		if filter.Labels == nil {
+			filter.Labels = make(model.JobLabels)
 		}
+		filter.Labels[kv[0]] = kv[1]
+	}
+
+	switch c.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		filter.SortDesc = true
+	default:
+		invalidFields = append(invalidFields, "order")
+		details = append(details, "order: must be asc or desc")
+	}
+
+	if len(invalidFields) > 0 {
+		fields = strings.Join(invalidFields, ",")
+		msg = strings.Join(details, "; ")
+	}
+
+	return
+}
+
+// validate create job fields; `msg` contains the details of every invalid field
+func (h *HTTP) validateCreateJobInput(j *model.Job) (fields, msg string) {
+	invalidFields := []string{}
+	details := []string{}
+
+	// for cons, we need a con expression
+	switch j.JobType {
+	case model.JobTypeCron:
+		invalidFields, details = h.validateCronInput(j)
+	case model.JobTypeAuto:
+	default:
+		invalidFields = append(invalidFields, "job_type")
+		details = append(details, fmt.Sprintf("job_type: must be one of %s, %s", model.JobTypeCron, model.JobTypeAuto))
 	}
 
 	if j.Name == "" {
 		j.Name = DefaultJobName
 	}
 
+	// an empty slug is kept, it removes the slug of the job
+	if j.Slug != nil {
+		slug := strings.TrimSpace(*j.Slug)
+		j.Slug = &slug
+	}
+	if j.Slug != nil && *j.Slug != "" {
+		switch slug := *j.Slug; {
+		case len(slug) > MaxSlugLength:
+			invalidFields = append(invalidFields, "slug")
+			details = append(details, fmt.Sprintf("slug: must be at most %d characters", MaxSlugLength))
+		case !slugRegexp.MatchString(slug):
+			invalidFields = append(invalidFields, "slug")
+			details = append(details, "slug: may only contain lowercase letters and digits, separated by -")
+		case reservedSlugs[slug]:
+			invalidFields = append(invalidFields, "slug")
+			details = append(details, fmt.Sprintf("slug: %s is reserved", slug))
+		}
+	}
+
+	if labelDetails := h.validateLabelsInput(j.Labels); len(labelDetails) > 0 {
+		invalidFields = append(invalidFields, "labels")
+		details = append(details, labelDetails...)
+	}
+
+	if ipDetails := h.validateAllowedIPsInput(j.AllowedIPs); len(ipDetails) > 0 {
+		invalidFields = append(invalidFields, "allowed_ips")
+		details = append(details, ipDetails...)
+	}
+
+	if len(invalidFields) > 0 {
+		fields = strings.Join(invalidFields, ",")
+		msg = strings.Join(details, "; ")
+	}
+
+	return
+}
+
+// validate cron expression and timezone fields
+func (h *HTTP) validateCronInput(j *model.Job) (invalidFields, details []string) {
+
+	if j.CronExpression == nil || *j.CronExpression == "" {
+		invalidFields = append(invalidFields, "cron_expression")
+		details = append(details, "cron_expression: required")
+	} else if _, err := cron.Parse(*j.CronExpression); err != nil {
+		invalidFields = append(invalidFields, "cron_expression")
+		details = append(details, "cron_expression: "+err.Error())
+	}
+
+	if j.CronExpressionTimezone == nil || *j.CronExpressionTimezone == "" {
+		invalidFields = append(invalidFields, "cron_expression_timezone")
+		details = append(details, "cron_expression_timezone: required")
+	} else if *j.CronExpressionTimezone == "Local" {
+		// the server timezone is not a valid option
+		invalidFields = append(invalidFields, "cron_expression_timezone")
+		details = append(details, fmt.Sprintf("cron_expression_timezone: unknown time zone %s", *j.CronExpressionTimezone))
+	} else if _, err := time.LoadLocation(*j.CronExpressionTimezone); err != nil {
+		invalidFields = append(invalidFields, "cron_expression_timezone")
+		details = append(details, "cron_expression_timezone: "+err.Error())
+	}
+
+	// expressions like `0 0 30 2 *` are valid but never run
+	if len(invalidFields) == 0 {
+		if _, err := j.GetNextRun(); err != nil {
+			invalidFields = append(invalidFields, "cron_expression")
+			details = append(details, "cron_expression: "+err.Error())
+		}
+	}
+
+	return
+}
+
+// validate the number and format of the job labels
+func (h *HTTP) validateLabelsInput(labels model.JobLabels) (details []string) {
+
+	if len(labels) > MaxJobLabels {
+		details = append(details, fmt.Sprintf("labels: must be at most %d", MaxJobLabels))
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch {
+		case len(k) > MaxLabelKeyLength:
+			details = append(details, fmt.Sprintf("labels: key %q must be at most %d characters", k, MaxLabelKeyLength))
+		case !labelKeyRegexp.MatchString(k):
+			details = append(details, fmt.Sprintf("labels: key %q may only contain letters, digits, _, -, . and /", k))
+		}
+
+		if len(labels[k]) > MaxLabelValueLength {
+			details = append(details, fmt.Sprintf("labels: value of %q must be at most %d characters", k, MaxLabelValueLength))
+		}
+	}
+
+	return
+}
+
+// validate the IPs allowed to ping a job, which are normalized to CIDR notation
+func (h *HTTP) validateAllowedIPsInput(ips model.IPAllowList) (details []string) {
+
+	if len(ips) > MaxAllowedIPs {
+		details = append(details, fmt.Sprintf("allowed_ips: must be at most %d", MaxAllowedIPs))
+	}
+
+	for i, ip := range ips {
+		cidr, err := model.NormalizeCIDR(strings.TrimSpace(ip))
+		if err != nil {
+			details = append(details, fmt.Sprintf("allowed_ips: %q is not a valid IP or network", ip))
+			continue
+		}
+		ips[i] = cidr
+	}
+
+	return
+}
+
+// validate job alert fields; `msg` contains the details of every invalid field
+func (h *HTTP) validateJobAlertInput(a *model.JobAlert) (fields, msg string) {
+	invalidFields := []string{}
+	details := []string{}
+
+	if a.IDChannel <= 0 {
+		invalidFields = append(invalidFields, "id_channel")
+		details = append(details, "id_channel: required")
+	}
+
+	if a.MinutesBeforeNotification < 0 {
+		invalidFields = append(invalidFields, "minutes_before_notification")
+		details = append(details, "minutes_before_notification: must be zero or positive")
+	}
+
 	if len(invalidFields) > 0 {
 		fields = strings.Join(invalidFields, ",")
+		msg = strings.Join(details, "; ")
 	}
 
 	return
@@ -355,6 +1328,16 @@ func (h *HTTP) validateCreateChannelInput(c *model.Channel) (fields string) {
 		}
 	}
 
+	if c.Type == model.ChannelTypeWebHook && c.Configuration != nil {
+		cwh := c.GetChannelWebHook()
+		if cwh.BaseURL == "" {
+			invalidFields = append(invalidFields, "base_url")
+		}
+		if cwh.PayloadType != model.WebHookPayloadJSON && cwh.PayloadType != model.WebHookPayloadForm {
+			invalidFields = append(invalidFields, "payload_type")
+		}
+	}
+
 	if len(invalidFields) > 0 {
 		fields = strings.Join(invalidFields, ",")
 	}
@@ -0,0 +1,2455 @@
+package transport
+
+import (
+	"cronspy/backend/pkg/api/job"
+	"cronspy/backend/pkg/util/cursor"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/server"
+	"cronspy/backend/pkg/webhook"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testJobID1       = "0b5c4f0e-6a43-4c8e-9a43-6f1d7e1c2a01"
+	testJobID2       = "0b5c4f0e-6a43-4c8e-9a43-6f1d7e1c2a02"
+	testJobIDUnknown = "0b5c4f0e-6a43-4c8e-9a43-6f1d7e1c2aff"
+
+	testPingKey1       = "6f1d7e1c2a014c8e9a430b5c4f0e6a01"
+	testPingKey2       = "6f1d7e1c2a014c8e9a430b5c4f0e6a02"
+	testPingKeyUnknown = "6f1d7e1c2a014c8e9a430b5c4f0e6aff"
+	testUserPingKey1   = "9a430b5c4f0e6a436f1d7e1c2a010001"
+)
+
+// ****************************************************
+//
+// DATABASE MOCK
+//
+// ****************************************************
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func getDBMock(mockData bool) (db *DBMock) {
+	db = &DBMock{}
+
+	if mockData {
+		// load some jobs
+		db.jobs = append(db.jobs, model.Job{ID: testJobID1, IDUser: 1, Name: "Job 1", PingKey: testPingKey1, JobType: model.JobTypeAuto, Active: true, Status: model.JobStatusUnknown, DateCreated: time.Now(), DateUpdated: time.Now()})
+		db.jobs = append(db.jobs, model.Job{ID: testJobID2, IDUser: 2, Name: "Job 2", PingKey: testPingKey2, JobType: model.JobTypeAuto, Active: true, Status: model.JobStatusUnknown, DateCreated: time.Now(), DateUpdated: time.Now()})
+		db.userPingKeys = map[string]int{testUserPingKey1: 1}
+	}
+
+	return
+}
+
+type DBMock struct {
+	jobs     []model.Job
+	pings    []model.JobPing
+	runs     []model.JobRun
+	alerts   []model.JobAlert
+	channels []model.Channel
+
+	userPingKeys map[string]int
+
+	currentPingID    int
+	currentRunID     int
+	currentChannelID int
+	currentAlertID   int
+	jobFilter        model.JobFilter
+	mux              sync.Mutex
+}
+
+func (db *DBMock) Transaction() *gorm.DB {
+	return &gorm.DB{}
+}
+
+func (db *DBMock) GetJobs(idUser int, filter model.JobFilter, pageSize, page int) (jobs []model.Job, p model.Pagination, err error) {
+	db.jobFilter = filter
+	jobs = db.filterJobs(idUser, filter)
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(jobs)}
+
+	if offset := (page - 1) * pageSize; offset < len(jobs) {
+		jobs = jobs[offset:]
+	} else {
+		jobs = nil
+	}
+	if len(jobs) > pageSize {
+		jobs = jobs[:pageSize]
+	}
+	return
+}
+
+func (db *DBMock) GetJobsAfter(idUser int, filter model.JobFilter, after *model.JobCursor, pageSize int) (jobs []model.Job, next *model.JobCursor, err error) {
+	db.jobFilter = filter
+	jobs = db.filterJobs(idUser, filter)
+
+	if after != nil {
+		for i := range jobs {
+			if jobs[i].ID == after.ID {
+				jobs = jobs[i+1:]
+				break
+			}
+		}
+	}
+
+	if len(jobs) > pageSize {
+		jobs = jobs[:pageSize]
+		next = model.NewJobCursor(&jobs[pageSize-1], filter)
+	}
+	return
+}
+
+// returns the jobs of the user that match the filter, sorted by name or date
+func (db *DBMock) filterJobs(idUser int, filter model.JobFilter) (jobs []model.Job) {
+	for _, x := range db.jobs {
+		switch {
+		case x.IDUser != idUser,
+			filter.Status != "" && x.Status != filter.Status,
+			filter.JobType != "" && x.JobType != filter.JobType,
+			filter.Active != nil && x.Active != *filter.Active,
+			!strings.Contains(x.Name, filter.Search),
+			!hasLabels(x, filter.Labels):
+			continue
+		}
+		jobs = append(jobs, x)
+	}
+
+	sort.SliceStable(jobs, func(a, b int) bool {
+		if filter.SortDesc {
+			a, b = b, a
+		}
+		if filter.Sort == model.JobSortName && jobs[a].Name != jobs[b].Name {
+			return jobs[a].Name < jobs[b].Name
+		}
+		if !jobs[a].DateCreated.Equal(jobs[b].DateCreated) {
+			return jobs[a].DateCreated.Before(jobs[b].DateCreated)
+		}
+		return jobs[a].ID < jobs[b].ID
+	})
+	return
+}
+
+// checks if the job has every label
+func hasLabels(job model.Job, labels model.JobLabels) bool {
+	for k, v := range labels {
+		if value, ok := job.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *DBMock) GetJobByID(id string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == id {
+			job = db.jobs[i]
+			return
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetJobByPingKey(pingKey string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].PingKey == pingKey {
+			job = db.jobs[i]
+			return
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetJobBySlug(userPingKey, slug string) (job model.Job, err error) {
+	idUser, ok := db.userPingKeys[userPingKey]
+	for i := range db.jobs {
+		if ok && db.jobs[i].IDUser == idUser && db.jobs[i].Slug != nil && *db.jobs[i].Slug == slug {
+			job = db.jobs[i]
+			return
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) SlugExists(idUser int, slug, idJob string) (exists bool, err error) {
+	for _, x := range db.jobs {
+		if x.IDUser == idUser && x.Slug != nil && *x.Slug == slug && x.ID != idJob {
+			return true, nil
+		}
+	}
+	return
+}
+
+func (db *DBMock) UpdateJobPingKey(idJob, pingKey string) (err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			db.jobs[i].PingKey = pingKey
+		}
+	}
+	return
+}
+
+func (db *DBMock) UpdateJobPingSecret(idJob, secret string) (err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			db.jobs[i].PingSecret = &secret
+		}
+	}
+	return
+}
+
+func (db *DBMock) IncrementRejectedPings(idJob string) (err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			db.jobs[i].RejectedPings++
+		}
+	}
+	return
+}
+
+func (db *DBMock) SaveJob(job *model.Job) (err error) {
+	if db.slugTaken(job) {
+		return exception.ErrDuplicateRecord
+	}
+	db.jobs = append(db.jobs, *job)
+	return
+}
+
+func (db *DBMock) UpdateJob(job *model.Job) (err error) {
+	if db.slugTaken(job) {
+		return exception.ErrDuplicateRecord
+	}
+	job.DateUpdated = time.Now()
+	for i := range db.jobs {
+		if db.jobs[i].ID == job.ID {
+			db.jobs[i] = *job
+		}
+	}
+	return
+}
+
+// checks the unique index of the job slugs of a user
+func (db *DBMock) slugTaken(job *model.Job) bool {
+	for _, x := range db.jobs {
+		if job.Slug != nil && x.Slug != nil && x.ID != job.ID && x.IDUser == job.IDUser && *x.Slug == *job.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DBMock) UpdateJobActive(job *model.Job, active bool) (err error) {
+	job.Active = active
+	job.DateUpdated = time.Now()
+	for i := range db.jobs {
+		if db.jobs[i].ID == job.ID {
+			db.jobs[i].Active = active
+			db.jobs[i].DateUpdated = job.DateUpdated
+		}
+	}
+	return
+}
+
+func (db *DBMock) DeleteJob(idJob string) (err error) {
+	jobs := db.jobs[:0]
+	for _, x := range db.jobs {
+		if x.ID != idJob {
+			jobs = append(jobs, x)
+		}
+	}
+	db.jobs = jobs
+
+	alerts := db.alerts[:0]
+	for _, x := range db.alerts {
+		if x.IDJob != idJob {
+			alerts = append(alerts, x)
+		}
+	}
+	db.alerts = alerts
+
+	pings := db.pings[:0]
+	for _, x := range db.pings {
+		if x.IDJob != idJob {
+			pings = append(pings, x)
+		}
+	}
+	db.pings = pings
+
+	runs := db.runs[:0]
+	for _, x := range db.runs {
+		if x.IDJob != idJob {
+			runs = append(runs, x)
+		}
+	}
+	db.runs = runs
+	return
+}
+
+func (db *DBMock) GetActiveJobs() (jobs []model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].Active {
+			jobs = append(jobs, db.jobs[i])
+		}
+	}
+	return
+}
+
+func (db *DBMock) MarkJobAsLate(idJob string, expected time.Time) (updated bool, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob && db.jobs[i].Status != model.JobStatusError {
+			db.jobs[i].Status = model.JobStatusError
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *DBMock) UpdateJobDetectedInterval(idJob string, minutes int) (err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			db.jobs[i].DetectedIntervalMinutes = &minutes
+		}
+	}
+	return
+}
+
+func (db *DBMock) SavePing(job *model.Job, ping *model.JobPing, run *model.JobRun) (err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if run != nil {
+		if run.ID == 0 {
+			db.currentRunID++
+			run.ID = db.currentRunID
+			db.runs = append(db.runs, *run)
+		} else {
+			for i := range db.runs {
+				if db.runs[i].ID == run.ID {
+					db.runs[i] = *run
+				}
+			}
+		}
+	}
+
+	db.currentPingID++
+	ping.ID = db.currentPingID
+	db.pings = append(db.pings, *ping)
+
+	for i := range db.jobs {
+		if db.jobs[i].ID == job.ID {
+			db.jobs[i].Status = job.Status
+			db.jobs[i].DateLastPing = job.DateLastPing
+			db.jobs[i].LastRunDurationMs = job.LastRunDurationMs
+			db.jobs[i].LastRunOutcome = job.LastRunOutcome
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetRunningJobRun(idJob string) (run model.JobRun, err error) {
+	for i := len(db.runs) - 1; i >= 0; i-- {
+		if db.runs[i].IDJob == idJob && db.runs[i].Outcome == model.RunOutcomeRunning {
+			run = db.runs[i]
+			return
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetJobPingDates(idJob string, kinds []string, limit int) (dates []time.Time, err error) {
+	for i := len(db.pings) - 1; i >= 0 && len(dates) < limit; i-- {
+		if db.pings[i].IDJob != idJob {
+			continue
+		}
+		for _, k := range kinds {
+			if db.pings[i].Kind == k {
+				dates = append(dates, db.pings[i].DateCreated)
+			}
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetJobPings(idJob string, filter model.PingFilter, after *model.PingCursor, pageSize int) (pings []model.JobPing, next *model.PingCursor, err error) {
+	for _, x := range db.pings {
+		switch {
+		case x.IDJob != idJob,
+			filter.From != nil && x.DateCreated.Before(*filter.From),
+			filter.To != nil && !x.DateCreated.Before(*filter.To),
+			len(filter.Kinds) > 0 && !containsString(filter.Kinds, x.Kind):
+			continue
+		}
+		pings = append(pings, x)
+	}
+
+	// newest first
+	sort.SliceStable(pings, func(a, b int) bool {
+		if !pings[a].DateCreated.Equal(pings[b].DateCreated) {
+			return pings[a].DateCreated.After(pings[b].DateCreated)
+		}
+		return pings[a].ID > pings[b].ID
+	})
+
+	if after != nil {
+		for i := range pings {
+			if pings[i].ID == after.ID {
+				pings = pings[i+1:]
+				break
+			}
+		}
+	}
+
+	if len(pings) > pageSize {
+		pings = pings[:pageSize]
+		next = &model.PingCursor{DateCreated: pings[pageSize-1].DateCreated, ID: pings[pageSize-1].ID}
+	}
+	return
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DBMock) GetJobAlerts(idJob string) (alerts []model.JobAlert, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].IDJob == idJob {
+			alerts = append(alerts, db.alerts[i])
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetJobAlert(idAlert int) (alert model.JobAlert, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert {
+			return db.alerts[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) SaveJobAlert(alert *model.JobAlert) (err error) {
+	db.mux.Lock()
+	db.currentAlertID++
+	alert.ID = db.currentAlertID
+	db.mux.Unlock()
+
+	db.alerts = append(db.alerts, *alert)
+	return
+}
+
+func (db *DBMock) UpdateJobAlert(alert *model.JobAlert) (err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == alert.ID {
+			db.alerts[i] = *alert
+		}
+	}
+	return
+}
+
+func (db *DBMock) DeleteJobAlert(idAlert int) (err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert {
+			db.alerts = append(db.alerts[:i], db.alerts[i+1:]...)
+			return
+		}
+	}
+	return
+}
+
+func (db *DBMock) MarkJobAlertNotified(idAlert int, date time.Time) (updated bool, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert && db.alerts[i].DateLastNotified == nil {
+			db.alerts[i].DateLastNotified = &date
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *DBMock) ClearJobAlertNotified(idAlert int) (updated bool, err error) {
+	for i := range db.alerts {
+		if db.alerts[i].ID == idAlert && db.alerts[i].DateLastNotified != nil {
+			db.alerts[i].DateLastNotified = nil
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetChannel(idChannel int, loadChannelConfig bool) (c model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == idChannel {
+			c = db.channels[i]
+			return
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetChannels(idUser int, loadChannelConfig bool) (channels []model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].IDUser == idUser {
+			channels = append(channels, db.channels[i])
+		}
+	}
+	return
+}
+
+func (db *DBMock) SaveChannel(channel *model.Channel) (err error) {
+	db.mux.Lock()
+	db.currentChannelID++
+	channel.ID = db.currentChannelID
+	db.mux.Unlock()
+
+	db.channels = append(db.channels, *channel)
+	return
+}
+
+func (db *DBMock) DeleteChannel(channel *model.Channel) (err error) {
+	return
+}
+
+func (db *DBMock) UpdateChannel(channel *model.Channel) (err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == channel.ID {
+			db.channels[i] = *channel
+		}
+	}
+	return
+}
+
+func (db *DBMock) UpdateChannelSigningSecret(idChannel int, secret string) (err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == idChannel {
+			db.channels[i].Configuration["signing_secret"] = secret
+		}
+	}
+	return
+}
+
+// get HTTP handler
+func getHTTPHandler(e *echo.Echo, mockDB *DBMock) (h HTTP) {
+	logger := log.New()
+	jobService := job.Initialize(nil, mockDB, logger, nil)
+
+	h = HTTP{svc: jobService, jwtSigningKey: "myTestingKey", jwtSigningMethod: jwt.SigningMethodHS512}
+	return
+}
+
+// sets an authenticated user in the context, as the JWT middleware does
+func setUser(c echo.Context, idUser int) {
+	token := jwt.New(jwt.SigningMethodHS512)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["id"] = float64(idUser)
+	claims["email"] = fmt.Sprintf("test.user.%d@cronspy.com", idUser)
+	c.Set("user", token)
+}
+
+func runJSONRequest(mockDB *DBMock, idUser int, method, payload string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	// create server and handler
+	e := echo.New()
+	e.Validator = &server.CustomValidator{V: validator.New()}
+	e.Binder = server.NewBinder()
+	h := getHTTPHandler(e, mockDB)
+
+	// define request
+	req := httptest.NewRequest(method, "/", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setUser(c, idUser)
+
+	// call handler
+	err = handler(h)(c)
+	return
+}
+
+//
+// ============== CREATE JOB ==============
+
+func createJobHandler(h HTTP) echo.HandlerFunc {
+	return h.createJobHandler
+}
+
+func TestCreateJobOK(t *testing.T) {
+	mockDB := getDBMock(false)
+
+	payload := `{"name":"Backup","job_type":"CRON","cron_expression":"30 2 * * *","cron_expression_timezone":"America/New_York"}`
+	rec, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createJobHandler)
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		if assert.Len(t, mockDB.jobs, 1) {
+			assert.Equal(t, 1, mockDB.jobs[0].IDUser)
+			assert.Equal(t, "Backup", mockDB.jobs[0].Name)
+		}
+	}
+}
+
+func TestCreateJobIgnoresRunState(t *testing.T) {
+	mockDB := getDBMock(false)
+
+	payload := `{"name":"Backup","job_type":"AUTO","date_last_ping":"2020-01-21T19:00:00Z","last_run_outcome":"SUCCESS","last_run_duration_ms":1000}`
+	_, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createJobHandler)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, mockDB.jobs, 1) {
+		assert.Nil(t, mockDB.jobs[0].DateLastPing)
+		assert.Nil(t, mockDB.jobs[0].LastRunOutcome)
+		assert.Nil(t, mockDB.jobs[0].LastRunDurationMs)
+	}
+}
+
+func TestCreateJobInvalidCron(t *testing.T) {
+	cases := []struct {
+		name       string
+		payload    string
+		wantFields string
+	}{
+		{
+			name:       "Missing fields",
+			payload:    `{"job_type":"CRON"}`,
+			wantFields: "cron_expression,cron_expression_timezone",
+		},
+		{
+			name:       "Malformed expression",
+			payload:    `{"job_type":"CRON","cron_expression":"61 * * * *","cron_expression_timezone":"UTC"}`,
+			wantFields: "cron_expression",
+		},
+		{
+			name:       "Expression without runs",
+			payload:    `{"job_type":"CRON","cron_expression":"0 0 30 2 *","cron_expression_timezone":"UTC"}`,
+			wantFields: "cron_expression",
+		},
+		{
+			name:       "Unknown timezone",
+			payload:    `{"job_type":"CRON","cron_expression":"@daily","cron_expression_timezone":"Mars/Olympus"}`,
+			wantFields: "cron_expression_timezone",
+		},
+		{
+			name:       "Server timezone",
+			payload:    `{"job_type":"CRON","cron_expression":"@daily","cron_expression_timezone":"Local"}`,
+			wantFields: "cron_expression_timezone",
+		},
+		{
+			name:       "Unknown job type",
+			payload:    `{"job_type":"WEEKLY"}`,
+			wantFields: "job_type",
+		},
+		{
+			name:       "Missing job type",
+			payload:    `{"name":"Backup"}`,
+			wantFields: "job_type",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(false)
+			_, err := runJSONRequest(mockDB, 1, http.MethodPost, tt.payload, createJobHandler)
+
+			// assertions
+			if assert.Error(t, err) {
+				httpErr := err.(*echo.HTTPError)
+				assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+
+				m := httpErr.Message.(map[string]interface{})
+				assert.Equal(t, exception.CodeInvalidFields, m["code"])
+				assert.Equal(t, tt.wantFields, m["fields"])
+				assert.NotEmpty(t, m["message"])
+			}
+			assert.Len(t, mockDB.jobs, 0)
+		})
+	}
+}
+
+//
+// ============== PREVIEW JOB RUNS ==============
+
+func previewJobRunsHandler(h HTTP) echo.HandlerFunc {
+	return h.previewJobRunsHandler
+}
+
+func TestPreviewJobRunsOK(t *testing.T) {
+	payload := `{"cron_expression":"0 9 * * MON-FRI","cron_expression_timezone":"Europe/Madrid","count":3}`
+	rec, err := runJSONRequest(getDBMock(false), 1, http.MethodPost, payload, previewJobRunsHandler)
+
+	// assertions
+	if assert.NoError(t, err) {
+		resp := struct {
+			NextRuns []time.Time `json:"next_runs"`
+		}{}
+
+		if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp)) && assert.Len(t, resp.NextRuns, 3) {
+			assert.True(t, resp.NextRuns[0].After(time.Now()))
+			assert.True(t, resp.NextRuns[1].After(resp.NextRuns[0]))
+
+			loc, _ := time.LoadLocation("Europe/Madrid")
+			assert.Equal(t, 9, resp.NextRuns[0].In(loc).Hour())
+		}
+	}
+}
+
+func TestPreviewJobRunsDefaultCount(t *testing.T) {
+	payload := `{"cron_expression":"@hourly","cron_expression_timezone":"UTC"}`
+	rec, err := runJSONRequest(getDBMock(false), 1, http.MethodPost, payload, previewJobRunsHandler)
+
+	// assertions
+	if assert.NoError(t, err) {
+		resp := struct {
+			NextRuns []time.Time `json:"next_runs"`
+		}{}
+
+		if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp)) {
+			assert.Len(t, resp.NextRuns, DefaultPreviewRuns)
+		}
+	}
+}
+
+func TestPreviewJobRunsInvalidInput(t *testing.T) {
+	payload := `{"cron_expression":"* * *","cron_expression_timezone":"UTC","count":500}`
+	_, err := runJSONRequest(getDBMock(false), 1, http.MethodPost, payload, previewJobRunsHandler)
+
+	// assertions
+	if assert.Error(t, err) {
+		httpErr := err.(*echo.HTTPError)
+		assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+		assert.Equal(t, "cron_expression,count", httpErr.Message.(map[string]interface{})["fields"])
+	}
+}
+
+//
+// ============== PING ==============
+
+func runPing(mockDB *DBMock, method, pingKey, kind string) (rec *httptest.ResponseRecorder, err error) {
+	return runPingWithBody(mockDB, method, pingKey, kind, "", "")
+}
+
+func runPingWithBody(mockDB *DBMock, method, pingKey, kind, query, body string) (rec *httptest.ResponseRecorder, err error) {
+	return runPingRequest(mockDB, newPingRequest(method, query, body), pingKey, kind)
+}
+
+func newPingRequest(method, query, body string) *http.Request {
+	req := httptest.NewRequest(method, "/?"+query, strings.NewReader(body))
+	req.Header.Set("User-Agent", "curl/7.64.1")
+	req.RemoteAddr = "10.0.0.1:51234"
+	return req
+}
+
+func runPingRequest(mockDB *DBMock, req *http.Request, pingKey, kind string) (rec *httptest.ResponseRecorder, err error) {
+	// create server and handler
+	e := echo.New()
+	handler := getHTTPHandler(e, mockDB)
+
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("key")
+	c.SetParamValues(pingKey)
+
+	// call handler
+	switch kind {
+	case model.PingKindStart:
+		err = handler.pingStartHandler(c)
+	case model.PingKindFail:
+		err = handler.pingFailHandler(c)
+	default:
+		err = handler.pingHandler(c)
+	}
+	return
+}
+
+func TestPingOK(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	rec, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		if assert.Len(t, mockDB.pings, 1) {
+			assert.Equal(t, testJobID1, mockDB.pings[0].IDJob)
+			assert.Equal(t, "10.0.0.1", mockDB.pings[0].SourceIP)
+			assert.Equal(t, "curl/7.64.1", mockDB.pings[0].UserAgent)
+		}
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusOK, j.Status)
+		assert.NotNil(t, j.DateLastPing)
+	}
+}
+
+func TestPingPost(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPing(mockDB, http.MethodPost, testPingKey2, model.PingKindSuccess)
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Len(t, mockDB.pings, 1)
+	}
+}
+
+func TestPingUnknownJob(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPing(mockDB, http.MethodGet, testPingKeyUnknown, model.PingKindSuccess)
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+		assert.Len(t, mockDB.pings, 0)
+	}
+}
+
+func TestPingInvalidKey(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	// job IDs are not accepted as ping keys
+	_, err := runPing(mockDB, http.MethodGet, testJobID1, model.PingKindSuccess)
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	}
+}
+
+func TestPingStartAndSuccess(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, errStart := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindStart)
+	time.Sleep(5 * time.Millisecond)
+	_, errSuccess := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+
+	// assertions
+	if assert.NoError(t, errStart) && assert.NoError(t, errSuccess) {
+		if assert.Len(t, mockDB.runs, 1) {
+			assert.Equal(t, model.RunOutcomeSuccess, mockDB.runs[0].Outcome)
+			assert.NotNil(t, mockDB.runs[0].DateFinished)
+			if assert.NotNil(t, mockDB.runs[0].DurationMs) {
+				assert.True(t, *mockDB.runs[0].DurationMs >= 5)
+			}
+		}
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusOK, j.Status)
+		if assert.NotNil(t, j.LastRunOutcome) {
+			assert.Equal(t, model.RunOutcomeSuccess, *j.LastRunOutcome)
+		}
+		assert.NotNil(t, j.LastRunDurationMs)
+	}
+}
+
+func TestPingStartKeepsStatus(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindStart)
+
+	// assertions
+	if assert.NoError(t, err) {
+		if assert.Len(t, mockDB.runs, 1) {
+			assert.Equal(t, model.RunOutcomeRunning, mockDB.runs[0].Outcome)
+		}
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusUnknown, j.Status)
+	}
+}
+
+func TestPingFail(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, errStart := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindStart)
+	_, errFail := runPing(mockDB, http.MethodPost, testPingKey1, model.PingKindFail)
+
+	// assertions
+	if assert.NoError(t, errStart) && assert.NoError(t, errFail) {
+		if assert.Len(t, mockDB.runs, 1) {
+			assert.Equal(t, model.RunOutcomeFail, mockDB.runs[0].Outcome)
+		}
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusError, j.Status)
+	}
+}
+
+func TestPingWithOutput(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPingWithBody(mockDB, http.MethodPost, testPingKey1, model.PingKindSuccess, "exit_code=2", "rsync: connection refused\n")
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, mockDB.pings, 1) {
+		p := mockDB.pings[0]
+		assert.Equal(t, model.PingKindFail, p.Kind)
+		assert.Equal(t, 2, *p.ExitCode)
+		assert.Equal(t, "rsync: connection refused\n", *p.Body)
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusError, j.Status)
+	}
+
+	// the body is truncated
+	_, err = runPingWithBody(mockDB, http.MethodPost, testPingKey1, model.PingKindSuccess, "", strings.Repeat("a", job.DefaultMaxPingBodySize+10))
+	if assert.NoError(t, err) && assert.Len(t, mockDB.pings, 2) {
+		assert.Len(t, *mockDB.pings[1].Body, job.DefaultMaxPingBodySize)
+		assert.Nil(t, mockDB.pings[1].ExitCode)
+	}
+}
+
+func TestPingInvalidExitCode(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPingWithBody(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess, "exit_code=abc", "")
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		assert.Len(t, mockDB.pings, 0)
+	}
+}
+
+func TestPingFailWithoutStart(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	_, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindFail)
+
+	// assertions
+	if assert.NoError(t, err) {
+		if assert.Len(t, mockDB.runs, 1) {
+			assert.Equal(t, model.RunOutcomeFail, mockDB.runs[0].Outcome)
+			assert.Nil(t, mockDB.runs[0].DurationMs)
+		}
+
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, model.JobStatusError, j.Status)
+	}
+}
+
+func TestPingDetectsInterval(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	// not enough samples yet
+	for i := 0; i < job.AutoIntervalMinSamples; i++ {
+		_, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+		assert.NoError(t, err)
+	}
+
+	j, _ := mockDB.GetJobByID(testJobID1)
+	assert.Nil(t, j.DetectedIntervalMinutes)
+
+	// start pings are not samples
+	_, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindStart)
+	assert.NoError(t, err)
+
+	j, _ = mockDB.GetJobByID(testJobID1)
+	assert.Nil(t, j.DetectedIntervalMinutes)
+
+	_, err = runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+
+	// assertions
+	if assert.NoError(t, err) {
+		j, _ = mockDB.GetJobByID(testJobID1)
+		if assert.NotNil(t, j.DetectedIntervalMinutes) {
+			assert.Equal(t, 1, *j.DetectedIntervalMinutes)
+		}
+	}
+}
+
+//
+// ============== PING KEYS AND SLUGS ==============
+
+// sends a ping through the routes of the server
+func servePing(mockDB *DBMock, method, path string) *httptest.ResponseRecorder {
+	e := echo.New()
+	NewHTTP(job.Initialize(nil, mockDB, log.New(), nil), "myTestingKey", jwt.SigningMethodHS512, e)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestPingRoutes(t *testing.T) {
+	slug := "nightly-backup"
+
+	cases := []struct {
+		name   string
+		path   string
+		status int
+		kind   string
+	}{
+		{name: "Job key", path: "/ping/" + testPingKey1, status: http.StatusOK, kind: model.PingKindSuccess},
+		{name: "Job key start", path: "/ping/" + testPingKey1 + "/start", status: http.StatusOK, kind: model.PingKindStart},
+		{name: "Job key fail", path: "/ping/" + testPingKey1 + "/fail", status: http.StatusOK, kind: model.PingKindFail},
+		{name: "Slug", path: "/ping/" + testUserPingKey1 + "/" + slug, status: http.StatusOK, kind: model.PingKindSuccess},
+		{name: "Slug start", path: "/ping/" + testUserPingKey1 + "/" + slug + "/start", status: http.StatusOK, kind: model.PingKindStart},
+		{name: "Slug fail", path: "/ping/" + testUserPingKey1 + "/" + slug + "/fail", status: http.StatusOK, kind: model.PingKindFail},
+		{name: "Unknown job key", path: "/ping/" + testPingKeyUnknown, status: http.StatusNotFound},
+		{name: "Job ID", path: "/ping/" + testJobID1, status: http.StatusNotFound},
+		{name: "Unknown slug", path: "/ping/" + testUserPingKey1 + "/other-job", status: http.StatusNotFound},
+		{name: "Slug with job key", path: "/ping/" + testPingKey1 + "/" + slug, status: http.StatusNotFound},
+		{name: "Slug of other user", path: "/ping/" + testUserPingKey1 + "/job-2", status: http.StatusNotFound},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			mockDB.jobs[0].Slug = &slug
+			other := "job-2"
+			mockDB.jobs[1].Slug = &other
+
+			rec := servePing(mockDB, http.MethodGet, tt.path)
+
+			// assertions
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusOK {
+				assert.Empty(t, mockDB.pings)
+			} else if assert.Len(t, mockDB.pings, 1) {
+				assert.Equal(t, testJobID1, mockDB.pings[0].IDJob)
+				assert.Equal(t, tt.kind, mockDB.pings[0].Kind)
+			}
+		})
+	}
+}
+
+func TestCreateJobGeneratesPingKey(t *testing.T) {
+	mockDB := getDBMock(false)
+
+	payload := `{"name":"Backup","job_type":"AUTO","ping_key":"` + testPingKey1 + `"}`
+	_, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createJobHandler)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, mockDB.jobs, 1) {
+		assert.Len(t, mockDB.jobs[0].PingKey, 32)
+		assert.NotEqual(t, testPingKey1, mockDB.jobs[0].PingKey)
+	}
+}
+
+func rotatePingKeyHandler(h HTTP) echo.HandlerFunc {
+	return h.rotatePingKeyHandler
+}
+
+func TestRotatePingKey(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	rec, err := runJobRequest(mockDB, 1, http.MethodPost, testJobID1, "", rotatePingKeyHandler)
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusOK, rec.Code) {
+		return
+	}
+
+	var res struct {
+		PingKey string `json:"ping_key"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Len(t, res.PingKey, 32)
+	assert.Equal(t, res.PingKey, mockDB.jobs[0].PingKey)
+
+	// the previous key stops working
+	_, err = runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*echo.HTTPError).Code)
+	}
+
+	_, err = runPing(mockDB, http.MethodGet, res.PingKey, model.PingKindSuccess)
+	assert.NoError(t, err)
+}
+
+func TestRotatePingKeyErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		idUser int
+		idJob  string
+		status int
+	}{
+		{name: "Other user", idUser: 2, idJob: testJobID1, status: http.StatusForbidden},
+		{name: "Unknown job", idUser: 1, idJob: testJobIDUnknown, status: http.StatusNotFound},
+		{name: "Invalid job ID", idUser: 1, idJob: "abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			_, err := runJobRequest(mockDB, tt.idUser, http.MethodPost, tt.idJob, "", rotatePingKeyHandler)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+			assert.Equal(t, testPingKey1, mockDB.jobs[0].PingKey)
+		})
+	}
+}
+
+func TestJobSlug(t *testing.T) {
+	mockDB := getDBMock(true)
+	updateHandler := func(h HTTP) echo.HandlerFunc { return h.updateJobHandler }
+
+	_, err := runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","slug":" nightly-backup "}`, updateHandler)
+	if assert.NoError(t, err) && assert.NotNil(t, mockDB.jobs[0].Slug) {
+		assert.Equal(t, "nightly-backup", *mockDB.jobs[0].Slug)
+	}
+
+	// the job keeps its own slug
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup 2","job_type":"AUTO","slug":"nightly-backup"}`, updateHandler)
+	assert.NoError(t, err)
+
+	// other users can use it too
+	_, err = runJobRequest(mockDB, 2, http.MethodPut, testJobID2, `{"name":"Backup","job_type":"AUTO","slug":"nightly-backup"}`, updateHandler)
+	assert.NoError(t, err)
+
+	// it's kept when it's not sent
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup 3","job_type":"AUTO"}`, updateHandler)
+	if assert.NoError(t, err) && assert.NotNil(t, mockDB.jobs[0].Slug) {
+		assert.Equal(t, "nightly-backup", *mockDB.jobs[0].Slug)
+	}
+
+	// an empty slug removes it
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","slug":""}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Nil(t, mockDB.jobs[0].Slug)
+	}
+
+	// new jobs with an empty slug have none
+	_, err = runJSONRequest(mockDB, 1, http.MethodPost, `{"name":"Backup","job_type":"AUTO","slug":" "}`, createJobHandler)
+	if assert.NoError(t, err) {
+		assert.Nil(t, mockDB.jobs[len(mockDB.jobs)-1].Slug)
+	}
+}
+
+func TestJobSlugInvalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		slug   string
+		status int
+		code   string
+	}{
+		{name: "Uppercase", slug: "Nightly", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Spaces", slug: "nightly backup", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Slash", slug: "nightly/backup", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Leading dash", slug: "-nightly", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Double dash", slug: "nightly--backup", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Reserved", slug: "start", status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Too long", slug: strings.Repeat("a", MaxSlugLength+1), status: http.StatusBadRequest, code: exception.CodeInvalidFields},
+		{name: "Used by another job", slug: "used", status: http.StatusConflict, code: exception.CodeSlugExists},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			used := "used"
+			mockDB.jobs = append(mockDB.jobs, model.Job{ID: testJobIDUnknown, IDUser: 1, Name: "Job 3", Slug: &used})
+
+			// on create
+			_, err := runJSONRequest(mockDB, 1, http.MethodPost, `{"job_type":"AUTO","slug":"`+tt.slug+`"}`, createJobHandler)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Message.(map[string]interface{})["code"])
+				assert.Equal(t, "slug", err.(*echo.HTTPError).Message.(map[string]interface{})["fields"])
+			}
+			assert.Len(t, mockDB.jobs, 3)
+
+			// on update
+			_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"job_type":"AUTO","slug":"`+tt.slug+`"}`, func(h HTTP) echo.HandlerFunc { return h.updateJobHandler })
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Message.(map[string]interface{})["code"])
+				assert.Equal(t, "slug", err.(*echo.HTTPError).Message.(map[string]interface{})["fields"])
+			}
+			assert.Nil(t, mockDB.jobs[0].Slug)
+		})
+	}
+}
+
+// slugRaceDBMock misses the jobs with the same slug, as if they were saved
+// after the check, so only the unique index catches them
+type slugRaceDBMock struct {
+	*DBMock
+}
+
+func (db slugRaceDBMock) SlugExists(idUser int, slug, idJob string) (exists bool, err error) {
+	return false, nil
+}
+
+func TestJobSlugDuplicateRecord(t *testing.T) {
+	mockDB := getDBMock(true)
+	used := "used"
+	mockDB.jobs = append(mockDB.jobs, model.Job{ID: testJobIDUnknown, IDUser: 1, Name: "Job 3", Slug: &used})
+
+	e := echo.New()
+	e.Validator = &server.CustomValidator{V: validator.New()}
+	e.Binder = server.NewBinder()
+	h := HTTP{svc: job.Initialize(nil, slugRaceDBMock{mockDB}, log.New(), nil)}
+
+	requests := []struct {
+		method  string
+		idJob   string
+		handler echo.HandlerFunc
+	}{
+		{method: http.MethodPost, handler: h.createJobHandler},
+		{method: http.MethodPut, idJob: testJobID1, handler: h.updateJobHandler},
+	}
+
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, "/", strings.NewReader(`{"name":"Backup","job_type":"AUTO","slug":"used"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		setUser(c, 1)
+		if r.idJob != "" {
+			c.SetParamNames("job-id")
+			c.SetParamValues(r.idJob)
+		}
+
+		err := r.handler(c)
+
+		// assertions
+		if assert.Error(t, err, r.method) {
+			assert.Equal(t, http.StatusConflict, err.(*echo.HTTPError).Code)
+			assert.Equal(t, exception.CodeSlugExists, err.(*echo.HTTPError).Message.(map[string]interface{})["code"])
+		}
+	}
+	assert.Len(t, mockDB.jobs, 3)
+	assert.Nil(t, mockDB.jobs[0].Slug)
+}
+
+//
+// ============== PING PROTECTIONS ==============
+
+func TestPingAllowedIPs(t *testing.T) {
+	mockDB := getDBMock(true)
+	mockDB.jobs[0].AllowedIPs = model.IPAllowList{"10.0.0.0/24"}
+
+	_, err := runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindSuccess)
+	if assert.NoError(t, err) {
+		assert.Len(t, mockDB.pings, 1)
+	}
+
+	// pings from other IPs are rejected and counted, without changing the job
+	mockDB.jobs[0].AllowedIPs = model.IPAllowList{"203.0.113.0/24", "2001:db8::/32"}
+	before, _ := mockDB.GetJobByID(testJobID1)
+
+	_, err = runPing(mockDB, http.MethodGet, testPingKey1, model.PingKindFail)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+
+	j, _ := mockDB.GetJobByID(testJobID1)
+	assert.Len(t, mockDB.pings, 1)
+	assert.Equal(t, 1, j.RejectedPings)
+	assert.Equal(t, before.Status, j.Status)
+	assert.Equal(t, before.DateLastPing, j.DateLastPing)
+}
+
+// returns the signature of a ping sent with the request
+func signPing(secret string, t time.Time, req *http.Request, kind, body string) string {
+	return webhook.Sign(secret, t, job.SignedPingContent(req.Method, req.RequestURI, kind, []byte(body)))
+}
+
+func TestSignedPings(t *testing.T) {
+	secret := "whsec_test"
+	body := "backup done\n"
+
+	signed := func(sign func(req *http.Request) string) *http.Request {
+		req := newPingRequest(http.MethodPost, "", body)
+		if sign != nil {
+			req.Header.Set(webhook.SignatureHeader, sign(req))
+		}
+		return req
+	}
+
+	cases := []struct {
+		name string
+		req  *http.Request
+	}{
+		{name: "Unsigned", req: signed(nil)},
+		{name: "Wrong secret", req: signed(func(req *http.Request) string {
+			return signPing("whsec_other", time.Now(), req, model.PingKindFail, body)
+		})},
+		{name: "Other body", req: signed(func(req *http.Request) string {
+			return signPing(secret, time.Now(), req, model.PingKindFail, "other")
+		})},
+		{name: "Other kind", req: signed(func(req *http.Request) string {
+			return signPing(secret, time.Now(), req, model.PingKindSuccess, body)
+		})},
+		{name: "Other method", req: signed(func(req *http.Request) string {
+			return webhook.Sign(secret, time.Now(), job.SignedPingContent(http.MethodGet, req.RequestURI, model.PingKindFail, []byte(body)))
+		})},
+		{name: "Body only", req: signed(func(req *http.Request) string {
+			return webhook.Sign(secret, time.Now(), []byte(body))
+		})},
+		{name: "Old signature", req: signed(func(req *http.Request) string {
+			return signPing(secret, time.Now().Add(-time.Hour), req, model.PingKindFail, body)
+		})},
+		{name: "Invalid header", req: signed(func(req *http.Request) string { return "abc" })},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			mockDB.jobs[0].SignedPings = boolPtr(true)
+			mockDB.jobs[0].PingSecret = &secret
+			mockDB.jobs[0].Status = model.JobStatusOK
+
+			_, err := runPingRequest(mockDB, tt.req, testPingKey1, model.PingKindFail)
+			if assert.Error(t, err) {
+				assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+			}
+
+			j, _ := mockDB.GetJobByID(testJobID1)
+			assert.Empty(t, mockDB.pings)
+			assert.Equal(t, 1, j.RejectedPings)
+			assert.Equal(t, model.JobStatusOK, j.Status)
+		})
+	}
+
+	// signed pings are accepted
+	mockDB := getDBMock(true)
+	mockDB.jobs[0].SignedPings = boolPtr(true)
+	mockDB.jobs[0].PingSecret = &secret
+
+	req := signed(func(req *http.Request) string {
+		return signPing(secret, time.Now(), req, model.PingKindSuccess, body)
+	})
+	_, err := runPingRequest(mockDB, req, testPingKey1, model.PingKindSuccess)
+	if assert.NoError(t, err) && assert.Len(t, mockDB.pings, 1) {
+		assert.Equal(t, body, *mockDB.pings[0].Body)
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, 0, j.RejectedPings)
+		assert.Equal(t, model.JobStatusOK, j.Status)
+	}
+}
+
+func TestSignedPingsBigBody(t *testing.T) {
+	secret := "whsec_test"
+	body := strings.Repeat("a", job.DefaultMaxPingBodySize+10)
+
+	mockDB := getDBMock(true)
+	mockDB.jobs[0].SignedPings = boolPtr(true)
+	mockDB.jobs[0].PingSecret = &secret
+
+	// the whole body is signed, but only the max body size is stored
+	req := newPingRequest(http.MethodPost, "", body)
+	req.Header.Set(webhook.SignatureHeader, signPing(secret, time.Now(), req, model.PingKindSuccess, body))
+	_, err := runPingRequest(mockDB, req, testPingKey1, model.PingKindSuccess)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, mockDB.pings, 1) {
+		assert.Equal(t, body[:job.DefaultMaxPingBodySize], *mockDB.pings[0].Body)
+		j, _ := mockDB.GetJobByID(testJobID1)
+		assert.Equal(t, 0, j.RejectedPings)
+	}
+}
+
+func TestSignedPingsReplay(t *testing.T) {
+	secret := "whsec_test"
+	body := "backup done"
+	path := "/ping/" + testPingKey1
+
+	// a success ping signed by the job
+	original := httptest.NewRequest(http.MethodPost, path, nil)
+	signature := signPing(secret, time.Now(), original, model.PingKindSuccess, body)
+
+	cases := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "Original", path: path, status: http.StatusOK},
+		{name: "Start", path: path + "/start", status: http.StatusForbidden},
+		{name: "Fail", path: path + "/fail", status: http.StatusForbidden},
+		{name: "Exit code", path: path + "?exit_code=1", status: http.StatusForbidden},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			mockDB.jobs[0].SignedPings = boolPtr(true)
+			mockDB.jobs[0].PingSecret = &secret
+
+			e := echo.New()
+			NewHTTP(job.Initialize(nil, mockDB, log.New(), nil), "myTestingKey", jwt.SigningMethodHS512, e)
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+			req.Header.Set(webhook.SignatureHeader, signature)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			// assertions
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Len(t, mockDB.pings, 1)
+			} else {
+				assert.Empty(t, mockDB.pings)
+			}
+		})
+	}
+}
+
+func TestJobPingProtections(t *testing.T) {
+	mockDB := getDBMock(false)
+
+	payload := `{"name":"Backup","job_type":"AUTO","signed_pings":true,"ping_secret":"whsec_mine","rejected_pings":5,"allowed_ips":["203.0.113.7"," 10.1.2.3/8","2001:db8::1"]}`
+	rec, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createJobHandler)
+	if !assert.NoError(t, err) || !assert.Len(t, mockDB.jobs, 1) {
+		return
+	}
+
+	j := mockDB.jobs[0]
+	assert.Equal(t, model.IPAllowList{"203.0.113.7/32", "10.0.0.0/8", "2001:db8::1/128"}, j.AllowedIPs)
+	assert.True(t, j.UsesSignedPings())
+	assert.Equal(t, 0, j.RejectedPings)
+	if assert.NotNil(t, j.PingSecret) {
+		assert.NotEqual(t, "whsec_mine", *j.PingSecret)
+	}
+	secret := *j.PingSecret
+
+	// the secret is returned when the job is created
+	var created struct {
+		PingSecret string `json:"ping_secret"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, secret, created.PingSecret)
+
+	// allowed IPs are kept when they are not sent, and so is the secret
+	mockDB.jobs[0].ID = testJobID1
+	updateHandler := func(h HTTP) echo.HandlerFunc { return h.updateJobHandler }
+	rec, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","signed_pings":true,"ping_secret":"whsec_mine"}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Len(t, mockDB.jobs[0].AllowedIPs, 3)
+		assert.Equal(t, secret, *mockDB.jobs[0].PingSecret)
+		assert.NotContains(t, rec.Body.String(), "ping_secret")
+	}
+
+	// but not when the job is read
+	rec, err = runJobRequest(mockDB, 1, http.MethodGet, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.getJobHandler })
+	if assert.NoError(t, err) {
+		assert.NotContains(t, rec.Body.String(), "ping_secret")
+		assert.NotContains(t, rec.Body.String(), secret)
+	}
+
+	// signed pings are kept when they are not sent
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Nightly backup","job_type":"AUTO"}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.True(t, mockDB.jobs[0].UsesSignedPings())
+		assert.Equal(t, secret, *mockDB.jobs[0].PingSecret)
+	}
+
+	// an empty list removes them; disabling signed pings removes the secret
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","allowed_ips":[],"signed_pings":false}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Empty(t, mockDB.jobs[0].AllowedIPs)
+		assert.False(t, mockDB.jobs[0].UsesSignedPings())
+		assert.Nil(t, mockDB.jobs[0].PingSecret)
+	}
+}
+
+func TestJobAllowedIPsInvalid(t *testing.T) {
+	tooMany := make([]string, MaxAllowedIPs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`"10.0.0.%d"`, i)
+	}
+
+	cases := []struct {
+		name string
+		ips  string
+	}{
+		{name: "Too many", ips: "[" + strings.Join(tooMany, ",") + "]"},
+		{name: "Host name", ips: `["my-server"]`},
+		{name: "Invalid mask", ips: `["10.0.0.0/33"]`},
+		{name: "Empty", ips: `[""]`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(false)
+			_, err := runJSONRequest(mockDB, 1, http.MethodPost, `{"job_type":"AUTO","allowed_ips":`+tt.ips+`}`, createJobHandler)
+			if assert.Error(t, err) {
+				m := err.(*echo.HTTPError).Message.(map[string]interface{})
+				assert.Equal(t, "allowed_ips", m["fields"])
+				assert.NotEmpty(t, m["message"])
+			}
+			assert.Len(t, mockDB.jobs, 0)
+		})
+	}
+}
+
+func rotatePingSecretHandler(h HTTP) echo.HandlerFunc {
+	return h.rotatePingSecretHandler
+}
+
+func TestRotatePingSecret(t *testing.T) {
+	mockDB := getDBMock(true)
+	secret := "whsec_test"
+	mockDB.jobs[0].SignedPings = boolPtr(true)
+	mockDB.jobs[0].PingSecret = &secret
+
+	// other users can't rotate it
+	_, err := runJobRequest(mockDB, 2, http.MethodPost, testJobID1, "", rotatePingSecretHandler)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+
+	rec, err := runJobRequest(mockDB, 1, http.MethodPost, testJobID1, "", rotatePingSecretHandler)
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var res struct {
+			PingSecret string `json:"ping_secret"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.True(t, strings.HasPrefix(res.PingSecret, webhook.SecretPrefix))
+		assert.NotEqual(t, secret, res.PingSecret)
+		assert.Equal(t, res.PingSecret, *mockDB.jobs[0].PingSecret)
+	}
+
+	// jobs without signed pings have no secret
+	mockDB.jobs[0].SignedPings = boolPtr(false)
+	_, err = runJobRequest(mockDB, 1, http.MethodPost, testJobID1, "", rotatePingSecretHandler)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		assert.Equal(t, "signed_pings", err.(*echo.HTTPError).Message.(map[string]interface{})["fields"])
+	}
+}
+
+//
+// ============== CREATE CHANNEL ==============
+
+func createChannelHandler(h HTTP) echo.HandlerFunc {
+	return h.createChannelHandler
+}
+
+func TestCreateWebHookChannel(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		fields  string
+	}{
+		{name: "JSON", payload: `{"type":"WEB_HOOK","name":"Hook","configuration":{"base_url":"https://example.com/hook","payload_type":"JSON"}}`},
+		{name: "Form with basic auth", payload: `{"type":"WEB_HOOK","name":"Hook","configuration":{"base_url":"https://example.com/hook","payload_type":"FORM","basic_auth_username":"user","basic_auth_password":"pass"}}`},
+		{name: "Missing URL", payload: `{"type":"WEB_HOOK","name":"Hook","configuration":{"payload_type":"JSON"}}`, fields: "base_url"},
+		{name: "Invalid payload type", payload: `{"type":"WEB_HOOK","name":"Hook","configuration":{"base_url":"https://example.com/hook","payload_type":"XML"}}`, fields: "payload_type"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(false)
+			rec, err := runJSONRequest(mockDB, 1, http.MethodPost, tt.payload, createChannelHandler)
+
+			if tt.fields == "" {
+				if assert.NoError(t, err) && assert.Equal(t, http.StatusCreated, rec.Code) && assert.Len(t, mockDB.channels, 1) {
+					cwh := mockDB.channels[0].GetChannelWebHook()
+					assert.Equal(t, "https://example.com/hook", cwh.BaseURL)
+				}
+				return
+			}
+
+			if assert.Error(t, err) {
+				he := err.(*echo.HTTPError)
+				assert.Equal(t, http.StatusBadRequest, he.Code)
+				assert.Equal(t, tt.fields, he.Message.(map[string]interface{})["fields"])
+			}
+		})
+	}
+}
+
+//
+// ============== ROTATE CHANNEL SECRET ==============
+
+func runChannelRequest(mockDB *DBMock, idUser int, method, idChannel, payload string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	// create server and handler
+	e := echo.New()
+	e.Validator = &server.CustomValidator{V: validator.New()}
+	e.Binder = server.NewBinder()
+	h := getHTTPHandler(e, mockDB)
+
+	// define request
+	req := httptest.NewRequest(method, "/", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("channel-id")
+	c.SetParamValues(idChannel)
+	setUser(c, idUser)
+
+	// call handler
+	err = handler(h)(c)
+	return
+}
+
+func createWebHookChannel(t *testing.T, mockDB *DBMock) (secret string) {
+	payload := `{"type":"WEB_HOOK","name":"Hook","configuration":{"base_url":"https://example.com/hook","payload_type":"JSON","signing_secret":"mine"}}`
+	_, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createChannelHandler)
+	if assert.NoError(t, err) {
+		if s := mockDB.channels[0].GetChannelWebHook().SigningSecret; assert.NotNil(t, s) {
+			secret = *s
+		}
+	}
+	return
+}
+
+func TestCreateWebHookChannelGeneratesSecret(t *testing.T) {
+	mockDB := getDBMock(false)
+	secret := createWebHookChannel(t, mockDB)
+
+	// assertions
+	assert.NotEqual(t, "mine", secret)
+	assert.True(t, strings.HasPrefix(secret, webhook.SecretPrefix))
+}
+
+func TestUpdateWebHookChannelKeepsSecret(t *testing.T) {
+	mockDB := getDBMock(false)
+	secret := createWebHookChannel(t, mockDB)
+
+	payload := `{"name":"Renamed","configuration":{"base_url":"https://example.com/new","payload_type":"FORM","signing_secret":"mine"}}`
+	_, err := runChannelRequest(mockDB, 1, http.MethodPut, "1", payload, func(h HTTP) echo.HandlerFunc { return h.updateChannelHandler })
+
+	// assertions
+	if assert.NoError(t, err) {
+		cwh := mockDB.channels[0].GetChannelWebHook()
+		assert.Equal(t, "https://example.com/new", cwh.BaseURL)
+		if assert.NotNil(t, cwh.SigningSecret) {
+			assert.Equal(t, secret, *cwh.SigningSecret)
+		}
+	}
+}
+
+func rotateChannelSecretHandler(h HTTP) echo.HandlerFunc {
+	return h.rotateChannelSecretHandler
+}
+
+func TestRotateChannelSecret(t *testing.T) {
+	mockDB := getDBMock(false)
+	secret := createWebHookChannel(t, mockDB)
+
+	rec, err := runChannelRequest(mockDB, 1, http.MethodPost, "1", "", rotateChannelSecretHandler)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var resp struct {
+			SigningSecret string `json:"signing_secret"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+
+		assert.NotEqual(t, secret, resp.SigningSecret)
+		assert.Equal(t, resp.SigningSecret, *mockDB.channels[0].GetChannelWebHook().SigningSecret)
+	}
+}
+
+func TestRotateChannelSecretErrors(t *testing.T) {
+	mockDB := getDBMock(false)
+	createWebHookChannel(t, mockDB)
+	mockDB.SaveChannel(&model.Channel{IDUser: 1, Type: model.ChannelTypeSlack, Name: "Slack"})
+
+	cases := []struct {
+		name      string
+		idUser    int
+		idChannel string
+		status    int
+	}{
+		{name: "Other user", idUser: 2, idChannel: "1", status: http.StatusForbidden},
+		{name: "Unknown channel", idUser: 1, idChannel: "99", status: http.StatusNotFound},
+		{name: "Invalid ID", idUser: 1, idChannel: "abc", status: http.StatusBadRequest},
+		{name: "Not a web hook", idUser: 1, idChannel: "2", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runChannelRequest(mockDB, tt.idUser, http.MethodPost, tt.idChannel, "", rotateChannelSecretHandler)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+		})
+	}
+}
+
+//
+// ============== JOB ALERTS ==============
+
+func runJobAlertRequest(mockDB *DBMock, idUser int, method, idJob, idAlert, payload string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	// create server and handler
+	e := echo.New()
+	e.Validator = &server.CustomValidator{V: validator.New()}
+	e.Binder = server.NewBinder()
+	h := getHTTPHandler(e, mockDB)
+
+	// define request
+	req := httptest.NewRequest(method, "/", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("job-id", "alert-id")
+	c.SetParamValues(idJob, idAlert)
+	setUser(c, idUser)
+
+	// call handler
+	err = handler(h)(c)
+	return
+}
+
+// returns a mock with jobs, a channel for each user and an alert for the first job
+func getJobAlertsDBMock() *DBMock {
+	mockDB := getDBMock(true)
+	mockDB.SaveChannel(&model.Channel{IDUser: 1, Type: model.ChannelTypeSlack, Name: "Slack"})
+	mockDB.SaveChannel(&model.Channel{IDUser: 2, Type: model.ChannelTypeSlack, Name: "Other user"})
+	mockDB.SaveJobAlert(&model.JobAlert{IDJob: testJobID1, IDChannel: 1, MinutesBeforeNotification: 5})
+	return mockDB
+}
+
+func TestCreateJobAlert(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+
+	payload := `{"id_channel":1,"minutes_before_notification":10,"target":"ops","id_job":"other"}`
+	rec, err := runJobAlertRequest(mockDB, 1, http.MethodPost, testJobID1, "", payload, func(h HTTP) echo.HandlerFunc { return h.createJobAlertHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusCreated, rec.Code) && assert.Len(t, mockDB.alerts, 2) {
+		a := mockDB.alerts[1]
+		assert.Equal(t, 2, a.ID)
+		assert.Equal(t, testJobID1, a.IDJob)
+		assert.Equal(t, 10, a.MinutesBeforeNotification)
+		assert.Equal(t, "ops", a.Target)
+	}
+}
+
+func TestCreateJobAlertErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		idUser  int
+		idJob   string
+		payload string
+		status  int
+	}{
+		{name: "Other user job", idUser: 1, idJob: testJobID2, payload: `{"id_channel":1}`, status: http.StatusForbidden},
+		{name: "Unknown job", idUser: 1, idJob: testJobIDUnknown, payload: `{"id_channel":1}`, status: http.StatusNotFound},
+		{name: "Invalid job ID", idUser: 1, idJob: "abc", payload: `{"id_channel":1}`, status: http.StatusBadRequest},
+		{name: "Other user channel", idUser: 1, idJob: testJobID1, payload: `{"id_channel":2}`, status: http.StatusBadRequest},
+		{name: "Unknown channel", idUser: 1, idJob: testJobID1, payload: `{"id_channel":99}`, status: http.StatusBadRequest},
+		{name: "Without channel", idUser: 1, idJob: testJobID1, payload: `{}`, status: http.StatusBadRequest},
+		{name: "Negative minutes", idUser: 1, idJob: testJobID1, payload: `{"id_channel":1,"minutes_before_notification":-1}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getJobAlertsDBMock()
+			_, err := runJobAlertRequest(mockDB, tt.idUser, http.MethodPost, tt.idJob, "", tt.payload, func(h HTTP) echo.HandlerFunc { return h.createJobAlertHandler })
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+			assert.Len(t, mockDB.alerts, 1)
+		})
+	}
+}
+
+func TestGetJobAlerts(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+
+	rec, err := runJobAlertRequest(mockDB, 1, http.MethodGet, testJobID1, "", "", func(h HTTP) echo.HandlerFunc { return h.getJobAlertsHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var resp struct {
+			Alerts []model.JobAlert `json:"alerts"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if assert.Len(t, resp.Alerts, 1) {
+			assert.Equal(t, 5, resp.Alerts[0].MinutesBeforeNotification)
+		}
+	}
+
+	// other users can't see them
+	_, err = runJobAlertRequest(mockDB, 2, http.MethodGet, testJobID1, "", "", func(h HTTP) echo.HandlerFunc { return h.getJobAlertsHandler })
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+}
+
+func TestGetJobWithAlerts(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+
+	rec, err := runJobAlertRequest(mockDB, 1, http.MethodGet, testJobID1, "", "", func(h HTTP) echo.HandlerFunc { return h.getJobHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var job model.Job
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		if assert.Len(t, job.Alerts, 1) {
+			assert.Equal(t, 1, job.Alerts[0].IDChannel)
+		}
+	}
+}
+
+func TestUpdateJobAlert(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+	now := time.Now()
+	mockDB.alerts[0].DateLastNotified = &now
+
+	payload := `{"id_channel":1,"minutes_before_notification":30,"target":"db"}`
+	rec, err := runJobAlertRequest(mockDB, 1, http.MethodPut, testJobID1, "1", payload, func(h HTTP) echo.HandlerFunc { return h.updateJobAlertHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		a := mockDB.alerts[0]
+		assert.Equal(t, 30, a.MinutesBeforeNotification)
+		assert.Equal(t, "db", a.Target)
+		assert.Equal(t, testJobID1, a.IDJob)
+		assert.NotNil(t, a.DateLastNotified)
+	}
+}
+
+func TestUpdateJobAlertErrors(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+	mockDB.SaveJobAlert(&model.JobAlert{IDJob: testJobID2, IDChannel: 2})
+
+	cases := []struct {
+		name    string
+		idUser  int
+		idJob   string
+		idAlert string
+		payload string
+		status  int
+	}{
+		{name: "Other user", idUser: 2, idJob: testJobID1, idAlert: "1", payload: `{"id_channel":2}`, status: http.StatusForbidden},
+		{name: "Alert of another job", idUser: 1, idJob: testJobID1, idAlert: "2", payload: `{"id_channel":1}`, status: http.StatusNotFound},
+		{name: "Unknown alert", idUser: 1, idJob: testJobID1, idAlert: "99", payload: `{"id_channel":1}`, status: http.StatusNotFound},
+		{name: "Invalid alert ID", idUser: 1, idJob: testJobID1, idAlert: "abc", payload: `{"id_channel":1}`, status: http.StatusBadRequest},
+		{name: "Other user channel", idUser: 1, idJob: testJobID1, idAlert: "1", payload: `{"id_channel":2}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runJobAlertRequest(mockDB, tt.idUser, http.MethodPut, tt.idJob, tt.idAlert, tt.payload, func(h HTTP) echo.HandlerFunc { return h.updateJobAlertHandler })
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+		})
+	}
+
+	assert.Equal(t, 1, mockDB.alerts[0].IDChannel)
+}
+
+func TestDeleteJobAlert(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+
+	// other users can't delete it
+	_, err := runJobAlertRequest(mockDB, 2, http.MethodDelete, testJobID1, "1", "", func(h HTTP) echo.HandlerFunc { return h.deleteJobAlertHandler })
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+
+	rec, err := runJobAlertRequest(mockDB, 1, http.MethodDelete, testJobID1, "1", "", func(h HTTP) echo.HandlerFunc { return h.deleteJobAlertHandler })
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, mockDB.alerts)
+	}
+}
+
+//
+// ============== UPDATE, DELETE, PAUSE AND RESUME JOBS ==============
+
+func runJobRequest(mockDB *DBMock, idUser int, method, idJob, payload string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	return runJobAlertRequest(mockDB, idUser, method, idJob, "", payload, handler)
+}
+
+func TestUpdateJob(t *testing.T) {
+	mockDB := getDBMock(true)
+
+	payload := `{"name":"Backup","job_type":"CRON","cron_expression":"30 2 * * *","cron_expression_timezone":"Europe/Madrid","id_user":2,"status":"OK"}`
+	rec, err := runJobRequest(mockDB, 1, http.MethodPut, testJobID1, payload, func(h HTTP) echo.HandlerFunc { return h.updateJobHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		j := mockDB.jobs[0]
+		assert.Equal(t, "Backup", j.Name)
+		assert.Equal(t, model.JobTypeCron, j.JobType)
+		assert.Equal(t, "30 2 * * *", *j.CronExpression)
+		assert.Equal(t, "Europe/Madrid", *j.CronExpressionTimezone)
+
+		// other fields can't be changed
+		assert.Equal(t, 1, j.IDUser)
+		assert.Equal(t, model.JobStatusUnknown, j.Status)
+	}
+}
+
+func TestJobLabels(t *testing.T) {
+	mockDB := getDBMock(false)
+
+	payload := `{"name":"Backup","job_type":"AUTO","labels":{"env":"prod","team":"ops"}}`
+	_, err := runJSONRequest(mockDB, 1, http.MethodPost, payload, createJobHandler)
+	if !assert.NoError(t, err) || !assert.Len(t, mockDB.jobs, 1) {
+		return
+	}
+	assert.Equal(t, model.JobLabels{"env": "prod", "team": "ops"}, mockDB.jobs[0].Labels)
+
+	// labels are kept when they are not sent
+	mockDB.jobs[0].ID = testJobID1
+	updateHandler := func(h HTTP) echo.HandlerFunc { return h.updateJobHandler }
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO"}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.JobLabels{"env": "prod", "team": "ops"}, mockDB.jobs[0].Labels)
+	}
+
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","labels":{"env":"dev"}}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.JobLabels{"env": "dev"}, mockDB.jobs[0].Labels)
+	}
+
+	// an empty object removes them
+	_, err = runJobRequest(mockDB, 1, http.MethodPut, testJobID1, `{"name":"Backup","job_type":"AUTO","labels":{}}`, updateHandler)
+	if assert.NoError(t, err) {
+		assert.Empty(t, mockDB.jobs[0].Labels)
+	}
+}
+
+func TestJobLabelsInvalid(t *testing.T) {
+	tooMany := make([]string, MaxJobLabels+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`"k%d":"v"`, i)
+	}
+
+	cases := []struct {
+		name   string
+		labels string
+	}{
+		{name: "Too many", labels: "{" + strings.Join(tooMany, ",") + "}"},
+		{name: "Empty key", labels: `{"":"prod"}`},
+		{name: "Invalid key", labels: `{"my env":"prod"}`},
+		{name: "Long key", labels: `{"` + strings.Repeat("k", MaxLabelKeyLength+1) + `":"prod"}`},
+		{name: "Long value", labels: `{"env":"` + strings.Repeat("v", MaxLabelValueLength+1) + `"}`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(false)
+			_, err := runJSONRequest(mockDB, 1, http.MethodPost, `{"job_type":"AUTO","labels":`+tt.labels+`}`, createJobHandler)
+			if assert.Error(t, err) {
+				m := err.(*echo.HTTPError).Message.(map[string]interface{})
+				assert.Equal(t, "labels", m["fields"])
+				assert.NotEmpty(t, m["message"])
+			}
+			assert.Len(t, mockDB.jobs, 0)
+		})
+	}
+}
+
+func TestUpdateJobErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		idUser  int
+		idJob   string
+		payload string
+		status  int
+	}{
+		{name: "Other user", idUser: 2, idJob: testJobID1, payload: `{"job_type":"AUTO"}`, status: http.StatusForbidden},
+		{name: "Unknown job", idUser: 1, idJob: testJobIDUnknown, payload: `{"job_type":"AUTO"}`, status: http.StatusNotFound},
+		{name: "Invalid job ID", idUser: 1, idJob: "abc", payload: `{"job_type":"AUTO"}`, status: http.StatusBadRequest},
+		{name: "Invalid cron", idUser: 1, idJob: testJobID1, payload: `{"job_type":"CRON","cron_expression":"61 * * * *","cron_expression_timezone":"UTC"}`, status: http.StatusBadRequest},
+		{name: "Invalid job type", idUser: 1, idJob: testJobID1, payload: `{"job_type":"cron"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			_, err := runJobRequest(mockDB, tt.idUser, http.MethodPut, tt.idJob, tt.payload, func(h HTTP) echo.HandlerFunc { return h.updateJobHandler })
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+			assert.Equal(t, "Job 1", mockDB.jobs[0].Name)
+		})
+	}
+}
+
+func TestDeleteJob(t *testing.T) {
+	mockDB := getJobAlertsDBMock()
+	mockDB.pings = append(mockDB.pings, model.JobPing{IDJob: testJobID1}, model.JobPing{IDJob: testJobID2})
+	mockDB.runs = append(mockDB.runs, model.JobRun{IDJob: testJobID1})
+
+	// other users can't delete it
+	_, err := runJobRequest(mockDB, 2, http.MethodDelete, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.deleteJobHandler })
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+
+	rec, err := runJobRequest(mockDB, 1, http.MethodDelete, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.deleteJobHandler })
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		if assert.Len(t, mockDB.jobs, 1) {
+			assert.Equal(t, testJobID2, mockDB.jobs[0].ID)
+		}
+		assert.Empty(t, mockDB.alerts)
+		assert.Empty(t, mockDB.runs)
+		assert.Len(t, mockDB.pings, 1)
+	}
+}
+
+func TestPauseAndResumeJob(t *testing.T) {
+	mockDB := getDBMock(true)
+	updated := mockDB.jobs[0].DateUpdated
+
+	rec, err := runJobRequest(mockDB, 1, http.MethodPost, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.pauseJobHandler })
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var j model.Job
+		json.Unmarshal(rec.Body.Bytes(), &j)
+		assert.False(t, j.Active)
+		assert.False(t, mockDB.jobs[0].Active)
+	}
+
+	// paused jobs are not evaluated
+	active, _ := mockDB.GetActiveJobs()
+	assert.Len(t, active, 1)
+
+	rec, err = runJobRequest(mockDB, 1, http.MethodPost, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.resumeJobHandler })
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.True(t, mockDB.jobs[0].Active)
+		assert.True(t, mockDB.jobs[0].DateUpdated.After(updated))
+	}
+
+	// other users can't pause it
+	_, err = runJobRequest(mockDB, 2, http.MethodPost, testJobID1, "", func(h HTTP) echo.HandlerFunc { return h.pauseJobHandler })
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, err.(*echo.HTTPError).Code)
+	}
+	assert.True(t, mockDB.jobs[0].Active)
+}
+
+//
+// ============== LIST JOBS ==============
+
+func runListJobsRequest(mockDB *DBMock, query string) (rec *httptest.ResponseRecorder, err error) {
+	e := echo.New()
+	h := getHTTPHandler(e, mockDB)
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setUser(c, 1)
+
+	err = h.userJobsHandler(c)
+	return
+}
+
+// returns the names of the jobs in the response
+func getJobNames(t *testing.T, rec *httptest.ResponseRecorder) (names []string) {
+	var resp struct {
+		Jobs []model.Job `json:"jobs"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	for _, j := range resp.Jobs {
+		names = append(names, j.Name)
+	}
+	return
+}
+
+func TestListJobsFilters(t *testing.T) {
+	now := time.Now()
+	mockDB := getDBMock(false)
+	mockDB.jobs = []model.Job{
+		{ID: "1", IDUser: 1, Name: "Nightly backup", JobType: model.JobTypeCron, Active: true, Status: model.JobStatusOK, DateCreated: now.Add(-3 * time.Hour)},
+		{ID: "2", IDUser: 1, Name: "Send reports", JobType: model.JobTypeAuto, Active: true, Status: model.JobStatusError, DateCreated: now.Add(-2 * time.Hour)},
+		{ID: "3", IDUser: 1, Name: "Weekly backup", JobType: model.JobTypeCron, Active: false, Status: model.JobStatusError, DateCreated: now.Add(-time.Hour)},
+		{ID: "4", IDUser: 2, Name: "Other backup", JobType: model.JobTypeCron, Active: true, Status: model.JobStatusError, DateCreated: now, Labels: model.JobLabels{"env": "prod"}},
+	}
+	mockDB.jobs[0].Labels = model.JobLabels{"env": "prod", "team": "ops"}
+	mockDB.jobs[1].Labels = model.JobLabels{"env": "dev", "team": "ops"}
+
+	cases := []struct {
+		query string
+		names []string
+	}{
+		{query: "", names: []string{"Nightly backup", "Send reports", "Weekly backup"}},
+		{query: "status=ERROR", names: []string{"Send reports", "Weekly backup"}},
+		{query: "job_type=CRON&active=true", names: []string{"Nightly backup"}},
+		{query: "active=false", names: []string{"Weekly backup"}},
+		{query: "search=backup", names: []string{"Nightly backup", "Weekly backup"}},
+		{query: "sort=name&order=desc", names: []string{"Weekly backup", "Send reports", "Nightly backup"}},
+		{query: "order=desc", names: []string{"Weekly backup", "Send reports", "Nightly backup"}},
+		{query: "label=team=ops", names: []string{"Nightly backup", "Send reports"}},
+		{query: "label=team=ops&label=env=prod", names: []string{"Nightly backup"}},
+		{query: "label=env=staging", names: nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			rec, err := runListJobsRequest(mockDB, tt.query)
+			if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+				assert.Equal(t, tt.names, getJobNames(t, rec))
+			}
+		})
+	}
+
+	// the default sort is sent to the database
+	runListJobsRequest(mockDB, "")
+	assert.Equal(t, model.JobFilter{Sort: model.JobSortDateCreated}, mockDB.jobFilter)
+}
+
+func TestListJobsInvalidFilters(t *testing.T) {
+	cases := []struct {
+		query  string
+		fields string
+	}{
+		{query: "status=LATE", fields: "status"},
+		{query: "job_type=DAILY", fields: "job_type"},
+		{query: "active=maybe", fields: "active"},
+		{query: "search=" + strings.Repeat("a", MaxSearchLength+1), fields: "search"},
+		{query: "sort=id_user", fields: "sort"},
+		{query: "sort=name&order=up", fields: "order"},
+		{query: "status=x&sort=y", fields: "status,sort"},
+		{query: "label=env", fields: "label"},
+		{query: "label==prod", fields: "label"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.fields, func(t *testing.T) {
+			_, err := runListJobsRequest(getDBMock(true), tt.query)
+			if assert.Error(t, err) {
+				he := err.(*echo.HTTPError)
+				assert.Equal(t, http.StatusBadRequest, he.Code)
+				assert.Equal(t, exception.CodeInvalidFields, he.Message.(map[string]interface{})["code"])
+				assert.Equal(t, tt.fields, he.Message.(map[string]interface{})["fields"])
+			}
+		})
+	}
+}
+
+func TestListJobsCursor(t *testing.T) {
+	now := time.Now()
+	mockDB := getDBMock(false)
+	for i := 0; i < 20; i++ {
+		mockDB.jobs = append(mockDB.jobs, model.Job{ID: fmt.Sprintf("job-%02d", i), IDUser: 1, Name: fmt.Sprintf("Job %02d", i), DateCreated: now})
+	}
+
+	// walk every page; an empty cursor opts in to cursors
+	var names []string
+	query := "page_size=6&sort=name&order=desc&cursor="
+	for pages := 1; ; pages++ {
+		rec, err := runListJobsRequest(mockDB, query)
+		if !assert.NoError(t, err) || !assert.Equal(t, http.StatusOK, rec.Code) {
+			return
+		}
+
+		var resp struct {
+			Jobs       []model.Job       `json:"jobs"`
+			Pagination *model.Pagination `json:"pagination"`
+			NextCursor string            `json:"next_cursor"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Nil(t, resp.Pagination)
+
+		for _, j := range resp.Jobs {
+			names = append(names, j.Name)
+		}
+
+		if resp.NextCursor == "" {
+			assert.Equal(t, 4, pages)
+			break
+		}
+		query = "page_size=6&sort=name&order=desc&cursor=" + resp.NextCursor
+	}
+
+	if assert.Len(t, names, 20) {
+		assert.Equal(t, "Job 19", names[0])
+		assert.Equal(t, "Job 00", names[19])
+	}
+}
+
+func TestListJobsDefaultPage(t *testing.T) {
+	mockDB := getDBMock(false)
+	for i := 0; i < 20; i++ {
+		mockDB.jobs = append(mockDB.jobs, model.Job{ID: fmt.Sprintf("job-%02d", i), IDUser: 1, Name: fmt.Sprintf("Job %02d", i)})
+	}
+
+	rec, err := runListJobsRequest(mockDB, "page_size=15")
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusOK, rec.Code) {
+		return
+	}
+
+	var resp struct {
+		Jobs       []model.Job       `json:"jobs"`
+		Pagination *model.Pagination `json:"pagination"`
+		NextCursor string            `json:"next_cursor"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	assert.Len(t, resp.Jobs, 15)
+	assert.Empty(t, resp.NextCursor)
+	if assert.NotNil(t, resp.Pagination) {
+		assert.Equal(t, model.Pagination{Page: 1, PageSize: 15, TotalRows: 20}, *resp.Pagination)
+	}
+}
+
+func TestListJobsLastPartialPage(t *testing.T) {
+	mockDB := getDBMock(false)
+	for i := 0; i < 20; i++ {
+		mockDB.jobs = append(mockDB.jobs, model.Job{ID: fmt.Sprintf("job-%02d", i), IDUser: 1, Name: fmt.Sprintf("Job %02d", i)})
+	}
+
+	rec, err := runListJobsRequest(mockDB, "page=2")
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Len(t, getJobNames(t, rec), 5)
+	}
+}
+
+func TestListJobsInvalidPagination(t *testing.T) {
+	nameCursor, _ := cursor.Encode(model.JobCursor{Sort: model.JobSortName, ID: testJobID1})
+
+	cases := []struct {
+		query string
+		code  string
+	}{
+		{query: "page=0", code: exception.CodeInvalidPage},
+		{query: "page=abc", code: exception.CodeInvalidPage},
+		{query: "page_size=0", code: exception.CodeInvalidPageSize},
+		{query: fmt.Sprintf("page_size=%d", MaxPageSize+1), code: exception.CodeInvalidPageSize},
+		{query: "cursor=abc", code: exception.CodeInvalidCursor},
+		{query: "cursor=" + nameCursor, code: exception.CodeInvalidCursor},
+		{query: "sort=name&order=desc&cursor=" + nameCursor, code: exception.CodeInvalidCursor},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := runListJobsRequest(getDBMock(true), tt.query)
+			if assert.Error(t, err) {
+				he := err.(*echo.HTTPError)
+				assert.Equal(t, http.StatusBadRequest, he.Code)
+				assert.Equal(t, tt.code, he.Message.(map[string]interface{})["code"])
+			}
+		})
+	}
+}
+
+//
+// ============== PING HISTORY ==============
+
+// runs a request against a ping history handler for the first job
+func runJobPingsRequest(mockDB *DBMock, idUser int, idJob, query string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	e := echo.New()
+	h := getHTTPHandler(e, mockDB)
+
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("job-id")
+	c.SetParamValues(idJob)
+	setUser(c, idUser)
+
+	err = handler(h)(c)
+	return
+}
+
+// returns a mock with a start and a success ping every hour since 2020-01-01 00:00 UTC
+func getJobPingsDBMock(hours int) *DBMock {
+	mockDB := getDBMock(true)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < hours; i++ {
+		date := start.Add(time.Duration(i) * time.Hour)
+		duration := int64(1000)
+		mockDB.pings = append(mockDB.pings,
+			model.JobPing{ID: 2*i + 1, IDJob: testJobID1, DateCreated: date, Kind: model.PingKindStart, SourceIP: "10.0.0.1", UserAgent: "curl/7.68.0"},
+			model.JobPing{ID: 2*i + 2, IDJob: testJobID1, DateCreated: date.Add(time.Second), Kind: model.PingKindSuccess, SourceIP: "10.0.0.1", UserAgent: "curl/7.68.0", DurationMs: &duration},
+		)
+	}
+	mockDB.pings = append(mockDB.pings, model.JobPing{ID: 100000, IDJob: testJobID2, DateCreated: start, Kind: model.PingKindSuccess})
+
+	return mockDB
+}
+
+func getJobPingsHandler(h HTTP) echo.HandlerFunc {
+	return h.getJobPingsHandler
+}
+
+func exportJobPingsHandler(h HTTP) echo.HandlerFunc {
+	return h.exportJobPingsHandler
+}
+
+func TestGetJobPings(t *testing.T) {
+	mockDB := getJobPingsDBMock(5)
+
+	cases := []struct {
+		query string
+		ids   []int
+	}{
+		{query: "", ids: []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{query: "kind=success", ids: []int{10, 8, 6, 4, 2}},
+		{query: "kind=START&kind=fail", ids: []int{9, 7, 5, 3, 1}},
+		{query: "kind=start,success&page_size=3", ids: []int{10, 9, 8}},
+		{query: "from=2020-01-01T01:00:00Z&to=2020-01-01T03:00:00Z", ids: []int{6, 5, 4, 3}},
+		{query: "from=2020-01-01T03:00:00%2B01:00", ids: []int{10, 9, 8, 7, 6, 5}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			rec, err := runJobPingsRequest(mockDB, 1, testJobID1, tt.query, getJobPingsHandler)
+			if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+				var resp struct {
+					Pings []model.JobPing `json:"pings"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				ids := []int{}
+				for _, p := range resp.Pings {
+					ids = append(ids, p.ID)
+				}
+				assert.Equal(t, tt.ids, ids)
+			}
+		})
+	}
+}
+
+func TestGetJobPingsCursor(t *testing.T) {
+	mockDB := getJobPingsDBMock(5)
+
+	ids := []int{}
+	query := "kind=success&page_size=2"
+	for pages := 0; ; pages++ {
+		if !assert.True(t, pages < 5, "too many pages") {
+			return
+		}
+
+		rec, err := runJobPingsRequest(mockDB, 1, testJobID1, query, getJobPingsHandler)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var resp struct {
+			Pings      []model.JobPing `json:"pings"`
+			NextCursor string          `json:"next_cursor"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		for _, p := range resp.Pings {
+			ids = append(ids, p.ID)
+		}
+
+		if resp.NextCursor == "" {
+			break
+		}
+		query = "kind=success&page_size=2&cursor=" + resp.NextCursor
+	}
+
+	assert.Equal(t, []int{10, 8, 6, 4, 2}, ids)
+}
+
+func TestGetJobPingsErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		idUser   int
+		idJob    string
+		query    string
+		status   int
+		fields   string
+		listOnly bool
+	}{
+		{name: "Other user", idUser: 2, idJob: testJobID1, status: http.StatusForbidden},
+		{name: "Unknown job", idUser: 1, idJob: testJobIDUnknown, status: http.StatusNotFound},
+		{name: "Invalid job ID", idUser: 1, idJob: "abc", status: http.StatusBadRequest},
+		{name: "Invalid page size", idUser: 1, idJob: testJobID1, query: "page_size=0", status: http.StatusBadRequest, listOnly: true},
+		{name: "Invalid cursor", idUser: 1, idJob: testJobID1, query: "cursor=abc", status: http.StatusBadRequest, listOnly: true},
+		{name: "Invalid dates", idUser: 1, idJob: testJobID1, query: "from=yesterday&to=2020-01-01", status: http.StatusBadRequest, fields: "from,to"},
+		{name: "Empty range", idUser: 1, idJob: testJobID1, query: "from=2020-01-02T00:00:00Z&to=2020-01-01T00:00:00Z", status: http.StatusBadRequest, fields: "to"},
+		{name: "Invalid kind", idUser: 1, idJob: testJobID1, query: "kind=success,late", status: http.StatusBadRequest, fields: "kind"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := []func(h HTTP) echo.HandlerFunc{getJobPingsHandler, exportJobPingsHandler}
+			if tt.listOnly {
+				handlers = handlers[:1]
+			}
+
+			for _, handler := range handlers {
+				_, err := runJobPingsRequest(getJobPingsDBMock(1), tt.idUser, tt.idJob, tt.query, handler)
+				if assert.Error(t, err) {
+					he := err.(*echo.HTTPError)
+					assert.Equal(t, tt.status, he.Code)
+					if tt.fields != "" {
+						assert.Equal(t, tt.fields, he.Message.(map[string]interface{})["fields"])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExportJobPings(t *testing.T) {
+	mockDB := getJobPingsDBMock(PingExportPageSize)
+
+	// values sent by the job are escaped
+	body := "=HYPERLINK(\"http://example.com\")\nline 2"
+	exitCode := 0
+	mockDB.pings[len(mockDB.pings)-2].Body = &body
+	mockDB.pings[len(mockDB.pings)-2].ExitCode = &exitCode
+
+	rec, err := runJobPingsRequest(mockDB, 1, testJobID1, "kind=success", exportJobPingsHandler)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="pings-`+testJobID1+`.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if assert.NoError(t, err) && assert.Len(t, records, PingExportPageSize+1) {
+		assert.Equal(t, []string{"id", "date_created", "kind", "source_ip", "user_agent", "duration_ms", "exit_code", "body"}, records[0])
+		assert.Equal(t, []string{fmt.Sprint(2 * PingExportPageSize), "2020-01-21T19:00:01Z", model.PingKindSuccess, "10.0.0.1", "curl/7.68.0", "1000", "0", "'" + body}, records[1])
+		assert.Equal(t, []string{"2", "2020-01-01T00:00:01Z", model.PingKindSuccess, "10.0.0.1", "curl/7.68.0", "1000", "", ""}, records[PingExportPageSize])
+	}
+
+	// every page is exported
+	rec, err = runJobPingsRequest(mockDB, 1, testJobID1, "", exportJobPingsHandler)
+	if assert.NoError(t, err) {
+		records, _ := csv.NewReader(rec.Body).ReadAll()
+		assert.Len(t, records, 2*PingExportPageSize+1)
+	}
+}
+
+func TestPingCSVRecordFormulas(t *testing.T) {
+	exitCode := -1
+	durationMs := int64(-5)
+	body := "\r=1+1"
+	ping := &model.JobPing{ID: 7, Kind: model.PingKindFail, SourceIP: "10.0.0.1", UserAgent: "\t=cmd", ExitCode: &exitCode, DurationMs: &durationMs, Body: &body}
+
+	record := pingCSVRecord(ping)
+
+	// assertions
+	assert.Equal(t, "-5", record[5])
+	assert.Equal(t, "-1", record[6])
+	assert.Equal(t, "'\t=cmd", record[4])
+	assert.Equal(t, "'\r=1+1", record[7])
+}
@@ -0,0 +1,482 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"cronspy/backend/pkg/api/job"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/pingkey"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultMaxMailSize is the default max number of bytes of an inbound email
+	DefaultMaxMailSize = 1024 * 1024
+	// DefaultMailTimeout is the default time a client has to send each SMTP command
+	DefaultMailTimeout = time.Minute
+	// DefaultMailSessionTimeout is the default max duration of an SMTP session
+	DefaultMailSessionTimeout = 10 * time.Minute
+	// DefaultMaxMailSessions is the default number of concurrent SMTP sessions;
+	// clients are told to try again later when there are more
+	DefaultMaxMailSessions = 100
+	// MaxMailCommands is the max number of commands of an SMTP session
+	MaxMailCommands = 100
+	// MaxMailRecipients is the max number of recipients of an inbound email
+	MaxMailRecipients = 10
+	// MaxMailLineLength is the max number of bytes of a line sent by a client,
+	// commands or email text; RFC 5321 allows 1000, but some clients send more
+	MaxMailLineLength = 4096
+)
+
+// errLineTooLong is returned when a client sends a line longer than MaxMailLineLength
+var errLineTooLong = errors.New("line too long")
+
+// DefaultMailFailKeywords are the words that mark an inbound email as a failure
+// when they are found in its subject
+var DefaultMailFailKeywords = []string{"FAILED", "FAILURE"}
+
+// SMTPConfig holds the settings of the inbound email listener
+type SMTPConfig struct {
+	// Address is where the listener accepts connections, in the form `host:port`
+	Address string
+	// Domain is the domain of the recipients, as in `<ping-key>@<domain>`
+	Domain string
+	// FailKeywords are matched against the subject, ignoring case
+	FailKeywords   []string
+	MaxMailSize    int
+	MaxSessions    int
+	Timeout        time.Duration
+	SessionTimeout time.Duration
+}
+
+// SMTP is a minimal SMTP server that registers a ping for every email sent
+// to `<ping-key>@<domain>`; the pings are failures when the subject contains
+// any of the fail keywords, and the text of the email is stored as the ping
+// body. Jobs with signed pings reject the pings received by email.
+//
+// It can be tried locally with any SMTP client, such as curl:
+//
+//	curl smtp://127.0.0.1:2525 --mail-rcpt <ping-key>@<domain> --upload-file mail.txt
+type SMTP struct {
+	svc    job.Service
+	cfg    SMTPConfig
+	logger *log.Log
+
+	listener net.Listener
+	sessions chan struct{} // semaphore of the concurrent sessions
+	conns    map[net.Conn]struct{}
+	mux      sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewSMTP creates the inbound email listener; connections are accepted
+// once it's started
+func NewSMTP(svc job.Service, cfg SMTPConfig, logger *log.Log) (*SMTP, error) {
+	if cfg.Domain == "" {
+		return nil, errors.New("the domain of the inbound email listener is required")
+	}
+	if cfg.MaxMailSize <= 0 {
+		cfg.MaxMailSize = DefaultMaxMailSize
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = DefaultMaxMailSessions
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultMailTimeout
+	}
+	if cfg.SessionTimeout <= 0 {
+		cfg.SessionTimeout = DefaultMailSessionTimeout
+	}
+	if len(cfg.FailKeywords) == 0 {
+		cfg.FailKeywords = DefaultMailFailKeywords
+	}
+	cfg.Domain = strings.ToLower(cfg.Domain)
+
+	l, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTP{
+		svc:      svc,
+		cfg:      cfg,
+		logger:   logger,
+		listener: l,
+		sessions: make(chan struct{}, cfg.MaxSessions),
+		conns:    map[net.Conn]struct{}{},
+	}, nil
+}
+
+// Addr returns the address where the listener accepts connections
+func (s *SMTP) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Start starts accepting connections in background
+func (s *SMTP) Start() {
+	s.wg.Add(1)
+	go s.serve()
+	s.logger.Info("inbound email listener started", map[string]interface{}{"address": s.Addr(), "domain": s.cfg.Domain})
+}
+
+// Stop stops accepting connections, waiting for the current sessions to
+// finish; they are closed if the context expires first
+func (s *SMTP) Stop(ctx context.Context) error {
+	s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mux.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mux.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *SMTP) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		select {
+		case s.sessions <- struct{}{}:
+		default:
+			// the client is expected to retry later
+			conn.SetDeadline(time.Now().Add(time.Second))
+			fmt.Fprintf(conn, "421 %s too many connections, try again later\r\n", s.cfg.Domain)
+			conn.Close()
+			continue
+		}
+
+		s.mux.Lock()
+		s.conns[conn] = struct{}{}
+		s.mux.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+
+			s.mux.Lock()
+			delete(s.conns, conn)
+			s.mux.Unlock()
+			<-s.sessions
+		}()
+	}
+}
+
+// handles a single SMTP session
+func (s *SMTP) handle(conn net.Conn) {
+	defer conn.Close()
+
+	sourceIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	tp := textproto.NewConn(struct {
+		io.Reader
+		io.WriteCloser
+	}{&lineLimitReader{r: conn, max: MaxMailLineLength}, conn})
+	// every command has its own timeout, within the timeout of the session
+	end := time.Now().Add(s.cfg.SessionTimeout)
+	reply := func(format string, args ...interface{}) {
+		deadline := time.Now().Add(s.cfg.Timeout)
+		if deadline.After(end) {
+			deadline = end
+		}
+		conn.SetDeadline(deadline)
+		tp.PrintfLine(format, args...)
+	}
+	// the session can't continue after a read error
+	readFailed := func(err error) {
+		if err == errLineTooLong {
+			reply("500 line too long")
+		}
+	}
+	reply("220 %s CronSpy ESMTP", s.cfg.Domain)
+
+	var from string
+	var keys []string
+	for commands := 1; ; commands++ {
+		line, err := tp.ReadLine()
+		if err != nil {
+			readFailed(err)
+			return
+		}
+
+		if commands > MaxMailCommands {
+			reply("421 too many commands")
+			return
+		}
+
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(cmd) {
+		case "HELO":
+			reply("250 %s", s.cfg.Domain)
+		case "EHLO":
+			reply("250-%s", s.cfg.Domain)
+			reply("250 SIZE %d", s.cfg.MaxMailSize)
+		case "MAIL":
+			addr, ok := parseMailAddress(arg, "FROM:")
+			if !ok {
+				reply("501 syntax: MAIL FROM:<address>")
+				continue
+			}
+			from, keys = addr, nil
+			reply("250 ok")
+		case "RCPT":
+			addr, ok := parseMailAddress(arg, "TO:")
+			switch {
+			case !ok:
+				reply("501 syntax: RCPT TO:<address>")
+			case len(keys) >= MaxMailRecipients:
+				reply("452 too many recipients")
+			default:
+				key, valid := s.recipientPingKey(addr)
+				if !valid {
+					reply("550 no such mailbox: %s", addr)
+					continue
+				}
+				keys = append(keys, key)
+				reply("250 ok")
+			}
+		case "DATA":
+			if len(keys) == 0 {
+				reply("503 need RCPT command first")
+				continue
+			}
+			reply("354 end data with <CR><LF>.<CR><LF>")
+
+			// the message is read until its end, even when it's too big
+			dr := tp.DotReader()
+			data, err := ioutil.ReadAll(io.LimitReader(dr, int64(s.cfg.MaxMailSize)+1))
+			if err == nil {
+				_, err = io.Copy(ioutil.Discard, dr)
+			}
+			if err != nil {
+				readFailed(err)
+				return
+			}
+
+			if len(data) > s.cfg.MaxMailSize {
+				reply("552 message exceeds the max size of %d bytes", s.cfg.MaxMailSize)
+			} else {
+				code, msg := s.registerPings(keys, from, sourceIP, data)
+				reply("%d %s", code, msg)
+			}
+			from, keys = "", nil
+		case "RSET":
+			from, keys = "", nil
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// lineLimitReader fails with errLineTooLong when a line is longer than `max`
+// bytes, so clients can't make the session buffer unbounded lines
+type lineLimitReader struct {
+	r   io.Reader
+	max int
+	n   int // bytes read since the last line feed
+}
+
+func (l *lineLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			l.n = 0
+			continue
+		}
+		if l.n++; l.n > l.max {
+			return i, errLineTooLong
+		}
+	}
+	return n, err
+}
+
+// returns the ping key of a recipient of the configured domain
+func (s *SMTP) recipientPingKey(addr string) (key string, ok bool) {
+	i := strings.LastIndexByte(addr, '@')
+	if i < 0 || strings.ToLower(addr[i+1:]) != s.cfg.Domain {
+		return "", false
+	}
+
+	key = strings.ToLower(addr[:i])
+	return key, pingkey.Valid(key)
+}
+
+// registers a ping for every recipient; the reply is successful if any
+// of the pings was registered
+func (s *SMTP) registerPings(keys []string, from, sourceIP string, data []byte) (code int, msg string) {
+	subject, body, err := parseMail(data)
+	if err != nil {
+		return 554, "invalid message: " + err.Error()
+	}
+
+	kind := model.PingKindSuccess
+	if s.isFailure(subject) {
+		kind = model.PingKindFail
+	}
+
+	code, msg = 550, "no such job"
+	for _, key := range keys {
+		ping := &model.JobPing{
+			Kind:      kind,
+			SourceIP:  sourceIP,
+			UserAgent: "email from <" + from + ">",
+		}
+		if body != "" {
+			ping.Body = &body
+		}
+
+		err := s.svc.RegisterPingByKey(key, ping)
+		if err == nil {
+			code, msg = 250, "ok"
+			continue
+		}
+
+		// not found and rejected pings are reported only if none was registered
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			switch {
+			case code == 250:
+			case httpErr.Code == http.StatusForbidden:
+				code, msg = 550, "ping rejected"
+			case httpErr.Code != http.StatusNotFound:
+				code, msg = 451, "temporary error, try again later"
+			}
+		}
+	}
+
+	return
+}
+
+// checks if the subject contains any of the fail keywords
+func (s *SMTP) isFailure(subject string) bool {
+	subject = strings.ToUpper(subject)
+	for _, k := range s.cfg.FailKeywords {
+		if k != "" && strings.Contains(subject, strings.ToUpper(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the address in `FROM:<address>` or `TO:<address>`, ignoring any
+// parameter that follows it; the null sender `<>` is valid
+func parseMailAddress(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+
+	start, end := strings.IndexByte(arg, '<'), strings.IndexByte(arg, '>')
+	if start != 0 || end < start {
+		return "", false
+	}
+	return arg[start+1 : end], true
+}
+
+// returns the decoded subject and text of an email; for multipart emails,
+// the text is the first `text/plain` part
+func parseMail(data []byte) (subject, body string, err error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	subject = msg.Header.Get("Subject")
+	if decoded, errDecode := new(mime.WordDecoder).DecodeHeader(subject); errDecode == nil {
+		subject = decoded
+	}
+
+	text, err := readMailText(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return
+	}
+	body = strings.TrimSpace(string(text))
+	return
+}
+
+func readMailText(header textproto.MIMEHeader, r io.Reader) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// emails without a valid content type are plain text
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			// the multipart reader already decodes quoted-printable parts
+			text, err := readMailText(part.Header, part)
+			if err != nil || text != nil {
+				return text, err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return nil, nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+
+	text, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text: %s", err)
+	}
+	return text, nil
+}
@@ -0,0 +1,341 @@
+package transport
+
+import (
+	"context"
+	"cronspy/backend/pkg/api/job"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMailDomain = "ping.cronspy.test"
+
+// starts an inbound email listener on a random local port
+func startSMTP(t *testing.T, mockDB *DBMock, maxMailSize int) *SMTP {
+	return startSMTPWithConfig(t, mockDB, SMTPConfig{MaxMailSize: maxMailSize})
+}
+
+// starts an inbound email listener with the limits set in `cfg`
+func startSMTPWithConfig(t *testing.T, mockDB *DBMock, cfg SMTPConfig) *SMTP {
+	cfg.Address = "127.0.0.1:0"
+	cfg.Domain = testMailDomain
+	cfg.FailKeywords = []string{"FAILED", "error"}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	s, err := NewSMTP(job.Initialize(nil, mockDB, log.New(), nil), cfg, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	return s
+}
+
+func stopSMTP(t *testing.T, s *SMTP) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, s.Stop(ctx))
+}
+
+func sendMail(s *SMTP, to []string, msg string) error {
+	msg = strings.Replace(msg, "\n", "\r\n", -1)
+	return smtp.SendMail(s.Addr(), nil, "backup@legacy.local", to, []byte(msg))
+}
+
+// returns the pings saved so far; they are saved by the listener goroutines
+func savedPings(db *DBMock) []model.JobPing {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return append([]model.JobPing(nil), db.pings...)
+}
+
+func TestSMTPPing(t *testing.T) {
+	cases := []struct {
+		name string
+		to   string
+		msg  string
+		kind string
+		body string
+	}{
+		{
+			name: "Success",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg:  "Subject: Backup finished\n\nAll files copied\n",
+			kind: model.PingKindSuccess,
+			body: "All files copied",
+		},
+		{
+			name: "Failure",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg:  "Subject: [cron] Backup Failed\n\nDisk full\n",
+			kind: model.PingKindFail,
+			body: "Disk full",
+		},
+		{
+			name: "Encoded subject",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg:  "Subject: =?UTF-8?B?QmFja3VwIEVSUk9S?=\n\nDisk full\n",
+			kind: model.PingKindFail,
+			body: "Disk full",
+		},
+		{
+			name: "Uppercase address",
+			to:   strings.ToUpper(testPingKey1 + "@" + testMailDomain),
+			msg:  "Subject: Backup finished\n\n",
+			kind: model.PingKindSuccess,
+		},
+		{
+			name: "Quoted-printable",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg:  "Subject: Backup finished\nContent-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable\n\nCopied 10 files =E2=9C=93\n",
+			kind: model.PingKindSuccess,
+			body: "Copied 10 files ✓",
+		},
+		{
+			name: "Base64",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg:  "Subject: Backup finished\nContent-Type: text/plain\nContent-Transfer-Encoding: base64\n\nQWxsIGZpbGVz\nIGNvcGllZA==\n",
+			kind: model.PingKindSuccess,
+			body: "All files copied",
+		},
+		{
+			name: "Multipart",
+			to:   testPingKey1 + "@" + testMailDomain,
+			msg: "Subject: Backup finished\nMIME-Version: 1.0\nContent-Type: multipart/alternative; boundary=XYZ\n\n" +
+				"--XYZ\nContent-Type: text/html\n\n<p>All files copied</p>\n" +
+				"--XYZ\nContent-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable\n\nAll files =\ncopied\n" +
+				"--XYZ--\n",
+			kind: model.PingKindSuccess,
+			body: "All files copied",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			s := startSMTP(t, mockDB, 0)
+			defer stopSMTP(t, s)
+
+			err := sendMail(s, []string{tt.to}, tt.msg)
+
+			// assertions
+			if assert.NoError(t, err) {
+				pings := savedPings(mockDB)
+				if assert.Len(t, pings, 1) {
+					assert.Equal(t, testJobID1, pings[0].IDJob)
+					assert.Equal(t, tt.kind, pings[0].Kind)
+					assert.Equal(t, "127.0.0.1", pings[0].SourceIP)
+					assert.Equal(t, "email from <backup@legacy.local>", pings[0].UserAgent)
+					if tt.body == "" {
+						assert.Nil(t, pings[0].Body)
+					} else if assert.NotNil(t, pings[0].Body) {
+						assert.Equal(t, tt.body, *pings[0].Body)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPPingErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		to      []string
+		msg     string
+		wantErr string
+	}{
+		{name: "Other domain", to: []string{testPingKey1 + "@cronspy.test"}, wantErr: "550"},
+		{name: "Invalid key", to: []string{"backup@" + testMailDomain}, wantErr: "550"},
+		{name: "Job ID", to: []string{testJobID1 + "@" + testMailDomain}, wantErr: "550"},
+		{name: "Unknown job", to: []string{testPingKeyUnknown + "@" + testMailDomain}, wantErr: "550"},
+		{name: "Too big", to: []string{testPingKey1 + "@" + testMailDomain}, msg: strings.Repeat("a", 2048), wantErr: "552"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			s := startSMTP(t, mockDB, 1024)
+			defer stopSMTP(t, s)
+
+			msg := "Subject: Backup finished\n\n" + tt.msg
+			err := sendMail(s, tt.to, msg)
+
+			// assertions
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+			assert.Empty(t, savedPings(mockDB))
+		})
+	}
+}
+
+func TestSMTPPingRejected(t *testing.T) {
+	mockDB := getDBMock(true)
+	mockDB.jobs[0].AllowedIPs = model.IPAllowList{"203.0.113.0/24"}
+	s := startSMTP(t, mockDB, 0)
+	defer stopSMTP(t, s)
+
+	err := sendMail(s, []string{testPingKey1 + "@" + testMailDomain}, "Subject: Backup FAILED\n\n")
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "550")
+	}
+	assert.Empty(t, savedPings(mockDB))
+}
+
+func TestSMTPPingManyRecipients(t *testing.T) {
+	mockDB := getDBMock(true)
+	s := startSMTP(t, mockDB, 0)
+	defer stopSMTP(t, s)
+
+	// the email is accepted if any of the jobs exists
+	to := []string{testPingKeyUnknown + "@" + testMailDomain, testPingKey1 + "@" + testMailDomain, testPingKey2 + "@" + testMailDomain}
+	err := sendMail(s, to, "Subject: Backup finished\n\n")
+
+	// assertions
+	if assert.NoError(t, err) {
+		pings := savedPings(mockDB)
+		if assert.Len(t, pings, 2) {
+			assert.Equal(t, testJobID1, pings[0].IDJob)
+			assert.Equal(t, testJobID2, pings[1].IDJob)
+		}
+	}
+}
+
+func TestSMTPLineTooLong(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+	}{
+		{name: "Command", lines: []string{"HELO " + strings.Repeat("a", MaxMailLineLength)}},
+		{name: "Data", lines: []string{
+			"HELO legacy.local",
+			"MAIL FROM:<backup@legacy.local>",
+			"RCPT TO:<" + testPingKey1 + "@" + testMailDomain + ">",
+			"DATA",
+			"Subject: Backup finished\r\n\r\n" + strings.Repeat("a", MaxMailLineLength),
+		}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := getDBMock(true)
+			s := startSMTP(t, mockDB, 0)
+			defer stopSMTP(t, s)
+
+			conn, err := net.Dial("tcp", s.Addr())
+			if !assert.NoError(t, err) {
+				return
+			}
+			tp := textproto.NewConn(conn)
+			defer tp.Close()
+
+			// every line but the last one is accepted
+			_, _, err = tp.ReadResponse(220)
+			for i, line := range tt.lines {
+				if !assert.NoError(t, err) {
+					return
+				}
+				tp.PrintfLine("%s", line)
+				if i < len(tt.lines)-1 {
+					_, _, err = tp.ReadResponse(0)
+				}
+			}
+
+			// assertions
+			_, msg, err := tp.ReadResponse(500)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "line too long", msg)
+			}
+			assert.Empty(t, savedPings(mockDB))
+		})
+	}
+}
+
+// opens an SMTP session, returning the code of the greeting
+func dialSMTP(t *testing.T, s *SMTP) (*textproto.Conn, int) {
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := textproto.NewConn(conn)
+	code, _, _ := tp.ReadResponse(0)
+	return tp, code
+}
+
+func TestSMTPTooManySessions(t *testing.T) {
+	s := startSMTPWithConfig(t, getDBMock(true), SMTPConfig{MaxSessions: 1})
+	defer stopSMTP(t, s)
+
+	tp, code := dialSMTP(t, s)
+	assert.Equal(t, 220, code)
+
+	// other clients must try again later
+	other, code := dialSMTP(t, s)
+	other.Close()
+	assert.Equal(t, 421, code)
+
+	// the session is available again once the first one ends
+	tp.PrintfLine("QUIT")
+	tp.ReadResponse(221)
+	tp.Close()
+	for i := 0; i < 50 && code != 220; i++ {
+		time.Sleep(10 * time.Millisecond)
+		other, code = dialSMTP(t, s)
+		other.Close()
+	}
+	assert.Equal(t, 220, code)
+}
+
+func TestSMTPTooManyCommands(t *testing.T) {
+	s := startSMTP(t, getDBMock(true), 0)
+	defer stopSMTP(t, s)
+
+	tp, _ := dialSMTP(t, s)
+	defer tp.Close()
+
+	for i := 0; i < MaxMailCommands; i++ {
+		tp.PrintfLine("NOOP")
+		if _, _, err := tp.ReadResponse(250); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	// assertions
+	tp.PrintfLine("NOOP")
+	_, msg, err := tp.ReadResponse(421)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "too many commands", msg)
+	}
+}
+
+func TestSMTPSessionTimeout(t *testing.T) {
+	s := startSMTPWithConfig(t, getDBMock(true), SMTPConfig{SessionTimeout: 200 * time.Millisecond})
+	defer stopSMTP(t, s)
+
+	tp, _ := dialSMTP(t, s)
+	defer tp.Close()
+
+	// the session is closed before the timeout of the command
+	start := time.Now()
+	_, err := tp.ReadLine()
+
+	// assertions
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestNewSMTPWithoutDomain(t *testing.T) {
+	_, err := NewSMTP(job.Initialize(nil, getDBMock(false), log.New(), nil), SMTPConfig{Address: "127.0.0.1:0"}, log.New())
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestResult is the outcome of a test notification; the response of the
+// remote server is not included, only its status code
+type TestResult struct {
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SendTestNotification sends a test message through a channel of the user,
+// using the same notifier as the alerts; delivery errors are returned in
+// the result, not as an error
+func (n *Notification) SendTestNotification(ctx context.Context, idChannel, idUser int) (r TestResult, err error) {
+
+	// get channel
+	c, err := n.database.GetChannel(idChannel)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			n.logger.Error("error loading channel", err, map[string]interface{}{"id_channel": idChannel, "id_user": idUser})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if c.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	notifier, ok := n.notifiers[c.Type]
+	if !ok {
+		err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidChannelType, ""))
+		return
+	}
+
+	e := model.AlertEvent{
+		Type:        model.AlertEventTest,
+		DateCreated: time.Now().UTC(),
+		Job:         model.Job{IDUser: idUser, Name: TestJobName, Status: model.JobStatusOK},
+		Alert:       model.JobAlert{IDChannel: idChannel},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	result, errNotify := notifier.Notify(ctx, &c, NewMessage(e))
+	r = TestResult{Success: errNotify == nil, StatusCode: result.StatusCode}
+
+	params := map[string]interface{}{"id_channel": idChannel, "status_code": result.StatusCode}
+	if errNotify != nil {
+		r.Error = errNotify.Error()
+		params["error"] = r.Error
+		n.logger.Warn("test notification failed", params)
+		return
+	}
+
+	n.logger.Info("test notification delivered", params)
+	return
+}
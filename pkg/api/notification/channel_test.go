@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendTestNotification(t *testing.T) {
+	svc, notifier := getServiceMock()
+
+	r, err := svc.SendTestNotification(context.Background(), 1, 1)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, 1, notifier.count()) {
+		assert.Equal(t, TestResult{Success: true, StatusCode: 200}, r)
+
+		msg := notifier.messages[0]
+		assert.Equal(t, model.AlertEventTest, msg.Event.Type)
+		assert.Equal(t, "[Test] CronSpy test notification", msg.Subject)
+		assert.Equal(t, "Test", msg.Status())
+	}
+
+	// nothing is queued or recorded
+	assert.Empty(t, svc.database.(*DBMock).getDeliveries())
+}
+
+func TestSendTestNotificationFailure(t *testing.T) {
+	svc, notifier := getServiceMock()
+	notifier.err = errors.New("invalid_token")
+
+	r, err := svc.SendTestNotification(context.Background(), 1, 1)
+
+	// the delivery error is part of the result
+	if assert.NoError(t, err) {
+		assert.False(t, r.Success)
+		assert.Equal(t, "invalid_token", r.Error)
+	}
+}
+
+func TestSendTestNotificationErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		idChannel int
+		idUser    int
+		status    int
+	}{
+		{name: "Unknown channel", idChannel: 99, idUser: 1, status: http.StatusNotFound},
+		{name: "Other user", idChannel: 1, idUser: 2, status: http.StatusForbidden},
+		{name: "Unsupported channel type", idChannel: 2, idUser: 1, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, notifier := getServiceMock()
+
+			_, err := svc.SendTestNotification(context.Background(), tt.idChannel, tt.idUser)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+			assert.Equal(t, 0, notifier.count())
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// networks that notifications can't be delivered to: loopback, private,
+// link-local (including cloud metadata services), shared and reserved ranges
+var deniedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// NewDeliveryClient returns the HTTP client used by default to deliver
+// notifications. It refuses to connect to addresses of `deniedNetworks`;
+// the check is done on the resolved address of every connection, so
+// redirects and DNS rebinding can't reach internal services either.
+func NewDeliveryClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: DefaultDeliveryTimeout,
+		Control: denyPrivateAddress,
+	}
+	return &http.Client{
+		Timeout: DefaultDeliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: DefaultDeliveryTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// denyPrivateAddress is the `net.Dialer` control function of the delivery
+// client; it runs before connecting, with the address already resolved
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return &PermanentError{err}
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &PermanentError{fmt.Errorf("invalid address '%s'", host)}
+	}
+	for _, n := range deniedNetworks {
+		if n.Contains(ip) {
+			return &PermanentError{errors.New("address not allowed: " + ip.String())}
+		}
+	}
+	return nil
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = n
+	}
+	return networks
+}
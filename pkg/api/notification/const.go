@@ -0,0 +1,47 @@
+package notification
+
+import "time"
+
+const (
+	// DefaultDispatcherWorkers is the number of concurrent deliveries
+	DefaultDispatcherWorkers = 4
+
+	// DefaultPollInterval is the time between checks of the delivery queue
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultMaxAttempts is the number of attempts before a delivery is dead
+	DefaultMaxAttempts = 8
+
+	// RetryBaseDelay is the delay before the first retry; it doubles on every attempt
+	RetryBaseDelay = 30 * time.Second
+
+	// RetryMaxDelay is the max delay between attempts
+	RetryMaxDelay = time.Hour
+
+	// ClaimMargin is added to the delivery timeout to calculate how long a
+	// worker keeps a delivery; after that, other workers can take it
+	ClaimMargin = time.Minute
+
+	// DefaultPageSize configures the default number of records to return
+	DefaultPageSize = 15
+	// MaxPageSize configures the max number of records to return
+	MaxPageSize = 100
+
+	// DefaultDeliveryTimeout is the max time a single delivery attempt can take
+	DefaultDeliveryTimeout = 10 * time.Second
+
+	// JobURL is the frontend URL of a job; it receives the web base URL and the job ID
+	JobURL = "%s/jobs/%s"
+
+	// MaxResponseLength is the max number of bytes kept from remote responses
+	MaxResponseLength = 512
+
+	// MaxOutputLength is the max number of bytes of job output included in messages
+	MaxOutputLength = 1000
+
+	// WebHookUserAgent is sent on every web hook request
+	WebHookUserAgent = "CronSpy-WebHook/1"
+
+	// TestJobName is the name of the sample job described in test notifications
+	TestJobName = "Example job"
+)
@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"sync"
+	"time"
+)
+
+// Dispatcher delivers alert events asynchronously, so that the ping
+// registration and the job evaluation never wait for remote services
+//
+// Events are stored in the delivery queue and taken from there by the
+// workers, which retry failed deliveries with exponential backoff; several
+// replicas can run their own dispatcher over the same queue.
+type Dispatcher struct {
+	svc          *Notification
+	workers      int
+	pollInterval time.Duration
+	wake         chan struct{}
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewDispatcher creates a new dispatcher with `workers` concurrent deliveries
+// that check the queue every `pollInterval`
+func NewDispatcher(svc *Notification, workers int, pollInterval time.Duration) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultDispatcherWorkers
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	return &Dispatcher{
+		svc:          svc,
+		workers:      workers,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, workers),
+	}
+}
+
+// HandleAlert queues the event for delivery and wakes up the workers
+func (d *Dispatcher) HandleAlert(e model.AlertEvent) {
+	if err := d.svc.Enqueue(e); err != nil {
+		return
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start starts the delivery workers
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.run(ctx)
+	}
+	d.svc.logger.Info("notification dispatcher started", map[string]interface{}{"workers": d.workers})
+}
+
+// Stop stops the workers, waiting for the deliveries in progress; the
+// pending ones stay in the queue
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.svc.logger.Info("notification dispatcher stopped", nil)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// process deliveries until the queue is empty
+		for ctx.Err() == nil {
+			if processed, _ := d.svc.ProcessNext(time.Now()); !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// ****************************************************
+//
+// DATABASE MOCK
+//
+// ****************************************************
+
+type DBMock struct {
+	channels   []model.Channel
+	jobs       []model.Job
+	deliveries []model.NotificationDelivery
+	attempts   []model.NotificationAttempt
+
+	currentDeliveryID int
+	mux               sync.Mutex
+}
+
+func (db *DBMock) Transaction() *gorm.DB {
+	return nil
+}
+
+func (db *DBMock) GetChannel(idChannel int) (channel model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == idChannel {
+			return db.channels[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetJob(idJob string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			return db.jobs[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) CreateDelivery(d *model.NotificationDelivery) (err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.currentDeliveryID++
+	d.ID = db.currentDeliveryID
+	db.deliveries = append(db.deliveries, *d)
+	return
+}
+
+func (db *DBMock) ClaimDelivery(token string, now, claimUntil time.Time) (d model.NotificationDelivery, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		x := &db.deliveries[i]
+		if (x.Status == model.DeliveryStatusQueued || x.Status == model.DeliveryStatusFailed) &&
+			!x.DateNextAttempt.After(now) && (x.DateClaimExpires == nil || x.DateClaimExpires.Before(now)) {
+			x.ClaimToken = &token
+			x.DateClaimExpires = &claimUntil
+			return *x, nil
+		}
+	}
+
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) CompleteDelivery(d *model.NotificationDelivery, token string, attempt *model.NotificationAttempt) (updated bool, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		x := &db.deliveries[i]
+		if x.ID == d.ID && x.ClaimToken != nil && *x.ClaimToken == token {
+			*x = *d
+			x.ClaimToken = nil
+			x.DateClaimExpires = nil
+
+			attempt.IDDelivery = d.ID
+			db.attempts = append(db.attempts, *attempt)
+			return true, nil
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetDelivery(idDelivery int) (d model.NotificationDelivery, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		if db.deliveries[i].ID == idDelivery {
+			return db.deliveries[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		if db.deliveries[i].IDUser == idUser && db.deliveries[i].Status == model.DeliveryStatusDead {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+func (db *DBMock) RequeueDelivery(idDelivery int, now time.Time) (updated bool, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		x := &db.deliveries[i]
+		if x.ID == idDelivery && x.Status == model.DeliveryStatusDead {
+			x.Status = model.DeliveryStatusQueued
+			x.Attempts = 0
+			x.DateNextAttempt = &now
+			updated = true
+		}
+	}
+	return
+}
+
+func (db *DBMock) GetChannelDeliveries(idChannel int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		if db.deliveries[i].IDChannel == idChannel {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+func (db *DBMock) GetJobDeliveries(idJob string, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for i := range db.deliveries {
+		if db.deliveries[i].IDJob == idJob {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+// returns a copy of the deliveries
+func (db *DBMock) getDeliveries() []model.NotificationDelivery {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return append([]model.NotificationDelivery(nil), db.deliveries...)
+}
+
+// notifierMock records the delivered messages
+type notifierMock struct {
+	messages []*Message
+	err      error
+	mux      sync.Mutex
+
+	// errors returned by the first calls, before `err`
+	errs []error
+}
+
+func (n *notifierMock) Notify(ctx context.Context, channel *model.Channel, msg *Message) (r Result, err error) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	n.messages = append(n.messages, msg)
+
+	err = n.err
+	if len(n.errs) > 0 {
+		err, n.errs = n.errs[0], n.errs[1:]
+	}
+	return Result{StatusCode: 200}, err
+}
+
+func (n *notifierMock) count() int {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return len(n.messages)
+}
+
+func getServiceMock() (*Notification, *notifierMock) {
+	db := &DBMock{
+		channels: []model.Channel{
+			{ID: 1, IDUser: 1, Name: "Hooks", Type: model.ChannelTypeWebHook},
+			{ID: 2, IDUser: 1, Name: "Slack", Type: model.ChannelTypeSlack},
+		},
+		jobs: []model.Job{
+			{ID: "job-1", IDUser: 1, Name: "Backup"},
+		},
+	}
+
+	notifier := &notifierMock{}
+	svc := Initialize(nil, db, log.New(), "https://app.cronspy.com")
+	svc.RegisterNotifier(model.ChannelTypeWebHook, notifier)
+
+	return svc, notifier
+}
+
+func getAlertEvent(idChannel int) model.AlertEvent {
+	expected := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	return model.AlertEvent{
+		Type:         model.AlertEventJobDown,
+		DateCreated:  expected.Add(5 * time.Minute),
+		Job:          model.Job{ID: "job-1", IDUser: 1, Name: "Backup"},
+		Alert:        model.JobAlert{ID: 1, IDJob: "job-1", IDChannel: idChannel},
+		DateExpected: &expected,
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	svc, notifier := getServiceMock()
+
+	r, err := svc.Deliver(context.Background(), getAlertEvent(1))
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, 1, notifier.count()) {
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, "Job 'Backup' is down", notifier.messages[0].Subject)
+	}
+}
+
+func TestDeliverUnknownChannel(t *testing.T) {
+	svc, notifier := getServiceMock()
+
+	_, err := svc.Deliver(context.Background(), getAlertEvent(99))
+
+	// assertions
+	assert.Equal(t, exception.ErrRecordNotFound, err)
+	assert.Equal(t, 0, notifier.count())
+}
+
+func TestDeliverUnsupportedChannelType(t *testing.T) {
+	svc, notifier := getServiceMock()
+
+	_, err := svc.Deliver(context.Background(), getAlertEvent(2))
+
+	// assertions
+	assert.Error(t, err)
+	assert.Equal(t, 0, notifier.count())
+}
+
+func TestDeliverNotifierError(t *testing.T) {
+	svc, notifier := getServiceMock()
+	notifier.err = errors.New("remote error")
+
+	_, err := svc.Deliver(context.Background(), getAlertEvent(1))
+
+	// assertions
+	assert.Equal(t, notifier.err, err)
+}
+
+func TestDispatcher(t *testing.T) {
+	svc, notifier := getServiceMock()
+	d := NewDispatcher(svc, 2, time.Hour)
+	d.Start()
+
+	for i := 0; i < 5; i++ {
+		d.HandleAlert(getAlertEvent(1))
+	}
+
+	// the workers are woken up, without waiting for the poll interval
+	assert.Eventually(t, func() bool { return notifier.count() == 5 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, d.Stop(ctx))
+
+	for _, x := range svc.database.(*DBMock).getDeliveries() {
+		assert.Equal(t, model.DeliveryStatusSent, x.Status)
+		assert.Equal(t, 1, x.Attempts)
+	}
+}
+
+func TestDispatcherKeepsEventsWhenStopped(t *testing.T) {
+	svc, notifier := getServiceMock()
+	d := NewDispatcher(svc, 1, time.Hour)
+
+	// not started, so the events stay in the queue
+	d.HandleAlert(getAlertEvent(1))
+	d.HandleAlert(getAlertEvent(1))
+	assert.Equal(t, 0, notifier.count())
+	assert.Len(t, svc.database.(*DBMock).getDeliveries(), 2)
+
+	d.Start()
+	assert.Eventually(t, func() bool { return notifier.count() == 2 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, d.Stop(ctx))
+}
+
+func TestNewMessage(t *testing.T) {
+	e := getAlertEvent(1)
+	tz := "America/New_York"
+	e.Job.CronExpressionTimezone = &tz
+
+	m := NewMessage(e)
+	assert.Equal(t, "Job 'Backup' was expected to run at 2020-01-01 05:00:00 EST but it didn't check in. Last check-in: never.", m.Text)
+
+	e.Type = model.AlertEventJobRecovered
+	e.Job.DateLastPing = e.DateExpected
+	m = NewMessage(e)
+	assert.Equal(t, "Job 'Backup' is back up", m.Subject)
+	assert.Contains(t, m.Text, "Last check-in: 2020-01-01 05:00:00 EST.")
+}
+
+func TestMessageOutput(t *testing.T) {
+	e := getAlertEvent(1)
+	e.Type = model.AlertEventJobFailed
+
+	m := NewMessage(e)
+	assert.Equal(t, "", m.Output())
+	assert.Equal(t, "", m.ExitCode())
+
+	// only the end of long outputs is included
+	e.Ping = &model.JobPing{Kind: model.PingKindFail, Body: strPtr("\n" + strings.Repeat("é", MaxOutputLength) + "error: disk full\n"), ExitCode: intPtr(1)}
+	m = NewMessage(e)
+	assert.True(t, strings.HasPrefix(m.Output(), "…é"))
+	assert.True(t, strings.HasSuffix(m.Output(), "é"+"error: disk full"))
+	assert.True(t, len(m.Output()) <= MaxOutputLength+len("…"))
+	assert.True(t, utf8.ValidString(m.Output()))
+	assert.Equal(t, "1", m.ExitCode())
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
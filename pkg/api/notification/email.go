@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/mail"
+	"cronspy/backend/pkg/util/model"
+	"errors"
+)
+
+// EmailNotifier delivers messages to channels of type `ChannelTypeEmail`
+type EmailNotifier struct {
+	mailer mail.Mailer
+}
+
+// NewEmailNotifier returns a notifier that sends emails using `mailer`
+func NewEmailNotifier(mailer mail.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer}
+}
+
+// Notify sends the message to the email address configured in the channel
+func (n *EmailNotifier) Notify(ctx context.Context, channel *model.Channel, msg *Message) (r Result, err error) {
+
+	ce := channel.GetChannelEmail()
+	if ce.Email == "" {
+		return r, &PermanentError{errors.New("channel without email address")}
+	}
+
+	data := mail.JobAlertData{
+		JobName:     msg.Event.Job.Name,
+		Description: msg.Text,
+		LastCheckIn: msg.FormatDate(msg.Event.Job.DateLastPing),
+		JobURL:      msg.JobURL,
+		Labels:      msg.Event.Job.Labels.String(),
+		ExitCode:    msg.ExitCode(),
+		Output:      msg.Output(),
+	}
+	if msg.Event.DateExpected != nil {
+		data.Expected = msg.FormatDate(msg.Event.DateExpected)
+	}
+
+	m, err := mail.Render(emailTemplate(msg.Event.Type), data, ce.Email)
+	if err != nil {
+		return r, &PermanentError{err}
+	}
+
+	err = n.mailer.Send(ctx, m)
+	return
+}
+
+// returns the template used for each event type
+func emailTemplate(eventType string) string {
+	switch eventType {
+	case model.AlertEventJobFailed:
+		return mail.TemplateJobFailed
+	case model.AlertEventJobRecovered:
+		return mail.TemplateJobRecovered
+	case model.AlertEventTest:
+		return mail.TemplateTest
+	default:
+		return mail.TemplateJobDown
+	}
+}
@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/mail"
+	"cronspy/backend/pkg/util/mail/mailtest"
+	"cronspy/backend/pkg/util/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	svc, _ := getServiceMock()
+	svc.database.(*DBMock).channels[0] = model.Channel{ID: 1, IDUser: 1, Type: model.ChannelTypeEmail,
+		Configuration: map[string]interface{}{"email": "user@cronspy.com"}}
+	svc.RegisterNotifier(model.ChannelTypeEmail, NewEmailNotifier(mail.NewSMTPMailer(mail.SMTPConfig{
+		Host: s.Host(),
+		Port: s.Port(),
+		From: "alerts@cronspy.com",
+	})))
+
+	// down
+	_, err = svc.Deliver(context.Background(), getAlertEvent(1))
+	if assert.NoError(t, err) && assert.Len(t, s.Messages(), 1) {
+		m := s.Messages()[0]
+		assert.Equal(t, []string{"user@cronspy.com"}, m.To)
+		assert.Contains(t, m.Data, "Job 'Backup' is down")
+		assert.Contains(t, m.Data, "2020-01-01 10:00:00 UTC")
+		assert.Contains(t, m.Data, "https://app.cronspy.com/jobs/job-1")
+	}
+
+	// recovery
+	e := getAlertEvent(1)
+	e.Type = model.AlertEventJobRecovered
+	_, err = svc.Deliver(context.Background(), e)
+	if assert.NoError(t, err) && assert.Len(t, s.Messages(), 2) {
+		assert.Contains(t, s.Messages()[1].Data, "Job 'Backup' is back up")
+	}
+}
+
+func TestEmailNotifierWithoutAddress(t *testing.T) {
+	n := NewEmailNotifier(nil)
+
+	_, err := n.Notify(context.Background(), &model.Channel{ID: 1, Type: model.ChannelTypeEmail}, NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.True(t, IsPermanent(err))
+	}
+}
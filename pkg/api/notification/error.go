@@ -0,0 +1,24 @@
+package notification
+
+import "errors"
+
+// PermanentError is a delivery error that won't be solved by retrying,
+// like a wrong configuration of the channel
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent returns true if the delivery must not be retried
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
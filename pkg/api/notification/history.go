@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetChannelDeliveries returns the deliveries sent to a channel of the user
+func (n *Notification) GetChannelDeliveries(idChannel, idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+
+	// get channel
+	c, err := n.database.GetChannel(idChannel)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			n.logger.Error("error loading channel", err, map[string]interface{}{"id_channel": idChannel, "id_user": idUser})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if c.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	deliveries, p, err = n.database.GetChannelDeliveries(idChannel, pageSize, page)
+	if err != nil {
+		n.logger.Error("error loading channel deliveries", err, map[string]interface{}{"id_channel": idChannel})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+	return
+}
+
+// GetJobDeliveries returns the deliveries of the alerts of a job of the user
+func (n *Notification) GetJobDeliveries(idJob string, idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+
+	// get job
+	job, err := n.database.GetJob(idJob)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			n.logger.Error("error loading job", err, map[string]interface{}{"id_job": idJob, "id_user": idUser})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if job.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	deliveries, p, err = n.database.GetJobDeliveries(idJob, pageSize, page)
+	if err != nil {
+		n.logger.Error("error loading job deliveries", err, map[string]interface{}{"id_job": idJob})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+	return
+}
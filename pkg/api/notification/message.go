@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"cronspy/backend/pkg/util/model"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layout used to show dates in messages
+const dateLayout = "2006-01-02 15:04:05 MST"
+
+// Message is the content of a notification, built from an alert event
+type Message struct {
+	Event   model.AlertEvent
+	Subject string
+	Text    string
+	JobURL  string
+}
+
+// NewMessage builds the message describing an alert event
+func NewMessage(e model.AlertEvent) *Message {
+	m := &Message{Event: e}
+	name := e.Job.Name
+
+	switch e.Type {
+	case model.AlertEventJobDown:
+		m.Subject = fmt.Sprintf("Job '%s' is down", name)
+		m.Text = fmt.Sprintf("Job '%s' was expected to run at %s but it didn't check in. Last check-in: %s.",
+			name, m.FormatDate(e.DateExpected), m.FormatDate(e.Job.DateLastPing))
+	case model.AlertEventJobFailed:
+		m.Subject = fmt.Sprintf("Job '%s' failed", name)
+		m.Text = fmt.Sprintf("Job '%s' reported a failure. Last check-in: %s.",
+			name, m.FormatDate(e.Job.DateLastPing))
+	case model.AlertEventJobRecovered:
+		m.Subject = fmt.Sprintf("Job '%s' is back up", name)
+		m.Text = fmt.Sprintf("Job '%s' checked in successfully and is running again. Last check-in: %s.",
+			name, m.FormatDate(e.Job.DateLastPing))
+	case model.AlertEventTest:
+		m.Subject = "[Test] CronSpy test notification"
+		m.Text = fmt.Sprintf("This is a test notification for job '%s'. If you are reading it, the channel works; no action is needed.", name)
+	default:
+		m.Subject = fmt.Sprintf("Job '%s' changed its status", name)
+		m.Text = fmt.Sprintf("Job '%s' is now in status %s. Last check-in: %s.",
+			name, e.Job.Status, m.FormatDate(e.Job.DateLastPing))
+	}
+
+	return m
+}
+
+// FormatDate formats a date in the timezone of the job, or UTC if the job doesn't have one
+func (m *Message) FormatDate(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "never"
+	}
+
+	loc := time.UTC
+	if tz := m.Event.Job.CronExpressionTimezone; tz != nil && *tz != "" {
+		if l, err := time.LoadLocation(*tz); err == nil {
+			loc = l
+		}
+	}
+
+	return t.In(loc).Format(dateLayout)
+}
+
+// Status returns a short description of the status change of the job
+func (m *Message) Status() string {
+	switch m.Event.Type {
+	case model.AlertEventJobDown:
+		return "Up → Down (missed run)"
+	case model.AlertEventJobFailed:
+		return "Up → Down (failed)"
+	case model.AlertEventJobRecovered:
+		return "Down → Up"
+	case model.AlertEventTest:
+		return "Test"
+	default:
+		return m.Event.Job.Status
+	}
+}
+
+// Schedule returns a short description of the schedule of the job
+func (m *Message) Schedule() string {
+	job := m.Event.Job
+
+	if job.JobType == model.JobTypeAuto {
+		if job.DetectedIntervalMinutes == nil {
+			return "Automatic (interval not detected yet)"
+		}
+		return fmt.Sprintf("Automatic (every %d minutes)", *job.DetectedIntervalMinutes)
+	}
+
+	if job.CronExpression == nil {
+		return "Not set"
+	}
+
+	tz := "UTC"
+	if job.CronExpressionTimezone != nil && *job.CronExpressionTimezone != "" {
+		tz = *job.CronExpressionTimezone
+	}
+	return fmt.Sprintf("%s (%s)", *job.CronExpression, tz)
+}
+
+// Output returns the end of the output sent by the ping that caused the
+// event, or an empty string if there's none
+func (m *Message) Output() string {
+	p := m.Event.Ping
+	if p == nil || p.Body == nil {
+		return ""
+	}
+
+	// errors are usually at the end
+	output := strings.TrimSpace(*p.Body)
+	if len(output) > MaxOutputLength {
+		output = "…" + strings.ToValidUTF8(output[len(output)-MaxOutputLength:], "")
+	}
+	return output
+}
+
+// ExitCode returns the exit code sent by the ping that caused the event,
+// or an empty string if there's none
+func (m *Message) ExitCode() string {
+	p := m.Event.Ping
+	if p == nil || p.ExitCode == nil {
+		return ""
+	}
+	return strconv.Itoa(*p.ExitCode)
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"fmt"
+)
+
+// Deliver sends the alert event through the channel configured in the alert
+func (n *Notification) Deliver(ctx context.Context, e model.AlertEvent) (r Result, err error) {
+
+	params := map[string]interface{}{"type": e.Type, "id_job": e.Job.ID, "id_alert": e.Alert.ID, "id_channel": e.Alert.IDChannel}
+
+	// resolve alert channel
+	channel, err := n.database.GetChannel(e.Alert.IDChannel)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			n.logger.Warn("alert channel not found", params)
+		} else {
+			n.logger.Error("error loading alert channel", err, params)
+		}
+		return
+	}
+
+	notifier, ok := n.notifiers[channel.Type]
+	if !ok {
+		err = &PermanentError{fmt.Errorf("channel type '%s' not supported", channel.Type)}
+		n.logger.Error("error delivering notification", err, params)
+		return
+	}
+
+	msg := NewMessage(e)
+	if n.webBaseURL != "" {
+		msg.JobURL = fmt.Sprintf(JobURL, n.webBaseURL, e.Job.ID)
+	}
+
+	r, err = notifier.Notify(ctx, &channel, msg)
+	if r.StatusCode > 0 {
+		params["status_code"] = r.StatusCode
+		params["response"] = r.Response
+	}
+	if err != nil {
+		params["permanent"] = IsPermanent(err)
+		n.logger.Error("error delivering notification", err, params)
+		return
+	}
+
+	n.logger.Info("notification delivered", params)
+	return
+}
@@ -0,0 +1,11 @@
+package db
+
+import (
+	jobdb "cronspy/backend/pkg/api/job/platform/db"
+	"cronspy/backend/pkg/util/model"
+)
+
+// GetChannel returns a channel by ID, including its configuration
+func (c *NotificationDB) GetChannel(idChannel int) (channel model.Channel, err error) {
+	return jobdb.NewJobDB(c.ds).GetChannel(idChannel, true)
+}
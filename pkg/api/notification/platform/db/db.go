@@ -0,0 +1,20 @@
+package db
+
+import "github.com/jinzhu/gorm"
+
+// NewNotificationDB returns a new notification database instance
+func NewNotificationDB(ds *gorm.DB) (c *NotificationDB) {
+	c = new(NotificationDB)
+	c.ds = ds
+	return
+}
+
+// NotificationDB contains the services to handle notifications
+type NotificationDB struct {
+	ds *gorm.DB
+}
+
+// Transaction returns a new database transaction
+func (c *NotificationDB) Transaction() *gorm.DB {
+	return c.ds.Begin()
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CreateDelivery queues a new delivery
+func (c *NotificationDB) CreateDelivery(d *model.NotificationDelivery) (err error) {
+	err = c.ds.Create(d).Error
+	return
+}
+
+// ClaimDelivery takes the next delivery ready to be attempted, marking it with
+// `token` until `claimUntil`; the claim is a single conditional UPDATE, so
+// concurrent workers (even on different replicas) never take the same row
+func (c *NotificationDB) ClaimDelivery(token string, now, claimUntil time.Time) (d model.NotificationDelivery, err error) {
+
+	q := c.ds.Model(&model.NotificationDelivery{}).
+		Where("status IN (?)", []string{model.DeliveryStatusQueued, model.DeliveryStatusFailed}).
+		Where("date_next_attempt <= ?", now).
+		Where("date_claim_expires IS NULL OR date_claim_expires < ?", now).
+		Order("date_next_attempt asc").
+		Limit(1).
+		Updates(map[string]interface{}{"claim_token": token, "date_claim_expires": claimUntil})
+
+	if err = q.Error; err != nil {
+		return
+	}
+	if q.RowsAffected == 0 {
+		err = exception.ErrRecordNotFound
+		return
+	}
+
+	err = c.ds.Where("claim_token = ?", token).First(&d).Error
+	if err == gorm.ErrRecordNotFound {
+		err = exception.ErrRecordNotFound
+	}
+	return
+}
+
+// CompleteDelivery saves the result of an attempt and releases the claim; nothing
+// is updated if the claim was lost (e.g. it expired and another worker took it)
+func (c *NotificationDB) CompleteDelivery(d *model.NotificationDelivery, token string, attempt *model.NotificationAttempt) (updated bool, err error) {
+
+	trx := c.ds.Begin()
+
+	q := trx.Model(&model.NotificationDelivery{}).
+		Where("id_delivery = ? AND claim_token = ?", d.ID, token).
+		Updates(map[string]interface{}{
+			"status":             d.Status,
+			"attempts":           d.Attempts,
+			"date_next_attempt":  d.DateNextAttempt,
+			"date_last_attempt":  d.DateLastAttempt,
+			"date_sent":          d.DateSent,
+			"last_status_code":   d.LastStatusCode,
+			"last_error":         d.LastError,
+			"claim_token":        nil,
+			"date_claim_expires": nil,
+		})
+	if err = q.Error; err != nil || q.RowsAffected == 0 {
+		trx.Rollback()
+		return
+	}
+
+	attempt.IDDelivery = d.ID
+	if err = trx.Create(attempt).Error; err != nil {
+		trx.Rollback()
+		return
+	}
+
+	err = trx.Commit().Error
+	updated = err == nil
+	return
+}
+
+// GetDelivery returns a delivery by ID
+func (c *NotificationDB) GetDelivery(idDelivery int) (d model.NotificationDelivery, err error) {
+	err = c.ds.Where("id_delivery = ?", idDelivery).First(&d).Error
+	if err == gorm.ErrRecordNotFound {
+		err = exception.ErrRecordNotFound
+	}
+	return
+}
+
+// GetDeadDeliveries returns the deliveries of a user that ran out of attempts
+func (c *NotificationDB) GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	q := c.ds.Model(&model.NotificationDelivery{}).Where("id_user = ? AND status = ?", idUser, model.DeliveryStatusDead)
+	return c.getDeliveries(q, pageSize, page)
+}
+
+// GetChannelDeliveries returns the deliveries sent to a channel, newest first
+func (c *NotificationDB) GetChannelDeliveries(idChannel int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	q := c.ds.Model(&model.NotificationDelivery{}).Where("id_channel = ?", idChannel)
+	return c.getDeliveries(q, pageSize, page)
+}
+
+// GetJobDeliveries returns the deliveries of the alerts of a job, newest first
+func (c *NotificationDB) GetJobDeliveries(idJob string, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	q := c.ds.Model(&model.NotificationDelivery{}).Where("id_job = ?", idJob)
+	return c.getDeliveries(q, pageSize, page)
+}
+
+// returns a page of the deliveries that match `q`, with their attempts
+func (c *NotificationDB) getDeliveries(q *gorm.DB, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+
+	offset := 0
+	if page > 1 {
+		offset = ((page - 1) * pageSize)
+	}
+
+	// get total records
+	totalRecords := 0
+	if err = q.Count(&totalRecords).Error; err != nil {
+		return
+	}
+
+	if err = q.Order("date_created desc, id_delivery desc").Offset(offset).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		return
+	}
+
+	// load attempts
+	if len(deliveries) > 0 {
+		ids := make([]int, len(deliveries))
+		index := make(map[int]int, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			index[deliveries[i].ID] = i
+		}
+
+		var attempts []model.NotificationAttempt
+		if err = c.ds.Where("id_delivery IN (?)", ids).Order("attempt asc").Find(&attempts).Error; err != nil {
+			return
+		}
+		for _, a := range attempts {
+			d := &deliveries[index[a.IDDelivery]]
+			d.AttemptHistory = append(d.AttemptHistory, a)
+		}
+	}
+
+	p.Page = page
+	p.PageSize = pageSize
+	p.TotalRows = totalRecords
+	return
+}
+
+// RequeueDelivery moves a dead delivery back to the queue, with all its attempts
+func (c *NotificationDB) RequeueDelivery(idDelivery int, now time.Time) (updated bool, err error) {
+	q := c.ds.Model(&model.NotificationDelivery{}).
+		Where("id_delivery = ? AND status = ?", idDelivery, model.DeliveryStatusDead).
+		Updates(map[string]interface{}{
+			"status":            model.DeliveryStatusQueued,
+			"attempts":          0,
+			"date_next_attempt": now,
+		})
+
+	err = q.Error
+	updated = q.RowsAffected > 0
+	return
+}
@@ -0,0 +1,11 @@
+package db
+
+import (
+	jobdb "cronspy/backend/pkg/api/job/platform/db"
+	"cronspy/backend/pkg/util/model"
+)
+
+// GetJob returns a job by ID
+func (c *NotificationDB) GetJob(idJob string) (job model.Job, err error) {
+	return jobdb.NewJobDB(c.ds).GetJobByID(idJob)
+}
@@ -0,0 +1,265 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// queuedEvent is the payload stored with every delivery; only the job fields
+// used by the notifiers are stored, so secrets such as the ping secret are
+// not. The detected interval of the job is not part of its JSON, so it's
+// stored apart; messages use it to describe the schedule of automatic jobs.
+type queuedEvent struct {
+	Type                    string         `json:"type"`
+	DateCreated             time.Time      `json:"date_created"`
+	Job                     queuedJob      `json:"job"`
+	Alert                   model.JobAlert `json:"alert"`
+	DateExpected            *time.Time     `json:"date_expected"`
+	Ping                    *model.JobPing `json:"ping,omitempty"`
+	DetectedIntervalMinutes *int           `json:"detected_interval_minutes,omitempty"`
+}
+
+// queuedJob holds the job fields of a queued event, with the JSON names of `model.Job`
+type queuedJob struct {
+	ID                     string          `json:"id"`
+	IDUser                 int             `json:"id_user"`
+	Name                   string          `json:"name"`
+	JobType                string          `json:"job_type"`
+	Status                 string          `json:"status"`
+	CronExpression         *string         `json:"cron_expression"`
+	CronExpressionTimezone *string         `json:"cron_expression_timezone"`
+	DateLastPing           *time.Time      `json:"date_last_ping"`
+	Labels                 model.JobLabels `json:"labels,omitempty"`
+}
+
+func newQueuedEvent(e model.AlertEvent) queuedEvent {
+	return queuedEvent{
+		Type:        e.Type,
+		DateCreated: e.DateCreated,
+		Job: queuedJob{
+			ID:                     e.Job.ID,
+			IDUser:                 e.Job.IDUser,
+			Name:                   e.Job.Name,
+			JobType:                e.Job.JobType,
+			Status:                 e.Job.Status,
+			CronExpression:         e.Job.CronExpression,
+			CronExpressionTimezone: e.Job.CronExpressionTimezone,
+			DateLastPing:           e.Job.DateLastPing,
+			Labels:                 e.Job.Labels,
+		},
+		Alert:                   e.Alert,
+		DateExpected:            e.DateExpected,
+		Ping:                    e.Ping,
+		DetectedIntervalMinutes: e.Job.DetectedIntervalMinutes,
+	}
+}
+
+// returns the alert event that was queued
+func (q *queuedEvent) alertEvent() model.AlertEvent {
+	return model.AlertEvent{
+		Type:        q.Type,
+		DateCreated: q.DateCreated,
+		Job: model.Job{
+			ID:                      q.Job.ID,
+			IDUser:                  q.Job.IDUser,
+			Name:                    q.Job.Name,
+			JobType:                 q.Job.JobType,
+			Status:                  q.Job.Status,
+			CronExpression:          q.Job.CronExpression,
+			CronExpressionTimezone:  q.Job.CronExpressionTimezone,
+			DetectedIntervalMinutes: q.DetectedIntervalMinutes,
+			DateLastPing:            q.Job.DateLastPing,
+			Labels:                  q.Job.Labels,
+		},
+		Alert:        q.Alert,
+		DateExpected: q.DateExpected,
+		Ping:         q.Ping,
+	}
+}
+
+// Enqueue stores the alert event in the delivery queue
+func (n *Notification) Enqueue(e model.AlertEvent) (err error) {
+	params := map[string]interface{}{"type": e.Type, "id_job": e.Job.ID, "id_alert": e.Alert.ID, "id_channel": e.Alert.IDChannel}
+
+	payload, err := json.Marshal(newQueuedEvent(e))
+	if err != nil {
+		n.logger.Error("error encoding alert event", err, params)
+		return
+	}
+
+	now := time.Now()
+	d := &model.NotificationDelivery{
+		IDUser:          e.Job.IDUser,
+		IDJob:           e.Job.ID,
+		IDAlert:         e.Alert.ID,
+		IDChannel:       e.Alert.IDChannel,
+		EventType:       e.Type,
+		Payload:         string(payload),
+		Status:          model.DeliveryStatusQueued,
+		DateCreated:     now,
+		DateNextAttempt: &now,
+	}
+
+	if err = n.database.CreateDelivery(d); err != nil {
+		n.logger.Error("error queueing notification", err, params)
+	}
+	return
+}
+
+// ProcessNext claims the next delivery ready to be attempted and delivers it;
+// it returns false when there was nothing to process
+func (n *Notification) ProcessNext(now time.Time) (processed bool, err error) {
+
+	token := uuid.New().String()
+	d, err := n.database.ClaimDelivery(token, now, now.Add(n.timeout+ClaimMargin))
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = nil
+		} else {
+			n.logger.Error("error claiming notification delivery", err, nil)
+		}
+		return
+	}
+
+	n.attemptDelivery(&d, token)
+	return true, nil
+}
+
+// attempts a claimed delivery and saves the outcome
+func (n *Notification) attemptDelivery(d *model.NotificationDelivery, token string) {
+	params := map[string]interface{}{"id_delivery": d.ID, "id_job": d.IDJob, "id_channel": d.IDChannel}
+
+	start := time.Now()
+	var r Result
+
+	var e queuedEvent
+	err := json.Unmarshal([]byte(d.Payload), &e)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+		r, err = n.Deliver(ctx, e.alertEvent())
+		cancel()
+	} else {
+		err = &PermanentError{err}
+	}
+
+	now := time.Now()
+	d.Attempts++
+	d.DateLastAttempt = &now
+
+	attempt := &model.NotificationAttempt{
+		Attempt:     d.Attempts,
+		DateCreated: start,
+		DurationMs:  now.Sub(start).Milliseconds(),
+	}
+	if r.StatusCode > 0 {
+		attempt.StatusCode = &r.StatusCode
+		attempt.Response = &r.Response
+		d.LastStatusCode = &r.StatusCode
+	}
+
+	if err == nil {
+		d.Status = model.DeliveryStatusSent
+		d.DateSent = &now
+		d.DateNextAttempt = nil
+		d.LastError = nil
+		attempt.Status = model.DeliveryStatusSent
+	} else {
+		errText := err.Error()
+		d.LastError = &errText
+		attempt.Error = &errText
+		attempt.Status = model.DeliveryStatusFailed
+
+		if IsPermanent(err) || err == exception.ErrRecordNotFound || d.Attempts >= n.maxAttempts() {
+			d.Status = model.DeliveryStatusDead
+			d.DateNextAttempt = nil
+			attempt.Status = model.DeliveryStatusDead
+			n.logger.Warn("notification delivery is dead", params)
+		} else {
+			next := now.Add(retryDelay(d.Attempts))
+			d.Status = model.DeliveryStatusFailed
+			d.DateNextAttempt = &next
+		}
+	}
+
+	updated, errSave := n.database.CompleteDelivery(d, token, attempt)
+	if errSave != nil {
+		n.logger.Error("error saving notification delivery", errSave, params)
+	} else if !updated {
+		n.logger.Warn("notification delivery claim lost", params)
+	}
+}
+
+func (n *Notification) maxAttempts() int {
+	if n.attempts > 0 {
+		return n.attempts
+	}
+	return DefaultMaxAttempts
+}
+
+// returns the delay before the next attempt: exponential on the number of
+// attempts, with a random jitter of up to half of it so that deliveries that
+// failed together don't retry together
+func retryDelay(attempts int) time.Duration {
+	delay := RetryMaxDelay
+	if attempts < 32 {
+		if d := RetryBaseDelay << uint(attempts-1); d > 0 && d < RetryMaxDelay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// GetDeadDeliveries returns the deliveries of a user that ran out of attempts
+func (n *Notification) GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	deliveries, p, err = n.database.GetDeadDeliveries(idUser, pageSize, page)
+	if err != nil {
+		n.logger.Error("error loading dead deliveries", err, map[string]interface{}{"id_user": idUser})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+	return
+}
+
+// RetryDelivery moves a dead delivery back to the queue
+func (n *Notification) RetryDelivery(idDelivery, idUser int) (err error) {
+
+	d, err := n.database.GetDelivery(idDelivery)
+	if err != nil {
+		if err == exception.ErrRecordNotFound {
+			err = echo.NewHTTPError(http.StatusNotFound, exception.GetErrorMap(exception.CodeNotFound, ""))
+		} else {
+			n.logger.Error("error loading delivery", err, map[string]interface{}{"id_delivery": idDelivery})
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	// check if the user is the owner
+	if d.IDUser != idUser {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	updated, err := n.database.RequeueDelivery(idDelivery, time.Now())
+	if err != nil {
+		n.logger.Error("error requeueing delivery", err, map[string]interface{}{"id_delivery": idDelivery})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+		return
+	}
+
+	// only dead deliveries can be retried
+	if !updated {
+		err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeDeliveryNotDead, ""))
+	}
+
+	return
+}
@@ -0,0 +1,225 @@
+package notification
+
+import (
+	"cronspy/backend/pkg/util/model"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	svc, notifier := getServiceMock()
+	db := svc.database.(*DBMock)
+	notifier.errs = []error{errors.New("remote down")}
+
+	e := getAlertEvent(1)
+	e.Job.JobType = model.JobTypeAuto
+	e.Job.DetectedIntervalMinutes = intPtr(15)
+	assert.NoError(t, svc.Enqueue(e))
+
+	// first attempt fails
+	now := time.Now()
+	processed, err := svc.ProcessNext(now)
+	if assert.NoError(t, err) && assert.True(t, processed) {
+		d := db.getDeliveries()[0]
+		assert.Equal(t, model.DeliveryStatusFailed, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, "remote down", *d.LastError)
+		assert.Nil(t, d.ClaimToken)
+		if assert.NotNil(t, d.DateNextAttempt) {
+			delay := d.DateNextAttempt.Sub(now)
+			assert.True(t, delay >= RetryBaseDelay/2 && delay <= RetryBaseDelay+time.Second, delay.String())
+		}
+	}
+
+	// not ready yet
+	processed, _ = svc.ProcessNext(now)
+	assert.False(t, processed)
+
+	// second attempt works
+	processed, _ = svc.ProcessNext(now.Add(RetryBaseDelay + time.Second))
+	if assert.True(t, processed) {
+		d := db.getDeliveries()[0]
+		assert.Equal(t, model.DeliveryStatusSent, d.Status)
+		assert.Equal(t, 2, d.Attempts)
+		assert.NotNil(t, d.DateSent)
+		assert.Nil(t, d.DateNextAttempt)
+	}
+
+	// every attempt is recorded
+	if assert.Len(t, db.attempts, 2) {
+		assert.Equal(t, model.DeliveryStatusFailed, db.attempts[0].Status)
+		assert.Equal(t, model.DeliveryStatusSent, db.attempts[1].Status)
+		assert.Equal(t, 200, *db.attempts[1].StatusCode)
+	}
+
+	// the event is delivered as it was queued
+	if assert.Equal(t, 2, notifier.count()) {
+		assert.Equal(t, "Automatic (every 15 minutes)", notifier.messages[1].Schedule())
+		assert.Equal(t, model.AlertEventJobDown, notifier.messages[1].Event.Type)
+	}
+}
+
+func TestQueueDeadAfterMaxAttempts(t *testing.T) {
+	svc, notifier := getServiceMock()
+	db := svc.database.(*DBMock)
+	notifier.err = errors.New("remote down")
+	svc.SetMaxAttempts(3)
+
+	svc.Enqueue(getAlertEvent(1))
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		processed, _ := svc.ProcessNext(now)
+		assert.True(t, processed)
+		now = now.Add(RetryMaxDelay + time.Second)
+	}
+
+	// assertions
+	d := db.getDeliveries()[0]
+	assert.Equal(t, model.DeliveryStatusDead, d.Status)
+	assert.Equal(t, 3, d.Attempts)
+	assert.Nil(t, d.DateNextAttempt)
+
+	processed, _ := svc.ProcessNext(now)
+	assert.False(t, processed)
+}
+
+func TestQueuePermanentError(t *testing.T) {
+	svc, notifier := getServiceMock()
+	db := svc.database.(*DBMock)
+	notifier.err = &PermanentError{errors.New("channel_not_found")}
+
+	svc.Enqueue(getAlertEvent(1))
+	svc.ProcessNext(time.Now())
+
+	// assertions
+	d := db.getDeliveries()[0]
+	assert.Equal(t, model.DeliveryStatusDead, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+}
+
+func TestQueueDeletedChannel(t *testing.T) {
+	svc, _ := getServiceMock()
+	db := svc.database.(*DBMock)
+
+	svc.Enqueue(getAlertEvent(99))
+	svc.ProcessNext(time.Now())
+
+	// assertions
+	assert.Equal(t, model.DeliveryStatusDead, db.getDeliveries()[0].Status)
+}
+
+func TestQueueClaim(t *testing.T) {
+	svc, _ := getServiceMock()
+	db := svc.database.(*DBMock)
+	svc.Enqueue(getAlertEvent(1))
+
+	now := time.Now()
+	d, err := db.ClaimDelivery("worker-1", now, now.Add(time.Minute))
+	if assert.NoError(t, err) {
+		// another worker can't take it while claimed
+		_, err = db.ClaimDelivery("worker-2", now, now.Add(time.Minute))
+		assert.Error(t, err)
+
+		// but it can when the claim expires
+		_, err = db.ClaimDelivery("worker-2", now.Add(2*time.Minute), now.Add(3*time.Minute))
+		assert.NoError(t, err)
+
+		// and the first worker can't save its result
+		d.Status = model.DeliveryStatusSent
+		updated, _ := db.CompleteDelivery(&d, "worker-1", &model.NotificationAttempt{})
+		assert.False(t, updated)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts := 1; attempts <= 40; attempts++ {
+		want := RetryMaxDelay
+		if attempts < 10 {
+			if d := RetryBaseDelay << uint(attempts-1); d < RetryMaxDelay {
+				want = d
+			}
+		}
+
+		delay := retryDelay(attempts)
+		assert.True(t, delay >= want/2 && delay <= want, "attempt %d: %s", attempts, delay)
+	}
+}
+
+func TestRetryDelivery(t *testing.T) {
+	svc, notifier := getServiceMock()
+	db := svc.database.(*DBMock)
+	notifier.err = &PermanentError{errors.New("channel_not_found")}
+
+	svc.Enqueue(getAlertEvent(1))
+	svc.Enqueue(getAlertEvent(1))
+	svc.ProcessNext(time.Now())
+
+	cases := []struct {
+		name       string
+		idDelivery int
+		idUser     int
+		status     int
+	}{
+		{name: "Other user", idDelivery: 1, idUser: 2, status: http.StatusForbidden},
+		{name: "Unknown delivery", idDelivery: 99, idUser: 1, status: http.StatusNotFound},
+		{name: "Not dead", idDelivery: 2, idUser: 1, status: http.StatusBadRequest},
+		{name: "Dead", idDelivery: 1, idUser: 1},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.RetryDelivery(tt.idDelivery, tt.idUser)
+			if tt.status == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+		})
+	}
+
+	// the delivery is queued again
+	notifier.err = nil
+	processed, _ := svc.ProcessNext(time.Now())
+	if assert.True(t, processed) {
+		assert.Equal(t, model.DeliveryStatusSent, db.getDeliveries()[0].Status)
+	}
+}
+
+func TestQueuePayloadWithoutSecrets(t *testing.T) {
+	svc, notifier := getServiceMock()
+	db := svc.database.(*DBMock)
+
+	secret := "whsec_job"
+	lastPing := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	e := getAlertEvent(1)
+	e.Job.PingKey = "8d3f4b1c2a9e4f6b8c7d5e3a1b2c4d6e"
+	e.Job.PingSecret = &secret
+	signed := true
+	e.Job.SignedPings = &signed
+	e.Job.AllowedIPs = model.IPAllowList{"203.0.113.0/24"}
+	e.Job.Status = model.JobStatusError
+	e.Job.DateLastPing = &lastPing
+	e.Job.Labels = model.JobLabels{"env": "prod"}
+	assert.NoError(t, svc.Enqueue(e))
+
+	// assertions
+	payload := db.getDeliveries()[0].Payload
+	for _, s := range []string{secret, e.Job.PingKey, "ping_secret", "ping_key", "allowed_ips", "203.0.113.0/24"} {
+		assert.NotContains(t, payload, s)
+	}
+
+	// the notifiers get the fields they use
+	processed, err := svc.ProcessNext(time.Now())
+	if assert.NoError(t, err) && assert.True(t, processed) && assert.Equal(t, 1, notifier.count()) {
+		job := notifier.messages[0].Event.Job
+		assert.Equal(t, model.Job{ID: "job-1", IDUser: 1, Name: "Backup", Status: model.JobStatusError, DateLastPing: &lastPing, Labels: e.Job.Labels}, job)
+	}
+}
@@ -0,0 +1,96 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/api/notification/platform/db"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Service holds the functions delcared in the service interface
+type Service interface {
+	GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+	RetryDelivery(idDelivery, idUser int) (err error)
+	GetChannelDeliveries(idChannel, idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+	GetJobDeliveries(idJob string, idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+	SendTestNotification(ctx context.Context, idChannel, idUser int) (r TestResult, err error)
+}
+
+// DB holds the functions for database access
+type DB interface {
+	Transaction() *gorm.DB
+
+	GetChannel(idChannel int) (channel model.Channel, err error)
+	GetJob(idJob string) (job model.Job, err error)
+
+	// Deliveries
+	CreateDelivery(d *model.NotificationDelivery) (err error)
+	ClaimDelivery(token string, now, claimUntil time.Time) (d model.NotificationDelivery, err error)
+	CompleteDelivery(d *model.NotificationDelivery, token string, attempt *model.NotificationAttempt) (updated bool, err error)
+	GetDelivery(idDelivery int) (d model.NotificationDelivery, err error)
+	GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+	RequeueDelivery(idDelivery int, now time.Time) (updated bool, err error)
+	GetChannelDeliveries(idChannel int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+	GetJobDeliveries(idJob string, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error)
+}
+
+// Notifier delivers messages through a specific channel type
+type Notifier interface {
+	Notify(ctx context.Context, channel *model.Channel, msg *Message) (r Result, err error)
+}
+
+// Result contains the outcome of a delivery
+type Result struct {
+	StatusCode int    `json:"status_code,omitempty"`
+	Response   string `json:"response,omitempty"`
+}
+
+// Notification defines the module for notification related operations
+type Notification struct {
+	database   DB
+	logger     *log.Log
+	notifiers  map[string]Notifier
+	webBaseURL string
+	timeout    time.Duration
+	attempts   int
+}
+
+// creates new notification service
+func new(database DB, l *log.Log, webBaseURL string) *Notification {
+	return &Notification{
+		database:   database,
+		logger:     l,
+		notifiers:  make(map[string]Notifier),
+		webBaseURL: webBaseURL,
+		timeout:    DefaultDeliveryTimeout,
+	}
+}
+
+// Initialize initializes notification service; `webBaseURL` is used
+// to link the jobs from the messages
+func Initialize(ds *gorm.DB, dbService DB, l *log.Log, webBaseURL string) *Notification {
+	if dbService == nil {
+		dbService = db.NewNotificationDB(ds)
+	}
+	return new(dbService, l, webBaseURL)
+}
+
+// RegisterNotifier sets the notifier used to deliver messages to channels of `channelType`
+func (n *Notification) RegisterNotifier(channelType string, notifier Notifier) {
+	n.notifiers[channelType] = notifier
+}
+
+// SetDeliveryTimeout sets the max time a single delivery attempt can take
+func (n *Notification) SetDeliveryTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		n.timeout = timeout
+	}
+}
+
+// SetMaxAttempts sets the number of attempts before a delivery is dead
+func (n *Notification) SetMaxAttempts(attempts int) {
+	n.attempts = attempts
+}
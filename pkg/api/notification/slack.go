@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// SlackNotifier delivers messages to channels of type `ChannelTypeSlack`,
+// using Slack incoming webhooks
+type SlackNotifier struct {
+	client *http.Client
+}
+
+// NewSlackNotifier returns a notifier that posts to Slack using `client`;
+// by default it uses `NewDeliveryClient`
+func NewSlackNotifier(client *http.Client) *SlackNotifier {
+	if client == nil {
+		client = NewDeliveryClient()
+	}
+	return &SlackNotifier{client: client}
+}
+
+type slackPayload struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Fields   []slackText   `json:"fields,omitempty"`
+	Elements []slackButton `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+	URL  string    `json:"url"`
+}
+
+// Notify posts the message to the webhook URL configured in the channel
+func (n *SlackNotifier) Notify(ctx context.Context, channel *model.Channel, msg *Message) (r Result, err error) {
+
+	cs := channel.GetChannelSlack()
+	if cs.BaseURL == "" {
+		return r, &PermanentError{errors.New("channel without webhook URL")}
+	}
+
+	body, err := json.Marshal(buildSlackPayload(&cs, msg))
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cs.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return r, &PermanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	r.StatusCode = resp.StatusCode
+	r.Response = readResponse(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("slack error: %d %s", resp.StatusCode, r.Response)
+
+		// Slack answers with a 4xx status when the payload or the channel is wrong
+		// (e.g. `channel_not_found`, `channel_is_archived`, `invalid_token`)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			err = &PermanentError{err}
+		}
+	}
+
+	return
+}
+
+func buildSlackPayload(cs *model.ChannelSlack, msg *Message) (p slackPayload) {
+	p.Text = msg.Subject
+	if cs.SlackChannelName != nil && *cs.SlackChannelName != "" {
+		p.Channel = *cs.SlackChannelName
+	}
+
+	icon := ":red_circle:"
+	switch msg.Event.Type {
+	case model.AlertEventJobRecovered:
+		icon = ":large_green_circle:"
+	case model.AlertEventTest:
+		icon = ":large_blue_circle:"
+	}
+
+	fields := []slackText{
+		{Type: "mrkdwn", Text: "*Job*\n" + slackEscape(msg.Event.Job.Name)},
+		{Type: "mrkdwn", Text: "*Status*\n" + msg.Status()},
+		{Type: "mrkdwn", Text: "*Schedule*\n" + slackEscape(msg.Schedule())},
+		{Type: "mrkdwn", Text: "*Last check-in*\n" + msg.FormatDate(msg.Event.Job.DateLastPing)},
+	}
+	if len(msg.Event.Job.Labels) > 0 {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*Labels*\n" + slackEscape(msg.Event.Job.Labels.String())})
+	}
+	if exitCode := msg.ExitCode(); exitCode != "" {
+		fields = append(fields, slackText{Type: "mrkdwn", Text: "*Exit code*\n" + exitCode})
+	}
+
+	p.Blocks = append(p.Blocks,
+		slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("%s *%s*\n%s", icon, slackEscape(msg.Subject), slackEscape(msg.Text))},
+		},
+		slackBlock{
+			Type:   "section",
+			Fields: fields,
+		},
+	)
+
+	if output := msg.Output(); output != "" {
+		p.Blocks = append(p.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: "*Output*\n```" + slackEscape(output) + "```"},
+		})
+	}
+
+	if msg.JobURL != "" {
+		p.Blocks = append(p.Blocks, slackBlock{
+			Type: "actions",
+			Elements: []slackButton{{
+				Type: "button",
+				Text: slackText{Type: "plain_text", Text: "View job"},
+				URL:  msg.JobURL,
+			}},
+		})
+	}
+
+	return
+}
+
+// escapes the characters that have a special meaning in Slack messages
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// reads the beginning of a remote response
+func readResponse(body io.Reader) string {
+	b, _ := ioutil.ReadAll(io.LimitReader(body, MaxResponseLength))
+	return string(b)
+}
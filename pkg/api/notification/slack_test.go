@@ -0,0 +1,105 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getSlackChannel(url string, channelName *string) *model.Channel {
+	c := &model.Channel{ID: 2, IDUser: 1, Type: model.ChannelTypeSlack}
+	c.SetChannelSlack(model.ChannelSlack{BaseURL: url, SlackChannelName: channelName})
+	return c
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var payload slackPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	e := getAlertEvent(2)
+	e.Job.JobType = model.JobTypeCron
+	e.Job.CronExpression = strPtr("0 * * * *")
+	msg := NewMessage(e)
+	msg.JobURL = "https://app.cronspy.com/jobs/job-1"
+
+	r, err := NewSlackNotifier(http.DefaultClient).Notify(context.Background(), getSlackChannel(ts.URL, strPtr("#alerts")), msg)
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, r.StatusCode)
+		assert.Equal(t, "ok", r.Response)
+		assert.Equal(t, "#alerts", payload.Channel)
+		assert.Equal(t, "Job 'Backup' is down", payload.Text)
+		if assert.Len(t, payload.Blocks, 3) {
+			assert.Contains(t, payload.Blocks[1].Fields[1].Text, "Up → Down")
+			assert.Contains(t, payload.Blocks[1].Fields[2].Text, "0 * * * * (UTC)")
+			assert.Contains(t, payload.Blocks[1].Fields[3].Text, "never")
+			assert.Equal(t, "https://app.cronspy.com/jobs/job-1", payload.Blocks[2].Elements[0].URL)
+		}
+	}
+
+	// labels are added as another field
+	e.Job.Labels = model.JobLabels{"env": "prod"}
+	p := buildSlackPayload(&model.ChannelSlack{}, NewMessage(e))
+	if assert.Len(t, p.Blocks[1].Fields, 5) {
+		assert.Equal(t, "*Labels*\nenv=prod", p.Blocks[1].Fields[4].Text)
+	}
+
+	// the output is added in its own block
+	e.Ping = &model.JobPing{Kind: model.PingKindFail, Body: strPtr("cp: <src> not found"), ExitCode: intPtr(1)}
+	p = buildSlackPayload(&model.ChannelSlack{}, NewMessage(e))
+	if assert.Len(t, p.Blocks, 3) && assert.Len(t, p.Blocks[1].Fields, 6) {
+		assert.Equal(t, "*Exit code*\n1", p.Blocks[1].Fields[5].Text)
+		assert.Equal(t, "*Output*\n```cp: &lt;src&gt; not found```", p.Blocks[2].Text.Text)
+	}
+}
+
+func TestSlackNotifierErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    int
+		response  string
+		permanent bool
+	}{
+		{name: "Channel not found", status: http.StatusNotFound, response: "channel_not_found", permanent: true},
+		{name: "Channel archived", status: http.StatusGone, response: "channel_is_archived", permanent: true},
+		{name: "Invalid payload", status: http.StatusBadRequest, response: "invalid_payload", permanent: true},
+		{name: "Rate limited", status: http.StatusTooManyRequests, response: "rate_limited"},
+		{name: "Server error", status: http.StatusInternalServerError, response: "rollup_error"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+
+			r, err := NewSlackNotifier(http.DefaultClient).Notify(context.Background(), getSlackChannel(ts.URL, nil), NewMessage(getAlertEvent(2)))
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.permanent, IsPermanent(err))
+				assert.Equal(t, tt.status, r.StatusCode)
+				assert.Equal(t, tt.response, r.Response)
+			}
+		})
+	}
+}
+
+func TestSlackNotifierWithoutURL(t *testing.T) {
+	_, err := NewSlackNotifier(nil).Notify(context.Background(), getSlackChannel("", nil), NewMessage(getAlertEvent(2)))
+
+	// assertions
+	assert.True(t, IsPermanent(err))
+}
@@ -0,0 +1,232 @@
+package transport
+
+import (
+	"cronspy/backend/pkg/api/notification"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/model"
+	"net/http"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var (
+	// IsUserLoggedIn is a middleware to restrict URL to logged user
+	IsUserLoggedIn echo.MiddlewareFunc
+)
+
+// response of the endpoints that list deliveries
+type deliveriesResponse struct {
+	Deliveries []model.NotificationDelivery `json:"deliveries"`
+	Pagination model.Pagination             `json:"pagination"`
+}
+
+// HTTP represents notification http service
+type HTTP struct {
+	svc              notification.Service
+	jwtSigningKey    string
+	jwtSigningMethod *jwt.SigningMethodHMAC
+}
+
+// NewHTTP creates new http service to handle request to /notifications
+func NewHTTP(svc notification.Service, jwtSigningKey string, jwtSigningMethod *jwt.SigningMethodHMAC, e *echo.Echo) {
+	h := HTTP{
+		svc:              svc,
+		jwtSigningKey:    jwtSigningKey,
+		jwtSigningMethod: jwtSigningMethod,
+	}
+
+	// define logged user check function
+	IsUserLoggedIn = middleware.JWTWithConfig(h.getJWTConfig())
+
+	// configure routes
+	notifications := e.Group("/notifications")
+	notifications.GET("/dead", h.deadDeliveriesHandler, IsUserLoggedIn)               // get deliveries that ran out of attempts
+	notifications.POST("/:delivery-id/retry", h.retryDeliveryHandler, IsUserLoggedIn) // queue a dead delivery again
+
+	e.GET("/channels/:channel-id/deliveries", h.channelDeliveriesHandler, IsUserLoggedIn) // get deliveries sent to a channel
+	e.POST("/channels/:channel-id/test", h.testChannelHandler, IsUserLoggedIn)            // send a test notification to a channel
+	e.GET("/jobs/:job-id/notifications", h.jobDeliveriesHandler, IsUserLoggedIn)          // get deliveries of the alerts of a job
+}
+
+func (h *HTTP) getJWTConfig() (jwtCfg middleware.JWTConfig) {
+	jwtCfg.SigningMethod = h.jwtSigningMethod.Name
+	jwtCfg.SigningKey = []byte(h.jwtSigningKey)
+	return
+}
+
+//
+// --- GET DEAD DELIVERIES ---
+//
+func (h *HTTP) deadDeliveriesHandler(c echo.Context) error {
+
+	// get user id
+	idUser, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	page, pageSize, err := h.getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, p, err := h.svc.GetDeadDeliveries(idUser, pageSize, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, deliveriesResponse{Deliveries: deliveries, Pagination: p})
+}
+
+//
+// --- GET CHANNEL DELIVERIES ---
+//
+func (h *HTTP) channelDeliveriesHandler(c echo.Context) error {
+
+	// get user id
+	idUser, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// get channel id from path
+	idChannel, errConv := strconv.Atoi(c.Param("channel-id"))
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
+	}
+
+	page, pageSize, err := h.getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, p, err := h.svc.GetChannelDeliveries(idChannel, idUser, pageSize, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, deliveriesResponse{Deliveries: deliveries, Pagination: p})
+}
+
+//
+// --- TEST CHANNEL ---
+//
+func (h *HTTP) testChannelHandler(c echo.Context) error {
+
+	// get user id
+	idUser, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// get channel id from path
+	idChannel, errConv := strconv.Atoi(c.Param("channel-id"))
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
+	}
+
+	r, err := h.svc.SendTestNotification(c.Request().Context(), idChannel, idUser)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+//
+// --- GET JOB DELIVERIES ---
+//
+func (h *HTTP) jobDeliveriesHandler(c echo.Context) error {
+
+	// get user id
+	idUser, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// job ID must be a valid UUID
+	idJob := c.Param("job-id")
+	if _, errParse := uuid.Parse(idJob); errParse != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, ""))
+	}
+
+	page, pageSize, err := h.getPagination(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, p, err := h.svc.GetJobDeliveries(idJob, idUser, pageSize, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, deliveriesResponse{Deliveries: deliveries, Pagination: p})
+}
+
+//
+// --- RETRY DELIVERY ---
+//
+func (h *HTTP) retryDeliveryHandler(c echo.Context) error {
+
+	// get user id
+	idUser, err := h.getUserID(c)
+	if err != nil {
+		return err
+	}
+
+	// get delivery id from path
+	idDelivery, errConv := strconv.Atoi(c.Param("delivery-id"))
+	if errConv != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidEntityID, errConv.Error()))
+	}
+
+	if err := h.svc.RetryDelivery(idDelivery, idUser); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+//
+// --- private methods ---
+//
+
+// get user ID from request context (must be authenticated)
+func (h *HTTP) getUserID(c echo.Context) (id int, err error) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	idUser, okID := claims["id"].(float64)
+
+	if !okID {
+		err = echo.NewHTTPError(http.StatusForbidden, exception.GetErrorMap(exception.CodeUnauthorized, ""))
+		return
+	}
+
+	id = int(idUser)
+	return
+}
+
+// get the `page` and `page_size` query params
+func (h *HTTP) getPagination(c echo.Context) (page, pageSize int, err error) {
+	page, pageSize = 1, notification.DefaultPageSize
+
+	if pageStr := c.QueryParam("page"); pageStr != "" {
+		if page, err = strconv.Atoi(pageStr); err != nil || page < 1 {
+			err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPage, ""))
+			return
+		}
+	}
+
+	if pageSizeStr := c.QueryParam("page_size"); pageSizeStr != "" {
+		if pageSize, err = strconv.Atoi(pageSizeStr); err != nil || pageSize < 1 || pageSize > notification.MaxPageSize {
+			err = echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPageSize, ""))
+			return
+		}
+	}
+
+	return
+}
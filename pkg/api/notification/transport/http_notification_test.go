@@ -0,0 +1,312 @@
+package transport
+
+import (
+	"cronspy/backend/pkg/api/notification"
+	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ****************************************************
+//
+// DATABASE MOCK
+//
+// ****************************************************
+
+// DBMock implements the methods used by the HTTP handlers; any other call panics
+type DBMock struct {
+	notification.DB
+
+	channels   []model.Channel
+	jobs       []model.Job
+	deliveries []model.NotificationDelivery
+}
+
+func getDBMock() *DBMock {
+	now := time.Now()
+	return &DBMock{
+		channels: []model.Channel{
+			{ID: 1, IDUser: 1, Name: "Hooks", Type: model.ChannelTypeWebHook},
+			{ID: 2, IDUser: 2, Name: "Slack", Type: model.ChannelTypeSlack},
+		},
+		jobs: []model.Job{
+			{ID: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d01", IDUser: 1, Name: "Backup"},
+			{ID: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d02", IDUser: 2, Name: "Reports"},
+		},
+		deliveries: []model.NotificationDelivery{
+			{ID: 1, IDUser: 1, IDJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d01", IDChannel: 1, Status: model.DeliveryStatusDead, Attempts: 8, DateCreated: now},
+			{ID: 2, IDUser: 1, IDJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d01", IDChannel: 1, Status: model.DeliveryStatusSent, Attempts: 1, DateCreated: now},
+			{ID: 3, IDUser: 2, IDJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d02", IDChannel: 2, Status: model.DeliveryStatusDead, Attempts: 8, DateCreated: now},
+		},
+	}
+}
+
+func (db *DBMock) GetChannel(idChannel int) (channel model.Channel, err error) {
+	for i := range db.channels {
+		if db.channels[i].ID == idChannel {
+			return db.channels[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetJob(idJob string) (job model.Job, err error) {
+	for i := range db.jobs {
+		if db.jobs[i].ID == idJob {
+			return db.jobs[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetChannelDeliveries(idChannel int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	for i := range db.deliveries {
+		if db.deliveries[i].IDChannel == idChannel {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+func (db *DBMock) GetJobDeliveries(idJob string, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	for i := range db.deliveries {
+		if db.deliveries[i].IDJob == idJob {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+func (db *DBMock) GetDelivery(idDelivery int) (d model.NotificationDelivery, err error) {
+	for i := range db.deliveries {
+		if db.deliveries[i].ID == idDelivery {
+			return db.deliveries[i], nil
+		}
+	}
+	err = exception.ErrRecordNotFound
+	return
+}
+
+func (db *DBMock) GetDeadDeliveries(idUser int, pageSize, page int) (deliveries []model.NotificationDelivery, p model.Pagination, err error) {
+	for i := range db.deliveries {
+		if db.deliveries[i].IDUser == idUser && db.deliveries[i].Status == model.DeliveryStatusDead {
+			deliveries = append(deliveries, db.deliveries[i])
+		}
+	}
+	p = model.Pagination{Page: page, PageSize: pageSize, TotalRows: len(deliveries)}
+	return
+}
+
+func (db *DBMock) RequeueDelivery(idDelivery int, now time.Time) (updated bool, err error) {
+	for i := range db.deliveries {
+		if db.deliveries[i].ID == idDelivery && db.deliveries[i].Status == model.DeliveryStatusDead {
+			db.deliveries[i].Status = model.DeliveryStatusQueued
+			updated = true
+		}
+	}
+	return
+}
+
+// runs a request against a handler, authenticated as `idUser`
+func runRequest(mockDB *DBMock, idUser int, method, target string, params map[string]string, handler func(h HTTP) echo.HandlerFunc) (rec *httptest.ResponseRecorder, err error) {
+	e := echo.New()
+	h := HTTP{svc: notification.Initialize(nil, mockDB, log.New(), ""), jwtSigningKey: "myTestingKey", jwtSigningMethod: jwt.SigningMethodHS512}
+
+	req := httptest.NewRequest(method, target, nil)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	for k, v := range params {
+		c.SetParamNames(k)
+		c.SetParamValues(v)
+	}
+
+	token := jwt.New(jwt.SigningMethodHS512)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["id"] = float64(idUser)
+	claims["email"] = fmt.Sprintf("test.user.%d@cronspy.com", idUser)
+	c.Set("user", token)
+
+	err = handler(h)(c)
+	return
+}
+
+func deadDeliveriesHandler(h HTTP) echo.HandlerFunc {
+	return h.deadDeliveriesHandler
+}
+
+func retryDeliveryHandler(h HTTP) echo.HandlerFunc {
+	return h.retryDeliveryHandler
+}
+
+func channelDeliveriesHandler(h HTTP) echo.HandlerFunc {
+	return h.channelDeliveriesHandler
+}
+
+func testChannelHandler(h HTTP) echo.HandlerFunc {
+	return h.testChannelHandler
+}
+
+func jobDeliveriesHandler(h HTTP) echo.HandlerFunc {
+	return h.jobDeliveriesHandler
+}
+
+func TestDeadDeliveries(t *testing.T) {
+	rec, err := runRequest(getDBMock(), 1, http.MethodGet, "/?page_size=5", nil, deadDeliveriesHandler)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var resp struct {
+			Deliveries []model.NotificationDelivery `json:"deliveries"`
+			Pagination model.Pagination             `json:"pagination"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+
+		if assert.Len(t, resp.Deliveries, 1) {
+			assert.Equal(t, 1, resp.Deliveries[0].ID)
+			assert.Equal(t, model.DeliveryStatusDead, resp.Deliveries[0].Status)
+		}
+		assert.Equal(t, model.Pagination{Page: 1, PageSize: 5, TotalRows: 1}, resp.Pagination)
+	}
+}
+
+func TestDeadDeliveriesInvalidPagination(t *testing.T) {
+	targets := []string{"/?page=abc", "/?page=0", "/?page_size=-1", fmt.Sprintf("/?page_size=%d", notification.MaxPageSize+1)}
+	for _, target := range targets {
+		_, err := runRequest(getDBMock(), 1, http.MethodGet, target, nil, deadDeliveriesHandler)
+		if assert.Error(t, err, target) {
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		}
+	}
+}
+
+func TestRetryDelivery(t *testing.T) {
+	mockDB := getDBMock()
+
+	cases := []struct {
+		name       string
+		idDelivery string
+		status     int
+	}{
+		{name: "Dead", idDelivery: "1", status: http.StatusOK},
+		{name: "Not dead", idDelivery: "2", status: http.StatusBadRequest},
+		{name: "Other user", idDelivery: "3", status: http.StatusForbidden},
+		{name: "Unknown", idDelivery: "99", status: http.StatusNotFound},
+		{name: "Invalid ID", idDelivery: "abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := runRequest(mockDB, 1, http.MethodPost, "/", map[string]string{"delivery-id": tt.idDelivery}, retryDeliveryHandler)
+			if err != nil {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			} else {
+				assert.Equal(t, tt.status, rec.Code)
+			}
+		})
+	}
+
+	assert.Equal(t, model.DeliveryStatusQueued, mockDB.deliveries[0].Status)
+}
+
+func TestChannelDeliveries(t *testing.T) {
+	cases := []struct {
+		name      string
+		idChannel string
+		status    int
+		count     int
+	}{
+		{name: "Owner", idChannel: "1", status: http.StatusOK, count: 2},
+		{name: "Other user", idChannel: "2", status: http.StatusForbidden},
+		{name: "Unknown", idChannel: "99", status: http.StatusNotFound},
+		{name: "Invalid ID", idChannel: "abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := runRequest(getDBMock(), 1, http.MethodGet, "/", map[string]string{"channel-id": tt.idChannel}, channelDeliveriesHandler)
+			if err != nil {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+				return
+			}
+
+			if assert.Equal(t, tt.status, rec.Code) {
+				var resp struct {
+					Deliveries []model.NotificationDelivery `json:"deliveries"`
+					Pagination model.Pagination             `json:"pagination"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Len(t, resp.Deliveries, tt.count)
+				assert.Equal(t, model.Pagination{Page: 1, PageSize: notification.DefaultPageSize, TotalRows: tt.count}, resp.Pagination)
+			}
+		})
+	}
+}
+
+func TestTestChannel(t *testing.T) {
+	cases := []struct {
+		name      string
+		idChannel string
+		status    int
+	}{
+		{name: "Without notifier", idChannel: "1", status: http.StatusBadRequest},
+		{name: "Other user", idChannel: "2", status: http.StatusForbidden},
+		{name: "Unknown", idChannel: "99", status: http.StatusNotFound},
+		{name: "Invalid ID", idChannel: "abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runRequest(getDBMock(), 1, http.MethodPost, "/", map[string]string{"channel-id": tt.idChannel}, testChannelHandler)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+			}
+		})
+	}
+}
+
+func TestJobDeliveries(t *testing.T) {
+	cases := []struct {
+		name   string
+		idJob  string
+		status int
+		count  int
+	}{
+		{name: "Owner", idJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d01", status: http.StatusOK, count: 2},
+		{name: "Other user", idJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d02", status: http.StatusForbidden},
+		{name: "Unknown", idJob: "8f2f3b1e-3c1c-4f4a-9a57-1b8a7f6c2d99", status: http.StatusNotFound},
+		{name: "Invalid ID", idJob: "abc", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := runRequest(getDBMock(), 1, http.MethodGet, "/", map[string]string{"job-id": tt.idJob}, jobDeliveriesHandler)
+			if err != nil {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code)
+				return
+			}
+
+			if assert.Equal(t, tt.status, rec.Code) {
+				var resp struct {
+					Deliveries []model.NotificationDelivery `json:"deliveries"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+				assert.Len(t, resp.Deliveries, tt.count)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/webhook"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WebHookPayloadVersion is the version of `WebHookPayload`
+const WebHookPayloadVersion = "1"
+
+// WebHookPayload is the body posted to web hook channels. It's sent as JSON, or
+// as form fields with the same names when the payload type of the channel is
+// `FORM`; dates use RFC 3339 and empty fields are omitted.
+//
+// New fields can be added within the same version, so receivers must ignore
+// the ones they don't know; renaming or removing a field requires a new version.
+type WebHookPayload struct {
+	// payload version, currently "1"
+	Version string `json:"version"`
+
+	// JOB_DOWN, JOB_FAILED or JOB_RECOVERED; TEST for the test notifications
+	// sent on demand, which describe an example job
+	Event string `json:"event"`
+
+	// when the event happened
+	DateCreated time.Time `json:"date_created"`
+
+	JobID       string `json:"job_id"`
+	JobName     string `json:"job_name"`
+	JobStatus   string `json:"job_status"`
+	JobSchedule string `json:"job_schedule"`
+	JobURL      string `json:"job_url,omitempty"`
+
+	// labels of the job; sent as one `key=value` form field per label
+	JobLabels model.JobLabels `json:"job_labels,omitempty"`
+
+	// scheduled run that was missed; only for JOB_DOWN
+	DateExpected *time.Time `json:"date_expected,omitempty"`
+
+	// last ping received from the job
+	DateLastPing *time.Time `json:"date_last_ping,omitempty"`
+
+	// end of the output and exit code sent by the ping that caused the event
+	Output   string `json:"output,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+
+	// human readable description of the event
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+// NewWebHookPayload builds the web hook payload for a message
+func NewWebHookPayload(msg *Message) WebHookPayload {
+	e := msg.Event
+	p := WebHookPayload{
+		Version:      WebHookPayloadVersion,
+		Event:        e.Type,
+		DateCreated:  e.DateCreated,
+		JobID:        e.Job.ID,
+		JobName:      e.Job.Name,
+		JobStatus:    e.Job.Status,
+		JobSchedule:  msg.Schedule(),
+		JobURL:       msg.JobURL,
+		JobLabels:    e.Job.Labels,
+		DateExpected: e.DateExpected,
+		DateLastPing: e.Job.DateLastPing,
+		Output:       msg.Output(),
+		Subject:      msg.Subject,
+		Message:      msg.Text,
+	}
+	if e.Ping != nil {
+		p.ExitCode = e.Ping.ExitCode
+	}
+	return p
+}
+
+// form returns the payload as form values
+func (p *WebHookPayload) form() url.Values {
+	v := url.Values{}
+	v.Set("version", p.Version)
+	v.Set("event", p.Event)
+	v.Set("date_created", p.DateCreated.Format(time.RFC3339))
+	v.Set("job_id", p.JobID)
+	v.Set("job_name", p.JobName)
+	v.Set("job_status", p.JobStatus)
+	v.Set("job_schedule", p.JobSchedule)
+	if p.JobURL != "" {
+		v.Set("job_url", p.JobURL)
+	}
+	for _, l := range p.JobLabels.Pairs() {
+		v.Add("job_labels", l)
+	}
+	if p.DateExpected != nil {
+		v.Set("date_expected", p.DateExpected.Format(time.RFC3339))
+	}
+	if p.DateLastPing != nil {
+		v.Set("date_last_ping", p.DateLastPing.Format(time.RFC3339))
+	}
+	if p.Output != "" {
+		v.Set("output", p.Output)
+	}
+	if p.ExitCode != nil {
+		v.Set("exit_code", strconv.Itoa(*p.ExitCode))
+	}
+	v.Set("subject", p.Subject)
+	v.Set("message", p.Message)
+	return v
+}
+
+// WebHookNotifier delivers messages to channels of type `ChannelTypeWebHook`
+type WebHookNotifier struct {
+	client *http.Client
+}
+
+// NewWebHookNotifier returns a notifier that posts to web hooks using
+// `client`; by default it uses `NewDeliveryClient`
+func NewWebHookNotifier(client *http.Client) *WebHookNotifier {
+	if client == nil {
+		client = NewDeliveryClient()
+	}
+	return &WebHookNotifier{client: client}
+}
+
+// Notify posts the message to the URL configured in the channel; any
+// response other than 2xx is considered an error
+func (n *WebHookNotifier) Notify(ctx context.Context, channel *model.Channel, msg *Message) (r Result, err error) {
+
+	cwh := channel.GetChannelWebHook()
+	if u, errURL := url.Parse(cwh.BaseURL); errURL != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return r, &PermanentError{errors.New("invalid web hook URL")}
+	}
+
+	body, contentType, err := encodeWebHookPayload(NewWebHookPayload(msg), cwh.PayloadType)
+	if err != nil {
+		return r, &PermanentError{err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cwh.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return r, &PermanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", WebHookUserAgent)
+
+	if cwh.SigningSecret != nil && *cwh.SigningSecret != "" {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(*cwh.SigningSecret, time.Now(), body))
+	}
+
+	if cwh.BasicAuthUsername != nil {
+		password := ""
+		if cwh.BasicAuthPassword != nil {
+			password = *cwh.BasicAuthPassword
+		}
+		req.SetBasicAuth(*cwh.BasicAuthUsername, password)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	r.StatusCode = resp.StatusCode
+	r.Response = readResponse(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("web hook error: %d", resp.StatusCode)
+
+		// client errors won't change by retrying, except timeouts and rate limits
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			err = &PermanentError{err}
+		}
+	}
+
+	return
+}
+
+// encodes the payload according to the payload type of the channel
+func encodeWebHookPayload(p WebHookPayload, payloadType string) (body []byte, contentType string, err error) {
+	switch payloadType {
+	case model.WebHookPayloadJSON, "":
+		body, err = json.Marshal(p)
+		contentType = "application/json"
+	case model.WebHookPayloadForm:
+		body = []byte(p.form().Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		err = fmt.Errorf("payload type '%s' not supported", payloadType)
+	}
+	return
+}
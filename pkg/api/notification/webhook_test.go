@@ -0,0 +1,280 @@
+package notification
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/webhook"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getWebHookChannel(baseURL, payloadType string, username, password *string) *model.Channel {
+	c := &model.Channel{ID: 1, IDUser: 1, Type: model.ChannelTypeWebHook}
+	c.SetChannelWebHook(model.ChannelWebHook{BaseURL: baseURL, PayloadType: payloadType, BasicAuthUsername: username, BasicAuthPassword: password})
+	return c
+}
+
+// web hook receiver that keeps the last request
+type webHookReceiver struct {
+	*httptest.Server
+	req    *http.Request
+	body   []byte
+	status int
+}
+
+func newWebHookReceiver(status int, delay time.Duration) *webHookReceiver {
+	r := &webHookReceiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		r.req = req
+		r.body, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(r.status)
+		w.Write([]byte("received"))
+	}))
+	return r
+}
+
+func TestWebHookNotifierJSON(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusOK, 0)
+	defer ts.Close()
+
+	res, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(),
+		getWebHookChannel(ts.URL, model.WebHookPayloadJSON, strPtr("user"), strPtr("pass")), NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "received", res.Response)
+		assert.Equal(t, "application/json", ts.req.Header.Get("Content-Type"))
+
+		username, password, ok := ts.req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+
+		var p WebHookPayload
+		if assert.NoError(t, json.Unmarshal(ts.body, &p)) {
+			assert.Equal(t, WebHookPayloadVersion, p.Version)
+			assert.Equal(t, model.AlertEventJobDown, p.Event)
+			assert.Equal(t, "job-1", p.JobID)
+			assert.Equal(t, "Backup", p.JobName)
+			assert.NotNil(t, p.DateExpected)
+			assert.Nil(t, p.DateLastPing)
+		}
+	}
+}
+
+func TestWebHookNotifierForm(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusNoContent, 0)
+	defer ts.Close()
+
+	_, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(),
+		getWebHookChannel(ts.URL, model.WebHookPayloadForm, nil, nil), NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, "application/x-www-form-urlencoded", ts.req.Header.Get("Content-Type"))
+
+		_, _, ok := ts.req.BasicAuth()
+		assert.False(t, ok)
+
+		values, _ := url.ParseQuery(string(ts.body))
+		assert.Equal(t, WebHookPayloadVersion, values.Get("version"))
+		assert.Equal(t, model.AlertEventJobDown, values.Get("event"))
+		assert.Equal(t, "2020-01-01T10:00:00Z", values.Get("date_expected"))
+		assert.Equal(t, "", values.Get("date_last_ping"))
+	}
+}
+
+func TestWebHookNotifierLabels(t *testing.T) {
+	e := getAlertEvent(1)
+	e.Job.Labels = model.JobLabels{"team": "ops", "env": "prod"}
+
+	for _, payloadType := range []string{model.WebHookPayloadJSON, model.WebHookPayloadForm} {
+		t.Run(payloadType, func(t *testing.T) {
+			ts := newWebHookReceiver(http.StatusOK, 0)
+			defer ts.Close()
+
+			_, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(), getWebHookChannel(ts.URL, payloadType, nil, nil), NewMessage(e))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if payloadType == model.WebHookPayloadJSON {
+				var p WebHookPayload
+				if assert.NoError(t, json.Unmarshal(ts.body, &p)) {
+					assert.Equal(t, e.Job.Labels, p.JobLabels)
+				}
+				return
+			}
+
+			// one field per label, sorted by key
+			values, _ := url.ParseQuery(string(ts.body))
+			assert.Equal(t, []string{"env=prod", "team=ops"}, values["job_labels"])
+		})
+	}
+}
+
+func TestWebHookNotifierOutput(t *testing.T) {
+	e := getAlertEvent(1)
+	e.Type = model.AlertEventJobFailed
+	e.Ping = &model.JobPing{Kind: model.PingKindFail, Body: strPtr("error: disk full\n"), ExitCode: intPtr(2)}
+
+	for _, payloadType := range []string{model.WebHookPayloadJSON, model.WebHookPayloadForm} {
+		t.Run(payloadType, func(t *testing.T) {
+			ts := newWebHookReceiver(http.StatusOK, 0)
+			defer ts.Close()
+
+			_, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(), getWebHookChannel(ts.URL, payloadType, nil, nil), NewMessage(e))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if payloadType == model.WebHookPayloadJSON {
+				var p WebHookPayload
+				if assert.NoError(t, json.Unmarshal(ts.body, &p)) {
+					assert.Equal(t, "error: disk full", p.Output)
+					assert.Equal(t, intPtr(2), p.ExitCode)
+				}
+				return
+			}
+
+			values, _ := url.ParseQuery(string(ts.body))
+			assert.Equal(t, "error: disk full", values.Get("output"))
+			assert.Equal(t, "2", values.Get("exit_code"))
+		})
+	}
+}
+
+func TestWebHookNotifierErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    int
+		permanent bool
+	}{
+		{name: "Not found", status: http.StatusNotFound, permanent: true},
+		{name: "Unauthorized", status: http.StatusUnauthorized, permanent: true},
+		{name: "Rate limited", status: http.StatusTooManyRequests},
+		{name: "Server error", status: http.StatusBadGateway},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newWebHookReceiver(tt.status, 0)
+			defer ts.Close()
+
+			res, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(),
+				getWebHookChannel(ts.URL, model.WebHookPayloadJSON, nil, nil), NewMessage(getAlertEvent(1)))
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.permanent, IsPermanent(err))
+				assert.Equal(t, tt.status, res.StatusCode)
+				assert.Equal(t, "received", res.Response)
+			}
+		})
+	}
+}
+
+func TestWebHookNotifierTimeout(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusOK, 200*time.Millisecond)
+	defer ts.Close()
+
+	n := NewWebHookNotifier(&http.Client{Timeout: 50 * time.Millisecond})
+	_, err := n.Notify(context.Background(), getWebHookChannel(ts.URL, model.WebHookPayloadJSON, nil, nil), NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.False(t, IsPermanent(err))
+	}
+}
+
+func TestWebHookNotifierInvalidChannel(t *testing.T) {
+	n := NewWebHookNotifier(nil)
+	msg := NewMessage(getAlertEvent(1))
+
+	_, err := n.Notify(context.Background(), getWebHookChannel("ftp://example.com", model.WebHookPayloadJSON, nil, nil), msg)
+	assert.True(t, IsPermanent(err))
+
+	_, err = n.Notify(context.Background(), getWebHookChannel("https://example.com", "XML", nil, nil), msg)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestWebHookNotifierSignature(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusOK, 0)
+	defer ts.Close()
+
+	c := getWebHookChannel(ts.URL, model.WebHookPayloadJSON, nil, nil)
+	c.Configuration["signing_secret"] = "whsec_test"
+
+	_, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(), c, NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.NoError(t, err) {
+		header := ts.req.Header.Get(webhook.SignatureHeader)
+		assert.NoError(t, webhook.Verify("whsec_test", header, ts.body, webhook.DefaultTolerance))
+		assert.Error(t, webhook.Verify("whsec_other", header, ts.body, webhook.DefaultTolerance))
+	}
+}
+
+func TestWebHookNotifierWithoutSecret(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusOK, 0)
+	defer ts.Close()
+
+	_, err := NewWebHookNotifier(http.DefaultClient).Notify(context.Background(), getWebHookChannel(ts.URL, model.WebHookPayloadJSON, nil, nil), NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", ts.req.Header.Get(webhook.SignatureHeader))
+	}
+}
+
+func TestWebHookNotifierPrivateAddress(t *testing.T) {
+	ts := newWebHookReceiver(http.StatusOK, 0)
+	defer ts.Close()
+
+	// the default client doesn't connect to the loopback address of the test server
+	_, err := NewWebHookNotifier(nil).Notify(context.Background(), getWebHookChannel(ts.URL, model.WebHookPayloadJSON, nil, nil), NewMessage(getAlertEvent(1)))
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.True(t, IsPermanent(err))
+	}
+	assert.Nil(t, ts.req)
+}
+
+func TestDenyPrivateAddress(t *testing.T) {
+	cases := []struct {
+		address string
+		denied  bool
+	}{
+		{address: "127.0.0.1:80", denied: true},
+		{address: "10.1.2.3:443", denied: true},
+		{address: "172.16.0.1:80", denied: true},
+		{address: "192.168.1.1:80", denied: true},
+		{address: "169.254.169.254:80", denied: true},
+		{address: "0.0.0.0:80", denied: true},
+		{address: "[::1]:80", denied: true},
+		{address: "[fe80::1]:80", denied: true},
+		{address: "[fd00::1]:80", denied: true},
+		{address: "[::ffff:127.0.0.1]:80", denied: true},
+		{address: "203.0.113.7:443"},
+		{address: "[2001:db8::1]:443"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.address, func(t *testing.T) {
+			err := denyPrivateAddress("tcp", tt.address, nil)
+			if tt.denied {
+				assert.True(t, IsPermanent(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -5,3 +5,10 @@ const MaxPasswordRests = 3
 
 // MinutesToWaitBeforeEmailResend contains the number of minutes to wait before re-sending the email
 const MinutesToWaitBeforeEmailResend = 5
+
+// PasswordResetExpirationHours contains the number of hours a password reset link is valid
+const PasswordResetExpirationHours = 24
+
+// PasswordResetURL is the frontend URL sent by email to reset the password; it
+// receives the web base URL and the reset ID
+const PasswordResetURL = "%s/password-reset?token=%s"
@@ -3,6 +3,7 @@ package user
 import (
 	"cronspy/backend/pkg/api/user/platform/db"
 	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/mail"
 	"cronspy/backend/pkg/util/model"
 
 	"github.com/jinzhu/gorm"
@@ -15,7 +16,7 @@ type Service interface {
 	RegisterUser(ec echo.Context, user *model.User) (err error)
 	Login(username, password string) (user model.User, err error)
 	ChangePassword(idUser int, oldPassword, newPassword string) (err error)
-	ResetPassword(email string) (resetID string, err error)
+	ResetPassword(email string) (err error)
 	ValidateResetPassword(resetID string) (err error)
 	ChangePasswordWithReset(resetToken, newPassword string) (err error)
 }
@@ -46,21 +47,26 @@ type User struct {
 	database        DB
 	logger          *log.Log
 	tokenExpiration int
+	mailer          mail.Mailer
+	webBaseURL      string
 }
 
 // creates new reseller service
-func new(database DB, l *log.Log, tokenExpiration int) *User {
+func new(database DB, l *log.Log, tokenExpiration int, mailer mail.Mailer, webBaseURL string) *User {
 	return &User{
 		database:        database,
 		logger:          l,
 		tokenExpiration: tokenExpiration,
+		mailer:          mailer,
+		webBaseURL:      webBaseURL,
 	}
 }
 
-// Initialize initializes tax application service
-func Initialize(ds *gorm.DB, dbService DB, l *log.Log, tokenExpiration int) *User {
+// Initialize initializes tax application service; `mailer` can be nil, in
+// which case emails are not sent
+func Initialize(ds *gorm.DB, dbService DB, l *log.Log, tokenExpiration int, mailer mail.Mailer, webBaseURL string) *User {
 	if dbService == nil {
 		dbService = db.NewUserDB(ds)
 	}
-	return new(dbService, l, tokenExpiration)
+	return new(dbService, l, tokenExpiration, mailer, webBaseURL)
 }
@@ -128,22 +128,21 @@ func (h *HTTP) userLoginHandler(c echo.Context) error {
 //
 func (h *HTTP) userPasswordResetRequestHandler(c echo.Context) error {
 
-	type requestResponse struct {
+	type request struct {
 		Email string `json:"email,omitempty"`
-		ID    string `json:"id,omitempty"`
 	}
 
-	payload := new(requestResponse)
+	payload := new(request)
 	if err := c.Bind(payload); err != nil {
 		return err
 	}
 
-	resetID, err := h.svc.ResetPassword(payload.Email)
-	if err != nil {
+	// the reset token is only sent by email
+	if err := h.svc.ResetPassword(payload.Email); err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, requestResponse{ID: resetID})
+	return c.NoContent(http.StatusCreated)
 }
 
 //
@@ -5,11 +5,15 @@ import (
 	"cronspy/backend/pkg/api/user"
 	"cronspy/backend/pkg/util/exception"
 	"cronspy/backend/pkg/util/log"
+	"cronspy/backend/pkg/util/mail"
+	"cronspy/backend/pkg/util/mail/mailtest"
 	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/pingkey"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -113,6 +117,9 @@ func (db *DBMock) UpdateUser(user *model.User, fields map[string]interface{}) (e
 }
 
 func (db *DBMock) CreatePasswordReset(reset *model.PasswordReset) (err error) {
+	db.currentPasswordResetID++
+	reset.ID = strconv.Itoa(db.currentPasswordResetID)
+	db.passwordReset = append(db.passwordReset, *reset)
 	return
 }
 
@@ -121,14 +128,33 @@ func (db *DBMock) GetPasswordResetByID(id string, trx *gorm.DB) (reset model.Pas
 }
 
 func (db *DBMock) GetPasswordResetByUser(idUser int) (reset model.PasswordReset, err error) {
+	for _, r := range db.passwordReset {
+		if r.IDUser == idUser {
+			return r, nil
+		}
+	}
+
+	err = exception.ErrRecordNotFound
 	return
 }
 
 func (db *DBMock) DeletePasswordReset(id string) (err error) {
+	resets := db.passwordReset[:0]
+	for _, r := range db.passwordReset {
+		if r.ID != id {
+			resets = append(resets, r)
+		}
+	}
+	db.passwordReset = resets
 	return
 }
 
 func (db *DBMock) UpdatePasswordResetCount(id string, countValue int) (err error) {
+	for i := range db.passwordReset {
+		if db.passwordReset[i].ID == id {
+			db.passwordReset[i].LinkSentCount = countValue
+		}
+	}
 	return
 }
 
@@ -142,10 +168,18 @@ func (db *DBMock) MarkPasswordResetAsUsed(id string, trx *gorm.DB) (err error) {
 
 // get HTTP handler
 func getHTTPHandler(e *echo.Echo, mockData bool) (h HTTP) {
+	return getHTTPHandlerWithMailer(e, mockData, nil)
+}
 
-	mockDB := getDBMock(mockData)
+// get HTTP handler that sends emails with `mailer`
+func getHTTPHandlerWithMailer(e *echo.Echo, mockData bool, mailer mail.Mailer) (h HTTP) {
+	return getHTTPHandlerWithDB(e, getDBMock(mockData), mailer)
+}
+
+// get HTTP handler that uses `mockDB` and sends emails with `mailer`
+func getHTTPHandlerWithDB(e *echo.Echo, mockDB *DBMock, mailer mail.Mailer) (h HTTP) {
 	logger := log.New()
-	userService := user.Initialize(nil, mockDB, logger, 5)
+	userService := user.Initialize(nil, mockDB, logger, 5, mailer, "https://app.cronspy.com")
 
 	h = NewHTTP(userService, "myTestingKey", jwt.SigningMethodHS512, e)
 	return
@@ -192,6 +226,9 @@ func TestUserRegistrationOK(t *testing.T) {
 		assert.NotEqual(t, 0, newUser.ID)
 		assert.Equal(t, "test.user.1@cronspay.com", newUser.Email)
 		assert.Equal(t, "Test User 1", newUser.Name)
+		if assert.NotNil(t, newUser.PingKey) {
+			assert.True(t, pingkey.Valid(*newUser.PingKey))
+		}
 	}
 }
 
@@ -267,6 +304,9 @@ func TestUserLoginOK(t *testing.T) {
 	// assertions
 	if assert.NoError(t, err) {
 		assert.Equal(t, "Test User A1", r.User.Name)
+
+		// users without ping key get one
+		assert.NotNil(t, r.User.PingKey)
 	}
 }
 
@@ -290,3 +330,75 @@ func TestUserLoginInvalidPassword(t *testing.T) {
 	// assertions
 	assert.Error(t, err)
 }
+
+//
+// ============== PASSWORD RESET ==============
+
+func runPasswordResetRequest(mailer mail.Mailer, email string) (rec *httptest.ResponseRecorder, err error) {
+	return runPasswordResetRequestWithDB(getDBMock(true), mailer, email)
+}
+
+func runPasswordResetRequestWithDB(mockDB *DBMock, mailer mail.Mailer, email string) (rec *httptest.ResponseRecorder, err error) {
+	// create server and handler
+	e := echo.New()
+	handler := getHTTPHandlerWithDB(e, mockDB, mailer)
+
+	// define request
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf(`{"email":"%s"}`, email)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	// call handler
+	err = handler.userPasswordResetRequestHandler(c)
+	return
+}
+
+func TestPasswordResetSendsEmail(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	mailer := mail.NewSMTPMailer(mail.SMTPConfig{Host: s.Host(), Port: s.Port(), From: "no-reply@cronspy.com"})
+	rec, err := runPasswordResetRequest(mailer, "test.user.a2@cronspy.com")
+
+	// assertions
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusCreated, rec.Code) && assert.Len(t, s.Messages(), 1) {
+		m := s.Messages()[0]
+		assert.Equal(t, []string{"test.user.a2@cronspy.com"}, m.To)
+		assert.Contains(t, m.Data, "Reset your password")
+		assert.Contains(t, m.Data, "https://app.cronspy.com/password-reset?token=")
+
+		// the token is only sent by email
+		assert.Empty(t, rec.Body.String())
+	}
+}
+
+func TestPasswordResetEmailError(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	s.Close()
+
+	mailer := mail.NewSMTPMailer(mail.SMTPConfig{Host: s.Host(), Port: s.Port(), From: "no-reply@cronspy.com", Timeout: time.Second})
+	mockDB := getDBMock(true)
+	_, err = runPasswordResetRequestWithDB(mockDB, mailer, "test.user.a2@cronspy.com")
+
+	// assertions
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusInternalServerError, err.(*echo.HTTPError).Code)
+	}
+
+	// the reset isn't counted when the email can't be sent
+	assert.Empty(t, mockDB.passwordReset)
+
+	mockDB.passwordReset = []model.PasswordReset{{ID: "100", IDUser: 2, LinkSentCount: 2}}
+	_, err = runPasswordResetRequestWithDB(mockDB, mailer, "test.user.a2@cronspy.com")
+	if assert.Error(t, err) && assert.Len(t, mockDB.passwordReset, 1) {
+		assert.Equal(t, 2, mockDB.passwordReset[0].LinkSentCount)
+	}
+}
@@ -1,9 +1,13 @@
 package user
 
 import (
+	"context"
 	"cronspy/backend/pkg/util/exception"
+	"cronspy/backend/pkg/util/mail"
 	"cronspy/backend/pkg/util/model"
+	"cronspy/backend/pkg/util/pingkey"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -38,6 +42,14 @@ func (u *User) RegisterUser(ec echo.Context, user *model.User) (err error) {
 		user.HashPassword()
 		user.AccountType = model.AccountTypeFree // new users are always FREE
 
+		key, errKey := pingkey.Generate()
+		if errKey != nil {
+			u.logger.Error("error generating user ping key", errKey, nil)
+			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errKey.Error()))
+			return
+		}
+		user.PingKey = &key
+
 		if _, errSave := u.database.RegisterUser(user); errSave != nil {
 			u.logger.Error("error creating user", errSave, nil)
 			err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errSave.Error()))
@@ -61,6 +73,7 @@ func (u *User) Login(username, password string) (user model.User, err error) {
 			err = echo.NewHTTPError(http.StatusUnauthorized, exception.GetErrorMap(exception.CodeInvalidPassword, ""))
 		} else {
 			user.CleanPassword()
+			u.setMissingPingKey(&user)
 		}
 
 	} else {
@@ -159,7 +172,7 @@ func (u *User) ChangePasswordWithReset(resetToken, newPassword string) (err erro
 }
 
 // ResetPassword holds the logic for password reset.
-func (u *User) ResetPassword(email string) (resetID string, err error) {
+func (u *User) ResetPassword(email string) (err error) {
 
 	// check if users exists
 	user, errGetUser := u.database.GetUserByEmail(email)
@@ -182,7 +195,7 @@ func (u *User) ResetPassword(email string) (resetID string, err error) {
 	}
 
 	// check if there's a password reset already created for this user
-	reset, ok, errReset := u.getOrCreatePasswordReset(user.ID)
+	reset, ok, created, errReset := u.getOrCreatePasswordReset(user.ID)
 	if errReset != nil {
 		u.logger.Error("error creating password reset for user", errReset, map[string]interface{}{"id_user": user.ID})
 		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, errReset.Error()))
@@ -201,13 +214,31 @@ func (u *User) ResetPassword(email string) (resetID string, err error) {
 		return
 	}
 
-	// TODO: send email
+	if err = u.sendPasswordResetEmail(&user, &reset); err != nil {
+		u.logger.Error("error sending password reset email", err, map[string]interface{}{"id_user": user.ID})
+		err = echo.NewHTTPError(http.StatusInternalServerError, exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
 
-	resetID = reset.ID
+		// emails that weren't sent don't count
+		u.rollbackPasswordReset(reset, created)
+		return
+	}
 
 	return
 }
 
+// undoes the changes made by `getOrCreatePasswordReset`
+func (u *User) rollbackPasswordReset(reset model.PasswordReset, created bool) {
+	var err error
+	if created {
+		err = u.database.DeletePasswordReset(reset.ID)
+	} else {
+		err = u.database.UpdatePasswordResetCount(reset.ID, reset.LinkSentCount-1)
+	}
+	if err != nil {
+		u.logger.Error("error rolling back password reset", err, map[string]interface{}{"id_user": reset.IDUser})
+	}
+}
+
 // ValidateResetPassword is invoked when the user clicks the reset password URL
 // provided by email; if not found, just return 404
 func (u *User) ValidateResetPassword(resetID string) (err error) {
@@ -226,7 +257,7 @@ func (u *User) ValidateResetPassword(resetID string) (err error) {
 	}
 
 	// check if EXPIRED
-	if time.Since(reset.DateUpdated).Hours() > 24 {
+	if time.Since(reset.DateUpdated).Hours() > PasswordResetExpirationHours {
 		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodePasswordResetTokenExpired, ""))
 	}
 
@@ -240,7 +271,7 @@ func (u *User) ValidateResetPassword(resetID string) (err error) {
 }
 
 // looks for an existing password reset for the user; if it doesn't exist a new record is crated
-func (u *User) getOrCreatePasswordReset(idUser int) (r model.PasswordReset, ok bool, err error) {
+func (u *User) getOrCreatePasswordReset(idUser int) (r model.PasswordReset, ok, created bool, err error) {
 
 	r, err = u.database.GetPasswordResetByUser(idUser)
 	if err != nil {
@@ -254,7 +285,7 @@ func (u *User) getOrCreatePasswordReset(idUser int) (r model.PasswordReset, ok b
 			r.Used = false
 
 			err = u.database.CreatePasswordReset(&r)
-			ok = true
+			ok, created = true, true
 		}
 	} else {
 		// wait MinutesToWaitBeforeEmailResend
@@ -267,3 +298,41 @@ func (u *User) getOrCreatePasswordReset(idUser int) (r model.PasswordReset, ok b
 
 	return
 }
+
+// sends the email with the link to reset the password
+func (u *User) sendPasswordResetEmail(user *model.User, reset *model.PasswordReset) (err error) {
+	if u.mailer == nil {
+		u.logger.Warn("mailer not configured, password reset email not sent", map[string]interface{}{"id_user": user.ID})
+		return
+	}
+
+	m, err := mail.Render(mail.TemplatePasswordReset, mail.PasswordResetData{
+		Name:            user.Name,
+		URL:             fmt.Sprintf(PasswordResetURL, u.webBaseURL, reset.ID),
+		ExpirationHours: PasswordResetExpirationHours,
+	}, user.Email)
+	if err != nil {
+		return
+	}
+
+	return u.mailer.Send(context.Background(), m)
+}
+
+// users created before ping keys existed get one on their next login; if
+// it can't be saved, the user just can't use slugs in ping URLs yet
+func (u *User) setMissingPingKey(user *model.User) {
+	if user.PingKey != nil {
+		return
+	}
+
+	key, err := pingkey.Generate()
+	if err == nil {
+		err = u.database.UpdateUser(user, map[string]interface{}{"ping_key": key})
+	}
+	if err != nil {
+		u.logger.Error("error setting user ping key", err, map[string]interface{}{"id_user": user.ID})
+		return
+	}
+
+	user.PingKey = &key
+}
@@ -11,12 +11,13 @@ import (
 // Configuration is the structure used to hold configuration from config.yml
 type Configuration struct {
 	Server struct {
-		Name            string `yaml:"name"`
-		Port            string `yaml:"port"`
-		ReadTimeout     int    `yaml:"read_timeout"`
-		WriteTimeout    int    `yaml:"write_timeout"`
-		Debug           bool   `yaml:"debug"`
-		TokenExpiration int    `yaml:"token_expiration"`
+		Name            string   `yaml:"name"`
+		Port            string   `yaml:"port"`
+		ReadTimeout     int      `yaml:"read_timeout"`
+		WriteTimeout    int      `yaml:"write_timeout"`
+		Debug           bool     `yaml:"debug"`
+		TokenExpiration int      `yaml:"token_expiration"`
+		TrustedProxies  []string `yaml:"trusted_proxies"`
 	} `yaml:"server"`
 	Database struct {
 		Driver             string `yaml:"driver"`
@@ -28,6 +29,37 @@ type Configuration struct {
 		MaxIdleConnections int    `yaml:"max_idle_connections"`
 		MaxLifeTime        int    `yaml:"max_lifetime"`
 	} `yaml:"database"`
+	Monitor struct {
+		EvaluationInterval int `yaml:"evaluation_interval"`
+		MaxPingBodySize    int `yaml:"max_ping_body_size"`
+	} `yaml:"monitor"`
+	Notification struct {
+		Workers      int `yaml:"workers"`
+		PollInterval int `yaml:"poll_interval"`
+		MaxAttempts  int `yaml:"max_attempts"`
+		Timeout      int `yaml:"timeout"`
+	} `yaml:"notification"`
+	Mail struct {
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		TLS      string `yaml:"tls"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+		Timeout  int    `yaml:"timeout"`
+	} `yaml:"mail"`
+	InboundMail struct {
+		Address        string   `yaml:"address"`
+		Domain         string   `yaml:"domain"`
+		FailKeywords   []string `yaml:"fail_keywords"`
+		MaxSize        int      `yaml:"max_size"`
+		MaxSessions    int      `yaml:"max_sessions"`
+		Timeout        int      `yaml:"timeout"`
+		SessionTimeout int      `yaml:"session_timeout"`
+	} `yaml:"inbound_mail"`
+	Web struct {
+		BaseURL string `yaml:"base_url"`
+	} `yaml:"web"`
 }
 
 // Load reads application settings in the indicated file
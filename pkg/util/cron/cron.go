@@ -0,0 +1,332 @@
+// Package cron parses standard 5-field cron expressions and calculates
+// the times at which they fire in a given location.
+//
+// Expressions are evaluated against the wall clock of the location, so
+// daylight saving transitions are handled as follows:
+//
+//   - when a scheduled time falls into a gap (the clock jumps forward), jobs
+//     with a fixed hour run once, shifted forward by the length of the gap;
+//     jobs with a wildcard hour (e.g. `*/15 * * * *`) just skip it
+//   - when a scheduled time happens twice (the clock goes back), jobs with a
+//     fixed hour run only on the first occurrence; jobs with a wildcard hour
+//     run on both
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// number of years to look for a matching time before giving up
+	searchYears = 5
+
+	// max clock shift expected on a timezone transition; it's used to
+	// widen the search so that shifted or repeated times are not missed
+	dstWindow = 3 * time.Hour
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// set when the field starts with `*`
+	hourStar, domStar, dowStar bool
+}
+
+// field bounds
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteBounds = bounds{name: "minute", min: 0, max: 59}
+	hourBounds   = bounds{name: "hour", min: 0, max: 23}
+	domBounds    = bounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = bounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	dowBounds = bounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// supported macros
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a standard 5-field cron expression (minute, hour, day of month,
+// month and day of week) or one of the supported macros (`@hourly`, `@daily`,
+// `@weekly`, `@monthly` and `@yearly`)
+func Parse(expr string) (s *Schedule, err error) {
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		m, ok := macros[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown macro '%s'", expr)
+		}
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, found %d", len(fields))
+	}
+
+	s = new(Schedule)
+	if s.minute, _, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, s.hourStar, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.dom, s.domStar, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.month, _, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.dow, s.dowStar, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+
+	// both 0 and 7 are sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return
+}
+
+// Next returns the first time after `t` at which the schedule fires, evaluated
+// in the location of `t`; a zero time is returned when there are no matches
+func (s *Schedule) Next(t time.Time) (next time.Time) {
+	loc := t.Location()
+
+	for w := s.nextWall(wallClock(t).Add(-dstWindow)); !w.IsZero(); w = s.nextWall(w.Add(time.Minute)) {
+		if !next.IsZero() && w.Sub(wallClock(next)) > dstWindow {
+			break
+		}
+
+		for _, i := range s.resolve(w, loc) {
+			if i.After(t) && (next.IsZero() || i.Before(next)) {
+				next = i
+			}
+		}
+	}
+
+	return
+}
+
+// Prev returns the last time before `t` at which the schedule fired, evaluated
+// in the location of `t`; a zero time is returned when there are no matches
+func (s *Schedule) Prev(t time.Time) (prev time.Time) {
+	loc := t.Location()
+
+	for w := s.prevWall(wallClock(t).Add(dstWindow)); !w.IsZero(); w = s.prevWall(w.Add(-time.Minute)) {
+		if !prev.IsZero() && wallClock(prev).Sub(w) > dstWindow {
+			break
+		}
+
+		for _, i := range s.resolve(w, loc) {
+			if i.Before(t) && i.After(prev) {
+				prev = i
+			}
+		}
+	}
+
+	return
+}
+
+// returns the first wall clock time (expressed in UTC) equal or after `t`
+// that matches the schedule
+func (s *Schedule) nextWall(t time.Time) time.Time {
+	limit := t.Year() + searchYears
+
+	for t.Year() <= limit {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// returns the last wall clock time (expressed in UTC) equal or before `t`
+// that matches the schedule
+func (s *Schedule) prevWall(t time.Time) time.Time {
+	limit := t.Year() - searchYears
+
+	for t.Year() >= limit {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(-time.Minute)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(-time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// when both day fields are restricted, a day matches if any of them does
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// returns the instants in `loc` at which the wall clock time `w` fires,
+// applying the daylight saving rules described in the package docs
+func (s *Schedule) resolve(w time.Time, loc *time.Location) (instants []time.Time) {
+
+	// offsets in effect before and after any transition near `w`
+	_, before := w.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := w.Add(24 * time.Hour).In(loc).Zone()
+
+	for _, offset := range []int{before, after} {
+		i := w.Add(-time.Duration(offset) * time.Second).In(loc)
+		if wallClock(i).Equal(w) && (len(instants) == 0 || !instants[0].Equal(i)) {
+			instants = append(instants, i)
+		}
+	}
+
+	switch len(instants) {
+	case 0:
+		// the time doesn't exist; shift it forward by the length of the gap
+		if !s.hourStar {
+			instants = append(instants, w.Add(-time.Duration(before)*time.Second).In(loc))
+		}
+	case 2:
+		// the time happens twice; keep the first occurrence only
+		if instants[1].Before(instants[0]) {
+			instants[0], instants[1] = instants[1], instants[0]
+		}
+		if !s.hourStar {
+			instants = instants[:1]
+		}
+	}
+
+	return
+}
+
+// parses a single field of the expression, returning the bits set for
+// every allowed value and if the field starts with `*`
+func parseField(field string, b bounds) (bits uint64, star bool, err error) {
+
+	star = strings.HasPrefix(field, "*")
+
+	for _, part := range strings.Split(field, ",") {
+		rangeAndStep := strings.Split(part, "/")
+		if len(rangeAndStep) > 2 {
+			return 0, false, fmt.Errorf("invalid value '%s' in %s field", part, b.name)
+		}
+
+		var low, high int
+		step := 1
+
+		if rangeAndStep[0] == "*" {
+			low, high = b.min, b.max
+		} else {
+			lowAndHigh := strings.Split(rangeAndStep[0], "-")
+			if len(lowAndHigh) > 2 {
+				return 0, false, fmt.Errorf("invalid range '%s' in %s field", part, b.name)
+			}
+
+			if low, err = parseValue(lowAndHigh[0], b); err != nil {
+				return 0, false, err
+			}
+
+			high = low
+			if len(lowAndHigh) == 2 {
+				if high, err = parseValue(lowAndHigh[1], b); err != nil {
+					return 0, false, err
+				}
+			} else if len(rangeAndStep) == 2 {
+				// `N/step` means every `step` from N to the max value
+				high = b.max
+			}
+
+			if low > high {
+				return 0, false, fmt.Errorf("invalid range '%s' in %s field", part, b.name)
+			}
+		}
+
+		if len(rangeAndStep) == 2 {
+			if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("invalid step '%s' in %s field", part, b.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return
+}
+
+// parses a single numeric or named value, checking it's within bounds
+func parseValue(value string, b bounds) (v int, err error) {
+	if n, ok := b.names[strings.ToUpper(value)]; ok {
+		return n, nil
+	}
+
+	v, err = strconv.Atoi(value)
+	if err != nil || v < b.min || v > b.max {
+		return 0, fmt.Errorf("invalid value '%s' in %s field", value, b.name)
+	}
+
+	return
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// returns the wall clock of `t` (truncated to minutes) expressed in UTC
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
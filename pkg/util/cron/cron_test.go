@@ -0,0 +1,210 @@
+package cron_test
+
+import (
+	"cronspy/backend/pkg/util/cron"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const layout = "2006-01-02 15:04 MST"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "Every minute", expr: "* * * * *"},
+		{name: "Lists, ranges and steps", expr: "0,30 8-18/2 1-15 */3 MON-FRI"},
+		{name: "Names", expr: "0 12 * jan,JUL sun"},
+		{name: "Sunday as 7", expr: "0 0 * * 7"},
+		{name: "Value with step", expr: "5/15 * * * *"},
+		{name: "Macro", expr: "@daily"},
+		{name: "Macro uppercase", expr: "@HOURLY"},
+		{name: "Unknown macro", expr: "@every5m", wantErr: true},
+		{name: "Missing fields", expr: "* * * *", wantErr: true},
+		{name: "Too many fields", expr: "0 * * * * *", wantErr: true},
+		{name: "Minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "Hour out of range", expr: "0 24 * * *", wantErr: true},
+		{name: "Day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "Inverted range", expr: "0 10-2 * * *", wantErr: true},
+		{name: "Zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "Invalid name", expr: "0 0 * FOO *", wantErr: true},
+		{name: "Garbage", expr: "a b c d e", wantErr: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cron.Parse(tt.expr)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{name: "Every minute", expr: "* * * * *", from: "2020-01-01 10:00 UTC", want: "2020-01-01 10:01 UTC"},
+		{name: "Hourly", expr: "@hourly", from: "2020-01-01 10:30 UTC", want: "2020-01-01 11:00 UTC"},
+		{name: "Daily, next day", expr: "@daily", from: "2020-01-01 00:00 UTC", want: "2020-01-02 00:00 UTC"},
+		{name: "Weekly", expr: "@weekly", from: "2020-01-01 00:00 UTC", want: "2020-01-05 00:00 UTC"},
+		{name: "Steps", expr: "*/20 * * * *", from: "2020-01-01 10:41 UTC", want: "2020-01-01 11:00 UTC"},
+		{name: "End of year", expr: "0 0 1 1 *", from: "2020-06-15 12:00 UTC", want: "2021-01-01 00:00 UTC"},
+		{name: "Leap day", expr: "0 0 29 2 *", from: "2020-03-01 00:00 UTC", want: "2024-02-29 00:00 UTC"},
+		{name: "Day of month OR day of week", expr: "0 0 13 * FRI", from: "2020-03-01 00:00 UTC", want: "2020-03-06 00:00 UTC"},
+		{name: "Day of week with wildcard day of month", expr: "0 0 * * 5", from: "2020-03-01 00:00 UTC", want: "2020-03-06 00:00 UTC"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := cron.Parse(tt.expr)
+			if assert.NoError(t, err) {
+				from, _ := time.Parse(layout, tt.from)
+				assert.Equal(t, tt.want, s.Next(from).Format(layout))
+			}
+		})
+	}
+}
+
+func TestNextNoMatches(t *testing.T) {
+	s, err := cron.Parse("0 0 30 2 *")
+	if assert.NoError(t, err) {
+		assert.True(t, s.Next(time.Now()).IsZero())
+		assert.True(t, s.Prev(time.Now()).IsZero())
+	}
+}
+
+func TestPrev(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{name: "Every minute", expr: "* * * * *", from: "2020-01-01 10:00 UTC", want: "2020-01-01 09:59 UTC"},
+		{name: "Hourly", expr: "@hourly", from: "2020-01-01 10:30 UTC", want: "2020-01-01 10:00 UTC"},
+		{name: "Daily", expr: "30 2 * * *", from: "2020-01-01 02:00 UTC", want: "2019-12-31 02:30 UTC"},
+		{name: "Previous year", expr: "0 0 1 1 *", from: "2020-06-15 12:00 UTC", want: "2020-01-01 00:00 UTC"},
+		{name: "Leap day", expr: "0 0 29 2 *", from: "2023-03-01 00:00 UTC", want: "2020-02-29 00:00 UTC"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := cron.Parse(tt.expr)
+			if assert.NoError(t, err) {
+				from, _ := time.Parse(layout, tt.from)
+				assert.Equal(t, tt.want, s.Prev(from).Format(layout))
+			}
+		})
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	s, _ := cron.Parse("0 9 * * *")
+	next := s.Next(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC).In(loc))
+
+	assert.Equal(t, time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC), next.UTC())
+}
+
+// collects all the fire times in [from, to)
+func fireTimes(s *cron.Schedule, from, to time.Time) (times []string) {
+	for t := s.Next(from.Add(-time.Nanosecond)); !t.IsZero() && t.Before(to); t = s.Next(t) {
+		times = append(times, t.UTC().Format("15:04"))
+	}
+	return
+}
+
+// collects all the fire times in [from, to) going backwards
+func fireTimesReverse(s *cron.Schedule, from, to time.Time) (times []string) {
+	for t := s.Prev(to); !t.IsZero() && !t.Before(from); t = s.Prev(t) {
+		times = append([]string{t.UTC().Format("15:04")}, times...)
+	}
+	return
+}
+
+func TestDaylightSavingGap(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 2021-03-14 02:00 EST jumps to 03:00 EDT (07:00 UTC)
+	from := time.Date(2021, 3, 14, 0, 0, 0, 0, loc)
+	to := time.Date(2021, 3, 14, 5, 0, 0, 0, loc)
+
+	cases := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		// fixed time inside the gap is shifted by the gap length (03:30 EDT)
+		{name: "Fixed hour", expr: "30 2 * * *", want: []string{"07:30"}},
+		// 02:00 EST doesn't exist; 03:00 EDT happens as usual
+		{name: "Wildcard hour", expr: "0 * * * *", want: []string{"05:00", "06:00", "07:00", "08:00"}},
+		// both the shifted and the regular run happen at 03:30 EDT, only once
+		{name: "Fixed hours around gap", expr: "30 2,3 * * *", want: []string{"07:30"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := cron.Parse(tt.expr)
+			assert.Equal(t, tt.want, fireTimes(s, from, to))
+			assert.Equal(t, tt.want, fireTimesReverse(s, from, to))
+		})
+	}
+}
+
+func TestDaylightSavingOverlap(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 2021-11-07 02:00 EDT goes back to 01:00 EST, so 01:xx happens twice
+	from := time.Date(2021, 11, 7, 0, 0, 0, 0, loc)
+	to := time.Date(2021, 11, 7, 3, 0, 0, 0, loc)
+
+	cases := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		// fixed time runs only on the first occurrence (01:30 EDT)
+		{name: "Fixed hour", expr: "30 1 * * *", want: []string{"05:30"}},
+		// wildcard hour runs on both occurrences
+		{name: "Wildcard hour", expr: "30 * * * *", want: []string{"04:30", "05:30", "06:30", "07:30"}},
+		{name: "Steps", expr: "*/30 * * * *", want: []string{"04:00", "04:30", "05:00", "05:30", "06:00", "06:30", "07:00", "07:30"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := cron.Parse(tt.expr)
+			assert.Equal(t, tt.want, fireTimes(s, from, to))
+			assert.Equal(t, tt.want, fireTimesReverse(s, from, to))
+		})
+	}
+}
+
+func TestDaylightSavingHalfHour(t *testing.T) {
+	loc, err := time.LoadLocation("Australia/Lord_Howe")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 2021-10-03 02:00 +1030 jumps to 02:30 +11
+	s, _ := cron.Parse("15 2 * * *")
+	next := s.Next(time.Date(2021, 10, 3, 0, 0, 0, 0, loc))
+
+	assert.Equal(t, "2021-10-03 02:45", next.Format("2006-01-02 15:04"))
+}
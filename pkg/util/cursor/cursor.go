@@ -0,0 +1,44 @@
+// Package cursor encodes the positions used by keyset pagination as opaque
+// tokens, so clients can request the next page without knowing how the
+// results are sorted:
+//
+//	token, err := cursor.Encode(model.JobCursor{Sort: "name", Text: &name, ID: id})
+//
+//	var c model.JobCursor
+//	if err := cursor.Decode(token, &c); err != nil {
+//		// cursor.ErrInvalidCursor
+//	}
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned when a token can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the opaque token of a cursor
+func Encode(c interface{}) (token string, err error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return
+}
+
+// Decode reads a token created by `Encode` into `c`
+func Decode(token string, c interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	if err = json.Unmarshal(b, c); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
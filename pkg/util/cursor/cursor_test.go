@@ -0,0 +1,36 @@
+package cursor_test
+
+import (
+	"cronspy/backend/pkg/util/cursor"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type position struct {
+	Time time.Time `json:"time"`
+	ID   string    `json:"id"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	in := position{Time: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), ID: "job-1"}
+
+	token, err := cursor.Encode(in)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, token, "job-1")
+
+		var out position
+		if assert.NoError(t, cursor.Decode(token, &out)) {
+			assert.True(t, in.Time.Equal(out.Time))
+			assert.Equal(t, in.ID, out.ID)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, token := range []string{"not base64!", "bm90IGpzb24", "eyJ0aW1lIjoieWVzdGVyZGF5In0"} {
+		var out position
+		assert.Equal(t, cursor.ErrInvalidCursor, cursor.Decode(token, &out), token)
+	}
+}
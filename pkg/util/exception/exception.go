@@ -5,6 +5,7 @@ import "errors"
 // Internal error defitions
 var (
 	ErrRecordNotFound        = errors.New("record not found")
+	ErrDuplicateRecord       = errors.New("duplicate record")
 	ErrInvalidEmailAddress   = errors.New("invalid_email")
 	ErrInvalidPasswordFormat = errors.New("invalid_password_format")
 )
@@ -24,6 +25,10 @@ const (
 	CodeInvalidPageSize           = "invalid_page_size"
 	CodeInvalidFields             = "invalid_fields"
 	CodeInvalidEntityID           = "invalid_entity_id"
+	CodeInvalidChannelType        = "invalid_channel_type"
+	CodeDeliveryNotDead           = "delivery_not_dead"
+	CodeInvalidCursor             = "invalid_cursor"
+	CodeSlugExists                = "slug_exists"
 )
 
 var (
@@ -44,6 +49,10 @@ var (
 		CodeInvalidPageSize:              "invalid page size value",
 		CodeInvalidFields:                "invalid or missing required fields",
 		CodeInvalidEntityID:              "the provided entity ID is invalid or malformed",
+		CodeInvalidChannelType:           "the operation is not supported by the channel type",
+		CodeDeliveryNotDead:              "only dead deliveries can be retried",
+		CodeInvalidCursor:                "the pagination cursor is invalid or doesn't match the query",
+		CodeSlugExists:                   "the slug is already used by another job",
 	}
 )
 
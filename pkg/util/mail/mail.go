@@ -0,0 +1,215 @@
+// Package mail sends transactional email through SMTP, rendering the
+// content from HTML and plain text templates.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS modes
+const (
+	// TLSNone sends mail in plain text
+	TLSNone = "none"
+
+	// TLSStartTLS upgrades the connection with STARTTLS
+	TLSStartTLS = "starttls"
+
+	// TLSImplicit connects using TLS from the start (usually port 465)
+	TLSImplicit = "tls"
+)
+
+// DefaultTimeout is used when the configuration doesn't define one
+const DefaultTimeout = 10 * time.Second
+
+// Message is an email to be sent
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// SMTPConfig contains the settings of the SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	TLS      string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+
+	// skips the verification of the server certificate; only for testing
+	InsecureSkipVerify bool
+}
+
+// SMTPMailer sends email through an SMTP server
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer returns a mailer that uses the indicated SMTP server
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	if cfg.TLS == "" {
+		cfg.TLS = TLSNone
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send sends the message, with both text and HTML parts when available
+func (s *SMTPMailer) Send(ctx context.Context, m *Message) (err error) {
+
+	if len(m.To) == 0 {
+		return errors.New("message without recipients")
+	}
+
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %s", err)
+	}
+
+	body, err := buildMessage(from, m)
+	if err != nil {
+		return
+	}
+
+	c, err := s.dial(ctx)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		if err = c.StartTLS(s.tlsConfig()); err != nil {
+			return
+		}
+	}
+
+	if s.cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return
+		}
+	}
+
+	if err = c.Mail(from.Address); err != nil {
+		return
+	}
+	for _, to := range m.To {
+		if err = c.Rcpt(to); err != nil {
+			return
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return
+	}
+	if _, err = w.Write(body); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	return c.Quit()
+}
+
+// opens the connection with the server, honoring the context deadline
+func (s *SMTPMailer) dial(ctx context.Context) (c *smtp.Client, err error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+
+	deadline := time.Now().Add(s.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(deadline)
+
+	if s.cfg.TLS == TLSImplicit {
+		conn = tls.Client(conn, s.tlsConfig())
+	}
+
+	if c, err = smtp.NewClient(conn, s.cfg.Host); err != nil {
+		conn.Close()
+	}
+	return
+}
+
+func (s *SMTPMailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: s.cfg.Host, InsecureSkipVerify: s.cfg.InsecureSkipVerify}
+}
+
+// builds the MIME message
+func buildMessage(from *mail.Address, m *Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("From: " + from.String() + "\r\n")
+	buf.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", m.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.HTML == "" {
+		writePart(&buf, "text/plain", m.Text)
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	buf.WriteString("--" + boundary + "\r\n")
+	writePart(&buf, "text/plain", m.Text)
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	writePart(&buf, "text/html", m.HTML)
+	buf.WriteString("\r\n--" + boundary + "--\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// writes the headers and the quoted-printable content of a single part
+func writePart(buf *bytes.Buffer, contentType, content string) {
+	buf.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+	w.Write([]byte(content))
+	w.Close()
+}
+
+func newBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,138 @@
+package mail_test
+
+import (
+	"context"
+	"cronspy/backend/pkg/util/mail"
+	"cronspy/backend/pkg/util/mail/mailtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getMailer(s *mailtest.Server, username string) *mail.SMTPMailer {
+	return mail.NewSMTPMailer(mail.SMTPConfig{
+		Host:     s.Host(),
+		Port:     s.Port(),
+		Username: username,
+		Password: "secret",
+		From:     "CronSpy <alerts@cronspy.com>",
+	})
+}
+
+func TestSend(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	err = getMailer(s, "alerts").Send(context.Background(), &mail.Message{
+		To:      []string{"user@cronspy.com"},
+		Subject: "Job 'Backup' is down",
+		Text:    "The job is down",
+		HTML:    "<p>The job is down</p>",
+	})
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, s.Messages(), 1) {
+		m := s.Messages()[0]
+		assert.Equal(t, "alerts@cronspy.com", m.From)
+		assert.Equal(t, []string{"user@cronspy.com"}, m.To)
+		assert.Equal(t, "alerts", m.Username)
+		assert.Contains(t, m.Data, "Subject: Job 'Backup' is down")
+		assert.Contains(t, m.Data, "multipart/alternative")
+		assert.Contains(t, m.Data, "The job is down")
+		assert.Contains(t, m.Data, "<p>The job is down</p>")
+	}
+}
+
+func TestSendTextOnly(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	err = getMailer(s, "").Send(context.Background(), &mail.Message{
+		To:      []string{"user@cronspy.com"},
+		Subject: "Hello",
+		Text:    "Just text",
+	})
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, s.Messages(), 1) {
+		m := s.Messages()[0]
+		assert.Equal(t, "", m.Username)
+		assert.Contains(t, m.Data, "Content-Type: text/plain")
+		assert.NotContains(t, m.Data, "multipart")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	s, err := mailtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	// no recipients
+	err = getMailer(s, "").Send(context.Background(), &mail.Message{Subject: "Hello"})
+	assert.Error(t, err)
+
+	// invalid from
+	m := mail.NewSMTPMailer(mail.SMTPConfig{Host: s.Host(), Port: s.Port(), From: "not an address"})
+	err = m.Send(context.Background(), &mail.Message{To: []string{"user@cronspy.com"}})
+	assert.Error(t, err)
+
+	// server not available
+	s.Close()
+	m = mail.NewSMTPMailer(mail.SMTPConfig{Host: s.Host(), Port: s.Port(), From: "alerts@cronspy.com", Timeout: time.Second})
+	err = m.Send(context.Background(), &mail.Message{To: []string{"user@cronspy.com"}})
+	assert.Error(t, err)
+
+	assert.Len(t, s.Messages(), 0)
+}
+
+func TestRender(t *testing.T) {
+	data := mail.JobAlertData{
+		JobName:     "Backup",
+		Description: "The job didn't check in",
+		Expected:    "2020-01-01 10:00:00 UTC",
+		LastCheckIn: "never",
+		JobURL:      "https://app.cronspy.com/jobs/1",
+	}
+
+	for _, name := range []string{mail.TemplateJobDown, mail.TemplateJobFailed, mail.TemplateJobRecovered, mail.TemplateTest} {
+		t.Run(name, func(t *testing.T) {
+			m, err := mail.Render(name, data, "user@cronspy.com")
+			if assert.NoError(t, err) {
+				assert.Contains(t, m.Subject, "Backup")
+				assert.Contains(t, m.Text, "The job didn't check in")
+				assert.Contains(t, m.HTML, "https://app.cronspy.com/jobs/1")
+				assert.Equal(t, []string{"user@cronspy.com"}, m.To)
+			}
+		})
+	}
+
+	m, err := mail.Render(mail.TemplatePasswordReset, mail.PasswordResetData{Name: "<Test>", URL: "https://app.cronspy.com/reset?token=abc", ExpirationHours: 24})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[CronSpy] Reset your password", m.Subject)
+		assert.Contains(t, m.Text, "Hi <Test>,")
+		assert.Contains(t, m.HTML, "Hi &lt;Test&gt;,")
+		assert.Contains(t, m.HTML, "https://app.cronspy.com/reset?token=abc")
+	}
+
+	data.Labels = "env=prod, team=ops"
+	data.ExitCode = "1"
+	data.Output = "error: <disk> full"
+	m, err = mail.Render(mail.TemplateJobFailed, data, "user@cronspy.com")
+	if assert.NoError(t, err) {
+		assert.Contains(t, m.Text, "Labels:        env=prod, team=ops\nExit code:     1\n\nOutput:\nerror: <disk> full\n")
+		assert.Contains(t, m.HTML, "env=prod, team=ops")
+		assert.Contains(t, m.HTML, "error: &lt;disk&gt; full</pre>")
+	}
+
+	_, err = mail.Render("unknown", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+// Package mailtest provides a fake SMTP server to test code that sends email.
+package mailtest
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is an email received by the server
+type Message struct {
+	From     string
+	To       []string
+	Data     string
+	Username string
+}
+
+// Server is a minimal SMTP server that keeps the received messages in memory;
+// it supports plain text connections and AUTH PLAIN only
+type Server struct {
+	// Addr is the address of the server, in the form `127.0.0.1:port`
+	Addr string
+
+	listener net.Listener
+	messages []Message
+	mux      sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a new server listening on a random local port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Addr: l.Addr().String(), listener: l}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host returns the host of the server
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port returns the port of the server
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handles a single SMTP session
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 mailtest ESMTP")
+
+	var msg Message
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		switch strings.ToUpper(cmd) {
+		case "HELO":
+			tp.PrintfLine("250 mailtest")
+		case "EHLO":
+			tp.PrintfLine("250-mailtest")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			msg.Username = parsePlainAuth(arg)
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.From = parseAddress(arg)
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, parseAddress(arg))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+
+			s.mux.Lock()
+			s.messages = append(s.messages, msg)
+			s.mux.Unlock()
+
+			msg = Message{Username: msg.Username}
+			tp.PrintfLine("250 ok")
+		case "RSET":
+			msg = Message{Username: msg.Username}
+			tp.PrintfLine("250 ok")
+		case "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// returns the address in `FROM:<address>` or `TO:<address>`
+func parseAddress(arg string) string {
+	start, end := strings.IndexByte(arg, '<'), strings.IndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// returns the username in `PLAIN <base64 credentials>`
+func parsePlainAuth(arg string) string {
+	fields := strings.Fields(arg)
+	if len(fields) != 2 {
+		return ""
+	}
+
+	b, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(string(b), "\x00")
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
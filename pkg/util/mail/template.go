@@ -0,0 +1,227 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// Template names
+const (
+	TemplateJobDown       = "job_down"
+	TemplateJobFailed     = "job_failed"
+	TemplateJobRecovered  = "job_recovered"
+	TemplatePasswordReset = "password_reset"
+	TemplateTest          = "test"
+)
+
+// JobAlertData is the data used by the job alert templates
+type JobAlertData struct {
+	JobName     string
+	Description string
+	Expected    string
+	LastCheckIn string
+	JobURL      string
+	Labels      string
+	ExitCode    string
+	Output      string
+}
+
+// PasswordResetData is the data used by the password reset template
+type PasswordResetData struct {
+	Name            string
+	URL             string
+	ExpirationHours int
+}
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("text").Parse(textSource))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("html").Parse(htmlSource))
+)
+
+// Render builds a message using the template `name`; every template defines
+// its subject, text and HTML versions
+func Render(name string, data interface{}, to ...string) (m *Message, err error) {
+	m = &Message{To: to}
+
+	if m.Subject, err = renderText(name+".subject", data); err != nil {
+		return nil, err
+	}
+	if m.Text, err = renderText(name+".text", data); err != nil {
+		return nil, err
+	}
+
+	if htmlTemplates.Lookup(name+".html") == nil {
+		return nil, fmt.Errorf("template '%s' not found", name+".html")
+	}
+	var buf bytes.Buffer
+	if err = htmlTemplates.ExecuteTemplate(&buf, name+".html", data); err != nil {
+		return nil, err
+	}
+	m.HTML = buf.String()
+
+	return
+}
+
+func renderText(name string, data interface{}) (string, error) {
+	if textTemplates.Lookup(name) == nil {
+		return "", fmt.Errorf("template '%s' not found", name)
+	}
+
+	var buf bytes.Buffer
+	err := textTemplates.ExecuteTemplate(&buf, name, data)
+	return buf.String(), err
+}
+
+// plain text templates, including the subjects
+const textSource = `
+{{- define "job_down.subject"}}[CronSpy] Job '{{.JobName}}' is down{{end}}
+{{- define "job_down.text"}}{{.Description}}
+
+Job:           {{.JobName}}
+Expected at:   {{.Expected}}
+Last check-in: {{.LastCheckIn}}
+{{- if .Labels}}
+Labels:        {{.Labels}}
+{{- end}}
+{{- if .ExitCode}}
+Exit code:     {{.ExitCode}}
+{{- end}}
+{{- if .Output}}
+
+Output:
+{{.Output}}
+{{- end}}
+{{if .JobURL}}
+{{.JobURL}}
+{{end}}{{end}}
+
+{{- define "job_failed.subject"}}[CronSpy] Job '{{.JobName}}' failed{{end}}
+{{- define "job_failed.text"}}{{.Description}}
+
+Job:           {{.JobName}}
+Last check-in: {{.LastCheckIn}}
+{{- if .Labels}}
+Labels:        {{.Labels}}
+{{- end}}
+{{- if .ExitCode}}
+Exit code:     {{.ExitCode}}
+{{- end}}
+{{- if .Output}}
+
+Output:
+{{.Output}}
+{{- end}}
+{{if .JobURL}}
+{{.JobURL}}
+{{end}}{{end}}
+
+{{- define "job_recovered.subject"}}[CronSpy] Job '{{.JobName}}' is back up{{end}}
+{{- define "job_recovered.text"}}{{.Description}}
+
+Job:           {{.JobName}}
+Last check-in: {{.LastCheckIn}}
+{{- if .Labels}}
+Labels:        {{.Labels}}
+{{- end}}
+{{- if .ExitCode}}
+Exit code:     {{.ExitCode}}
+{{- end}}
+{{- if .Output}}
+
+Output:
+{{.Output}}
+{{- end}}
+{{if .JobURL}}
+{{.JobURL}}
+{{end}}{{end}}
+
+{{- define "test.subject"}}[CronSpy] Test notification for job '{{.JobName}}'{{end}}
+{{- define "test.text"}}{{.Description}}
+
+Job:           {{.JobName}}
+Last check-in: {{.LastCheckIn}}
+{{- if .Labels}}
+Labels:        {{.Labels}}
+{{- end}}
+{{- if .ExitCode}}
+Exit code:     {{.ExitCode}}
+{{- end}}
+{{- if .Output}}
+
+Output:
+{{.Output}}
+{{- end}}
+{{if .JobURL}}
+{{.JobURL}}
+{{end}}{{end}}
+
+{{- define "password_reset.subject"}}[CronSpy] Reset your password{{end}}
+{{- define "password_reset.text"}}Hi {{.Name}},
+
+We received a request to reset your CronSpy password. Open the following
+link to choose a new one:
+
+{{.URL}}
+
+The link expires in {{.ExpirationHours}} hours. If you didn't request it,
+just ignore this email.
+{{end}}
+`
+
+// HTML templates
+const htmlSource = `
+{{- define "header"}}<!DOCTYPE html>
+<html>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #333333;">
+{{end}}
+
+{{- define "footer"}}<p style="color: #999999; font-size: 12px;">CronSpy</p>
+</body>
+</html>
+{{end}}
+
+{{- define "job_details"}}<table cellpadding="4">
+<tr><td><strong>Job</strong></td><td>{{.JobName}}</td></tr>
+{{- if .Expected}}
+<tr><td><strong>Expected at</strong></td><td>{{.Expected}}</td></tr>
+{{- end}}
+<tr><td><strong>Last check-in</strong></td><td>{{.LastCheckIn}}</td></tr>
+{{- if .Labels}}
+<tr><td><strong>Labels</strong></td><td>{{.Labels}}</td></tr>
+{{- end}}
+{{- if .ExitCode}}
+<tr><td><strong>Exit code</strong></td><td>{{.ExitCode}}</td></tr>
+{{- end}}
+</table>
+{{- if .Output}}
+<pre style="background: #f4f4f4; padding: 8px; white-space: pre-wrap;">{{.Output}}</pre>
+{{- end}}
+{{- if .JobURL}}
+<p><a href="{{.JobURL}}">View job</a></p>
+{{- end}}
+{{end}}
+
+{{- define "job_down.html"}}{{template "header"}}<h2 style="color: #c0392b;">Job '{{.JobName}}' is down</h2>
+<p>{{.Description}}</p>
+{{template "job_details" .}}{{template "footer"}}{{end}}
+
+{{- define "job_failed.html"}}{{template "header"}}<h2 style="color: #c0392b;">Job '{{.JobName}}' failed</h2>
+<p>{{.Description}}</p>
+{{template "job_details" .}}{{template "footer"}}{{end}}
+
+{{- define "job_recovered.html"}}{{template "header"}}<h2 style="color: #27ae60;">Job '{{.JobName}}' is back up</h2>
+<p>{{.Description}}</p>
+{{template "job_details" .}}{{template "footer"}}{{end}}
+
+{{- define "test.html"}}{{template "header"}}<h2 style="color: #2980b9;">Test notification for job '{{.JobName}}'</h2>
+<p>{{.Description}}</p>
+{{template "job_details" .}}{{template "footer"}}{{end}}
+
+{{- define "password_reset.html"}}{{template "header"}}<p>Hi {{.Name}},</p>
+<p>We received a request to reset your CronSpy password. Click the following link to choose a new one:</p>
+<p><a href="{{.URL}}">Reset password</a></p>
+<p>The link expires in {{.ExpirationHours}} hours. If you didn't request it, just ignore this email.</p>
+{{template "footer"}}{{end}}
+`
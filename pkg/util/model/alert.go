@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Alert event types
+const (
+	AlertEventJobDown      = "JOB_DOWN"
+	AlertEventJobFailed    = "JOB_FAILED"
+	AlertEventJobRecovered = "JOB_RECOVERED"
+
+	// AlertEventTest is sent on demand to check that a channel works
+	AlertEventTest = "TEST"
+)
+
+// AlertEvent is emitted when a job changes its status and
+// one of its alerts needs to be notified
+type AlertEvent struct {
+	Type         string     `json:"type"`
+	DateCreated  time.Time  `json:"date_created"`
+	Job          Job        `json:"job"`
+	Alert        JobAlert   `json:"alert"`
+	DateExpected *time.Time `json:"date_expected"`
+
+	// ping that caused the event, if any
+	Ping *JobPing `json:"ping,omitempty"`
+}
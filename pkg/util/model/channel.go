@@ -7,6 +7,12 @@ const (
 	ChannelTypeSlack   = "SLACK"
 )
 
+// Web hook payload types
+const (
+	WebHookPayloadJSON = "JSON"
+	WebHookPayloadForm = "FORM"
+)
+
 // Channel represents a notification channel
 type Channel struct {
 	ID            int                    `gorm:"column:id_channel;primary_key" json:"id"`
@@ -37,6 +43,9 @@ func (c *Channel) GetChannelWebHook() (cwh ChannelWebHook) {
 		if v, ok := c.Configuration["basic_auth_password"].(string); ok {
 			cwh.BasicAuthPassword = &v
 		}
+		if v, ok := c.Configuration["signing_secret"].(string); ok {
+			cwh.SigningSecret = &v
+		}
 	}
 	return
 }
@@ -48,10 +57,10 @@ func (c *Channel) SetChannelWebHook(cwh ChannelWebHook) {
 		c.Configuration = make(map[string]interface{})
 	}
 
-	if cwh.BaseURL == "" {
+	if cwh.BaseURL != "" {
 		c.Configuration["base_url"] = cwh.BaseURL
 	}
-	if cwh.PayloadType == "" {
+	if cwh.PayloadType != "" {
 		c.Configuration["payload_type"] = cwh.PayloadType
 	}
 	if cwh.BasicAuthUsername != nil {
@@ -60,6 +69,9 @@ func (c *Channel) SetChannelWebHook(cwh ChannelWebHook) {
 	if cwh.BasicAuthPassword != nil {
 		c.Configuration["basic_auth_password"] = *cwh.BasicAuthPassword
 	}
+	if cwh.SigningSecret != nil {
+		c.Configuration["signing_secret"] = *cwh.SigningSecret
+	}
 }
 
 // GetChannelSlack returns Configuration as `ChannelSlack`
@@ -134,6 +146,7 @@ type ChannelWebHook struct {
 	PayloadType       string  `gorm:"NOT NULL" json:"payload_type"`
 	BasicAuthUsername *string `json:"basic_auth_username"`
 	BasicAuthPassword *string `json:"basic_auth_password"`
+	SigningSecret     *string `json:"signing_secret"`
 }
 
 // TableName returns the table name for the model
@@ -19,4 +19,10 @@ var (
 
 	// ErrUnauthorized (401) is returned when user is not authorized
 	ErrUnauthorized = echo.ErrUnauthorized
+
+	// ErrCronExpressionNotSet is returned when a job has no cron expression configured
+	ErrCronExpressionNotSet = errors.New("cron expression not set")
+
+	// ErrNoScheduledRuns is returned when a cron expression never matches a date (e.g. `0 0 30 2 *`)
+	ErrNoScheduledRuns = errors.New("cron expression has no scheduled runs")
 )
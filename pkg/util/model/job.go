@@ -1,6 +1,12 @@
 package model
 
 import (
+	"cronspy/backend/pkg/util/cron"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,19 +26,97 @@ const (
 	JobTypeAuto = "AUTO"
 )
 
+// Fields used to sort jobs
+const (
+	JobSortName        = "name"
+	JobSortDateCreated = "date_created"
+	JobSortStatus      = "status"
+	JobSortLastPing    = "date_last_ping"
+)
+
+// JobFilter contains the criteria used to list jobs; empty fields are ignored
+type JobFilter struct {
+	Status   string
+	JobType  string
+	Active   *bool
+	Search   string
+	Sort     string
+	SortDesc bool
+
+	// jobs must have every label
+	Labels JobLabels
+}
+
+// JobCursor is the position of a job in a sorted list, used to request the
+// jobs after it; `Text` or `Time` hold the value of the sort field, and both
+// are nil when the value is NULL
+type JobCursor struct {
+	Sort string     `json:"sort"`
+	Desc bool       `json:"desc,omitempty"`
+	Text *string    `json:"text,omitempty"`
+	Time *time.Time `json:"time,omitempty"`
+	ID   string     `json:"id"`
+}
+
+// NewJobCursor returns the position of a job in a list sorted as indicated by the filter
+func NewJobCursor(j *Job, filter JobFilter) *JobCursor {
+	c := &JobCursor{Sort: filter.Sort, Desc: filter.SortDesc, ID: j.ID}
+
+	switch filter.Sort {
+	case JobSortName:
+		c.Text = &j.Name
+	case JobSortStatus:
+		c.Text = &j.Status
+	case JobSortLastPing:
+		c.Time = j.DateLastPing
+	default:
+		c.Time = &j.DateCreated
+	}
+
+	return c
+}
+
+// Value returns the value of the sort field, or nil if it's NULL
+func (c *JobCursor) Value() interface{} {
+	switch {
+	case c.Text != nil:
+		return *c.Text
+	case c.Time != nil:
+		return *c.Time
+	default:
+		return nil
+	}
+}
+
 // Job is a job configured for a user, to be monitored by the system
 type Job struct {
-	ID                      string    `gorm:"column:id_job;primary_key" json:"id"`
-	IDUser                  int       `gorm:"NOT NULL" json:"id_user"`
-	DateCreated             time.Time `gorm:"NOT NULL" json:"date_created"`
-	DateUpdated             time.Time `gorm:"NOT NULL" json:"date_updated"`
-	Name                    string    `gorm:"NOT NULL" json:"name"`
-	JobType                 string    `gorm:"NOT NULL" json:"job_type"`
-	Active                  bool      `gorm:"NOT NULL" json:"active"`
-	Status                  string    `gorm:"NOT NULL" json:"status"`
-	CronExpression          *string   `json:"cron_expression"`
-	CronExpressionTimezone  *string   `json:"cron_expression_timezone"`
-	DetectedIntervalMinutes *int      `json:"-"`
+	ID                      string     `gorm:"column:id_job;primary_key" json:"id"`
+	IDUser                  int        `gorm:"NOT NULL;unique_index:idx_jobs_user_slug" json:"id_user"`
+	DateCreated             time.Time  `gorm:"NOT NULL" json:"date_created"`
+	DateUpdated             time.Time  `gorm:"NOT NULL" json:"date_updated"`
+	Name                    string     `gorm:"NOT NULL" json:"name"`
+	Slug                    *string    `gorm:"unique_index:idx_jobs_user_slug" json:"slug"`
+	PingKey                 string     `gorm:"type:varchar(32);unique_index;NOT NULL" json:"ping_key"`
+	JobType                 string     `gorm:"NOT NULL" json:"job_type"`
+	Active                  bool       `gorm:"NOT NULL" json:"active"`
+	Status                  string     `gorm:"NOT NULL" json:"status"`
+	CronExpression          *string    `json:"cron_expression"`
+	CronExpressionTimezone  *string    `json:"cron_expression_timezone"`
+	DetectedIntervalMinutes *int       `json:"-"`
+	DateLastPing            *time.Time `json:"date_last_ping"`
+	LastRunDurationMs       *int64     `json:"last_run_duration_ms"`
+	LastRunOutcome          *string    `json:"last_run_outcome"`
+	Labels                  JobLabels  `gorm:"-" json:"labels,omitempty"`
+	Alerts                  []JobAlert `gorm:"-" json:"alerts,omitempty"`
+
+	// optional protections of the pings; pings from IPs out of `AllowedIPs`,
+	// or without a valid signature when `SignedPings` is set, are rejected.
+	// `SignedPings` is nil when it's not sent by the client. The secret is
+	// only returned when the job is created and when it's rotated.
+	AllowedIPs    IPAllowList `gorm:"type:text" json:"allowed_ips,omitempty"`
+	SignedPings   *bool       `gorm:"NOT NULL" json:"signed_pings"`
+	PingSecret    *string     `json:"-"`
+	RejectedPings int         `gorm:"NOT NULL" json:"rejected_pings"`
 }
 
 // TableName returns the table name for the model
@@ -40,12 +124,83 @@ func (Job) TableName() string {
 	return "cronspy.jobs"
 }
 
+// UsesSignedPings checks if the pings of the job must be signed
+func (j *Job) UsesSignedPings() bool {
+	return j.SignedPings != nil && *j.SignedPings
+}
+
 // GetNextRun returns the time at which the cron should run again,
 // based on the  configured con expression; the time is expressed
 // by the timezone configured in `CronExpressionTimezone`
 //
 // An error is returned if the cron expression is invalid or not set.
 func (j *Job) GetNextRun() (t time.Time, err error) {
+	return j.GetNextRunAfter(time.Now())
+}
+
+// GetNextRunAfter returns the first time after `after` at which the cron should run
+func (j *Job) GetNextRunAfter(after time.Time) (t time.Time, err error) {
+	s, loc, err := j.getSchedule()
+	if err != nil {
+		return
+	}
+
+	if t = s.Next(after.In(loc)); t.IsZero() {
+		err = ErrNoScheduledRuns
+	}
+	return
+}
+
+// GetNextRuns returns the next `count` times after `after` at which the cron should run
+func (j *Job) GetNextRuns(after time.Time, count int) (runs []time.Time, err error) {
+	for i := 0; i < count; i++ {
+		if after, err = j.GetNextRunAfter(after); err != nil {
+			return
+		}
+		runs = append(runs, after)
+	}
+	return
+}
+
+// GetPreviousRun returns the last time at which the cron should have run,
+// expressed by the timezone configured in `CronExpressionTimezone`; it's
+// useful to check if the job is late
+//
+// An error is returned if the cron expression is invalid or not set.
+func (j *Job) GetPreviousRun() (t time.Time, err error) {
+	return j.GetPreviousRunBefore(time.Now())
+}
+
+// GetPreviousRunBefore returns the last time before `before` at which the cron should have run
+func (j *Job) GetPreviousRunBefore(before time.Time) (t time.Time, err error) {
+	s, loc, err := j.getSchedule()
+	if err != nil {
+		return
+	}
+
+	if t = s.Prev(before.In(loc)); t.IsZero() {
+		err = ErrNoScheduledRuns
+	}
+	return
+}
+
+// parses the cron expression and loads the configured timezone;
+// UTC is used when no timezone is set
+func (j *Job) getSchedule() (s *cron.Schedule, loc *time.Location, err error) {
+	if j.CronExpression == nil || *j.CronExpression == "" {
+		err = ErrCronExpressionNotSet
+		return
+	}
+
+	if s, err = cron.Parse(*j.CronExpression); err != nil {
+		return
+	}
+
+	loc = time.UTC
+	if j.CronExpressionTimezone != nil && *j.CronExpressionTimezone != "" {
+		loc, err = time.LoadLocation(*j.CronExpressionTimezone)
+	}
+
 	return
 }
 
@@ -57,15 +212,119 @@ func (j *Job) BeforeCreate(scope *gorm.Scope) error {
 
 // JobAlert represent an alert definition, when something goes wrong
 type JobAlert struct {
-	ID                        int     `gorm:"column:id_alert;primary_key" json:"id"`
-	IDJob                     int     `gorm:"NOT NULL" json:"id_job"`
-	Target                    string  `gorm:"NOT NULL" json:"target"`
-	MinutesBeforeNotification int     `gorm:"NOT NULL" json:"minutes_before_notification"`
-	IDChannel                 int     `gorm:"NOT NULL" json:"id_channel"`
-	Channel                   Channel `gorm:"foreignkey:IDChannel" json:"-"`
+	ID                        int        `gorm:"column:id_alert;primary_key" json:"id"`
+	IDJob                     string     `gorm:"NOT NULL" json:"id_job"`
+	Target                    string     `gorm:"NOT NULL" json:"target"`
+	MinutesBeforeNotification int        `gorm:"NOT NULL" json:"minutes_before_notification"`
+	IDChannel                 int        `gorm:"NOT NULL" json:"id_channel"`
+	Channel                   Channel    `gorm:"foreignkey:IDChannel" json:"-"`
+	DateLastNotified          *time.Time `json:"-"`
 }
 
 // TableName returns the table name for the model
 func (JobAlert) TableName() string {
 	return "cronspy.job_alerts"
 }
+
+// JobLabels are free-form `key=value` labels used to group jobs
+type JobLabels map[string]string
+
+// Pairs returns the labels as `key=value` pairs, sorted by key
+func (l JobLabels) Pairs() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + l[k]
+	}
+	return pairs
+}
+
+// String returns the labels sorted by key, as `key=value` pairs separated by commas
+func (l JobLabels) String() string {
+	return strings.Join(l.Pairs(), ", ")
+}
+
+// JobLabel is a label of a job, as it's stored in the database
+type JobLabel struct {
+	IDJob string `gorm:"primary_key" json:"id_job"`
+	Key   string `gorm:"column:label_key;primary_key" json:"key"`
+	Value string `gorm:"column:label_value;NOT NULL" json:"value"`
+}
+
+// TableName returns the table name for the model
+func (JobLabel) TableName() string {
+	return "cronspy.job_labels"
+}
+
+// IPAllowList is a list of networks in CIDR notation, stored as
+// comma separated values
+type IPAllowList []string
+
+// Contains checks if `ip` belongs to any of the networks of the list
+func (l IPAllowList) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, s := range l {
+		if _, n, err := net.ParseCIDR(s); err == nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface
+func (l IPAllowList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	return strings.Join(l, ","), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (l *IPAllowList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("unsupported type for IPAllowList: %T", src)
+	}
+
+	*l = nil
+	if s != "" {
+		*l = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// NormalizeCIDR returns the network of an IP or a CIDR in canonical CIDR
+// notation; single IPs are networks with just that IP
+func NormalizeCIDR(s string) (string, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return "", err
+		}
+		return n.String(), nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
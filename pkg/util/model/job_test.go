@@ -0,0 +1,139 @@
+package model_test
+
+import (
+	"cronspy/backend/pkg/util/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestJobGetNextRunAfter(t *testing.T) {
+	j := model.Job{CronExpression: strPtr("0 9 * * *"), CronExpressionTimezone: strPtr("Europe/Madrid")}
+
+	next, err := j.GetNextRunAfter(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2020, 1, 2, 8, 0, 0, 0, time.UTC), next.UTC())
+		assert.Equal(t, "Europe/Madrid", next.Location().String())
+	}
+}
+
+func TestJobGetPreviousRunBefore(t *testing.T) {
+	j := model.Job{CronExpression: strPtr("*/15 * * * *")}
+
+	prev, err := j.GetPreviousRunBefore(time.Date(2020, 1, 1, 10, 20, 0, 0, time.UTC))
+
+	// assertions
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC), prev)
+	}
+}
+
+func TestJobGetNextRuns(t *testing.T) {
+	j := model.Job{CronExpression: strPtr("@hourly"), CronExpressionTimezone: strPtr("UTC")}
+
+	runs, err := j.GetNextRuns(time.Date(2020, 1, 1, 10, 20, 0, 0, time.UTC), 3)
+
+	// assertions
+	if assert.NoError(t, err) && assert.Len(t, runs, 3) {
+		assert.Equal(t, time.Date(2020, 1, 1, 13, 0, 0, 0, time.UTC), runs[2])
+	}
+}
+
+func TestJobGetNextRunErrors(t *testing.T) {
+	_, err := (&model.Job{}).GetNextRun()
+	assert.Equal(t, model.ErrCronExpressionNotSet, err)
+
+	_, err = (&model.Job{CronExpression: strPtr("0 0 30 2 *")}).GetNextRun()
+	assert.Equal(t, model.ErrNoScheduledRuns, err)
+
+	_, err = (&model.Job{CronExpression: strPtr("bad")}).GetNextRun()
+	assert.Error(t, err)
+
+	_, err = (&model.Job{CronExpression: strPtr("@daily"), CronExpressionTimezone: strPtr("Mars/Olympus")}).GetPreviousRun()
+	assert.Error(t, err)
+}
+
+func TestNewJobCursor(t *testing.T) {
+	created := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	j := &model.Job{ID: "job-1", Name: "Backup", Status: model.JobStatusOK, DateCreated: created}
+
+	c := model.NewJobCursor(j, model.JobFilter{Sort: model.JobSortName, SortDesc: true})
+	assert.Equal(t, "job-1", c.ID)
+	assert.True(t, c.Desc)
+	assert.Equal(t, "Backup", c.Value())
+
+	c = model.NewJobCursor(j, model.JobFilter{Sort: model.JobSortDateCreated})
+	assert.Equal(t, created, c.Value())
+
+	// jobs without pings have a NULL value
+	c = model.NewJobCursor(j, model.JobFilter{Sort: model.JobSortLastPing})
+	assert.Nil(t, c.Value())
+}
+
+func TestJobLabelsString(t *testing.T) {
+	labels := model.JobLabels{"team": "ops", "env": "prod", "app": "a=b"}
+	assert.Equal(t, []string{"app=a=b", "env=prod", "team=ops"}, labels.Pairs())
+	assert.Equal(t, "app=a=b, env=prod, team=ops", labels.String())
+	assert.Equal(t, "", model.JobLabels(nil).String())
+}
+
+func TestNormalizeCIDR(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "203.0.113.7", want: "203.0.113.7/32"},
+		{in: "203.0.113.7/24", want: "203.0.113.0/24"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+		{in: "203.0.113.7/33", err: true},
+		{in: "my-server", err: true},
+		{in: "", err: true},
+	}
+
+	for _, tt := range cases {
+		got, err := model.NormalizeCIDR(tt.in)
+		if tt.err {
+			assert.Error(t, err, tt.in)
+		} else if assert.NoError(t, err, tt.in) {
+			assert.Equal(t, tt.want, got)
+		}
+	}
+}
+
+func TestIPAllowList(t *testing.T) {
+	l := model.IPAllowList{"203.0.113.0/24", "2001:db8::/32"}
+
+	assert.True(t, l.Contains("203.0.113.7"))
+	assert.True(t, l.Contains("2001:db8::1"))
+	assert.False(t, l.Contains("198.51.100.1"))
+	assert.False(t, l.Contains("unknown"))
+	assert.False(t, model.IPAllowList(nil).Contains("203.0.113.7"))
+
+	// stored as comma separated values
+	v, err := l.Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "203.0.113.0/24,2001:db8::/32", v)
+	}
+
+	var scanned model.IPAllowList
+	if assert.NoError(t, scanned.Scan([]byte("203.0.113.0/24,2001:db8::/32"))) {
+		assert.Equal(t, l, scanned)
+	}
+	if assert.NoError(t, scanned.Scan(nil)) {
+		assert.Nil(t, scanned)
+	}
+
+	v, err = model.IPAllowList{}.Value()
+	if assert.NoError(t, err) {
+		assert.Nil(t, v)
+	}
+}
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// Notification delivery status
+const (
+	DeliveryStatusQueued = "QUEUED"
+	DeliveryStatusSent   = "SENT"
+	DeliveryStatusFailed = "FAILED"
+	DeliveryStatusDead   = "DEAD"
+)
+
+// NotificationDelivery is an alert event waiting to be delivered (or already
+// delivered) to a channel; it works as a queue shared by all the replicas
+//
+// Deliveries start as QUEUED; a failed attempt moves them to FAILED until the
+// next attempt, and to DEAD when there are no attempts left or the error
+// is permanent.
+type NotificationDelivery struct {
+	ID               int        `gorm:"column:id_delivery;primary_key" json:"id"`
+	IDUser           int        `gorm:"NOT NULL" json:"-"`
+	IDJob            string     `gorm:"NOT NULL" json:"id_job"`
+	IDAlert          int        `gorm:"NOT NULL" json:"id_alert"`
+	IDChannel        int        `gorm:"NOT NULL" json:"id_channel"`
+	EventType        string     `gorm:"NOT NULL" json:"event_type"`
+	Payload          string     `gorm:"type:text;NOT NULL" json:"-"`
+	Status           string     `gorm:"NOT NULL" json:"status"`
+	Attempts         int        `gorm:"NOT NULL" json:"attempts"`
+	DateCreated      time.Time  `gorm:"NOT NULL" json:"date_created"`
+	DateNextAttempt  *time.Time `json:"date_next_attempt"`
+	DateLastAttempt  *time.Time `json:"date_last_attempt"`
+	DateSent         *time.Time `json:"date_sent"`
+	LastStatusCode   *int       `json:"last_status_code"`
+	LastError        *string    `json:"last_error"`
+	ClaimToken       *string    `json:"-"`
+	DateClaimExpires *time.Time `json:"-"`
+
+	AttemptHistory []NotificationAttempt `gorm:"-" json:"attempt_history"`
+}
+
+// TableName returns the table name for the model
+func (NotificationDelivery) TableName() string {
+	return "cronspy.notification_deliveries"
+}
+
+// NotificationAttempt is a single attempt to deliver a notification
+type NotificationAttempt struct {
+	ID          int       `gorm:"column:id_attempt;primary_key" json:"id"`
+	IDDelivery  int       `gorm:"NOT NULL" json:"id_delivery"`
+	Attempt     int       `gorm:"NOT NULL" json:"attempt"`
+	Status      string    `gorm:"NOT NULL" json:"status"`
+	StatusCode  *int      `json:"status_code"`
+	Response    *string   `json:"response"`
+	Error       *string   `json:"error"`
+	DateCreated time.Time `gorm:"NOT NULL" json:"date_created"`
+	DurationMs  int64     `gorm:"NOT NULL" json:"duration_ms"`
+}
+
+// TableName returns the table name for the model
+func (NotificationAttempt) TableName() string {
+	return "cronspy.notification_attempts"
+}
@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// Ping kinds
+const (
+	PingKindStart   = "START"
+	PingKindSuccess = "SUCCESS"
+	PingKindFail    = "FAIL"
+)
+
+// Run outcomes
+const (
+	RunOutcomeRunning = "RUNNING"
+	RunOutcomeSuccess = "SUCCESS"
+	RunOutcomeFail    = "FAIL"
+)
+
+// JobPing represents a check-in received from a monitored job
+type JobPing struct {
+	ID          int       `gorm:"column:id_ping;primary_key;AUTO_INCREMENT" json:"id"`
+	IDJob       string    `gorm:"NOT NULL" json:"id_job"`
+	DateCreated time.Time `gorm:"NOT NULL" json:"date_created"`
+	Kind        string    `gorm:"NOT NULL" json:"kind"`
+	SourceIP    string    `gorm:"NOT NULL" json:"source_ip"`
+	UserAgent   string    `gorm:"NOT NULL" json:"user_agent"`
+	DurationMs  *int64    `json:"duration_ms"`
+
+	// output and exit code sent by the job along with the ping, if any
+	Body     *string `gorm:"type:text" json:"body"`
+	ExitCode *int    `json:"exit_code"`
+
+	// signature header, method and URI of the request, checked for jobs with
+	// signed pings
+	Signature string `gorm:"-" json:"-"`
+	Method    string `gorm:"-" json:"-"`
+	URI       string `gorm:"-" json:"-"`
+}
+
+// TableName returns the table name for the model
+func (JobPing) TableName() string {
+	return "cronspy.job_pings"
+}
+
+// PingFilter contains the filters of the ping history of a job
+type PingFilter struct {
+	// pings received from `From` (inclusive) to `To` (exclusive)
+	From *time.Time
+	To   *time.Time
+
+	// pings of any of these kinds; every kind if empty
+	Kinds []string
+}
+
+// PingCursor is the position of a ping in the history of a job, which
+// is sorted from newest to oldest
+type PingCursor struct {
+	DateCreated time.Time `json:"date"`
+	ID          int       `json:"id"`
+}
+
+// JobRun represents a single execution of a job, built by pairing
+// a start ping with the success or fail ping that follows it
+type JobRun struct {
+	ID           int        `gorm:"column:id_run;primary_key;AUTO_INCREMENT" json:"id"`
+	IDJob        string     `gorm:"NOT NULL" json:"id_job"`
+	DateStarted  *time.Time `json:"date_started"`
+	DateFinished *time.Time `json:"date_finished"`
+	DurationMs   *int64     `json:"duration_ms"`
+	Outcome      string     `gorm:"NOT NULL" json:"outcome"`
+}
+
+// TableName returns the table name for the model
+func (JobRun) TableName() string {
+	return "cronspy.job_runs"
+}
@@ -24,6 +24,9 @@ type User struct {
 	HashedPassword string    `gorm:"column:password;type:varchar(128);NOT NULL" json:"-"`
 	Password       string    `gorm:"-" json:"password,omitempty"`
 	AccountType    string    `gorm:"default(FREE);NOT NULL" json:"account_type"`
+
+	// used along with the job slugs in ping URLs
+	PingKey *string `gorm:"type:varchar(32);unique_index" json:"ping_key,omitempty"`
 }
 
 // TableName returns the table name for the model
@@ -0,0 +1,30 @@
+// Package pingkey generates the secret keys used in ping URLs. Keys only
+// contain lowercase letters and digits, so they can also be used in
+// email addresses and other case-insensitive places.
+package pingkey
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// number of random bytes of a key
+const keyLength = 16
+
+// valid keys have 32 hex characters
+var keyRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Generate returns a new random key
+func Generate() (string, error) {
+	b := make([]byte, keyLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Valid checks if `key` has the format of a key generated by `Generate`
+func Valid(key string) bool {
+	return keyRegexp.MatchString(key)
+}
@@ -0,0 +1,27 @@
+package pingkey_test
+
+import (
+	"cronspy/backend/pkg/util/pingkey"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	a, errA := pingkey.Generate()
+	b, errB := pingkey.Generate()
+
+	if assert.NoError(t, errA) && assert.NoError(t, errB) {
+		assert.Len(t, a, 32)
+		assert.NotEqual(t, a, b)
+		assert.True(t, pingkey.Valid(a))
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.False(t, pingkey.Valid(""))
+	assert.False(t, pingkey.Valid("0b5c4f0e-6a43-4c8e-9a43-6f1d7e1c2a01"))
+	assert.False(t, pingkey.Valid(strings.Repeat("A", 32)))
+	assert.True(t, pingkey.Valid(strings.Repeat("a0", 16)))
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RealIP returns a middleware that resolves the IP of the client, so
+// `echo.Context.RealIP` can be trusted; the `X-Forwarded-For` and
+// `X-Real-IP` headers are only taken into account when the request comes
+// from one of the trusted proxies, given as IPs or networks in CIDR notation.
+//
+// The middleware should be registered with `Echo.Pre`, so the resolved IP
+// is also used by the request logger.
+func RealIP(trustedProxies []string) (echo.MiddlewareFunc, error) {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, s := range trustedProxies {
+		n, err := parseNetwork(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %s", s, err)
+		}
+		trusted = append(trusted, n)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ip := clientIP(req, trusted)
+
+			// echo's RealIP prefers these headers over the remote address
+			req.Header.Del(echo.HeaderXForwardedFor)
+			req.Header.Set(echo.HeaderXRealIP, ip)
+
+			return next(c)
+		}
+	}, nil
+}
+
+// returns the IP of the client; the forwarded addresses are read from right
+// to left, and the first one that isn't a trusted proxy is the client
+func clientIP(req *http.Request, trusted []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, h := range req.Header[echo.HeaderXForwardedFor] {
+		for _, s := range strings.Split(h, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(s))
+		}
+	}
+	if len(forwarded) == 0 {
+		if realIP := strings.TrimSpace(req.Header.Get(echo.HeaderXRealIP)); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return ip
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		// an invalid address can't be trusted to be a proxy
+		if net.ParseIP(forwarded[i]) == nil {
+			return ip
+		}
+		ip = forwarded[i]
+		if !isTrusted(ip, trusted) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parses an IP or a network in CIDR notation
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
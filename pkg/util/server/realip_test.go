@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"cronspy/backend/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "Direct", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "Untrusted proxy", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "Untrusted real IP", remoteAddr: "203.0.113.7:5000", realIP: "198.51.100.1", want: "203.0.113.7"},
+		{name: "Trusted proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "Trusted real IP", remoteAddr: "10.0.0.2:5000", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "Spoofed chain", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "Proxy chain", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, 10.0.0.3", "192.168.1.1"}, want: "198.51.100.1"},
+		{name: "All trusted", remoteAddr: "10.0.0.2:5000", forwarded: []string{"10.0.0.4, 10.0.0.3"}, want: "10.0.0.4"},
+		{name: "Invalid forwarded", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, unknown"}, want: "10.0.0.2"},
+		{name: "IPv6", remoteAddr: "[2001:db8::1]:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+
+	mw, err := server.RealIP([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, f := range tt.forwarded {
+				req.Header.Add(echo.HeaderXForwardedFor, f)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(echo.HeaderXRealIP, tt.realIP)
+			}
+
+			e := echo.New()
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			var got string
+			err := mw(func(c echo.Context) error {
+				got = c.RealIP()
+				return nil
+			})(c)
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRealIPInvalidProxy(t *testing.T) {
+	_, err := server.RealIP([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = server.RealIP([]string{"proxy.local"})
+	assert.Error(t, err)
+}
@@ -20,6 +20,14 @@ type Config struct {
 	ReadTimeoutSeconds  int
 	WriteTimeoutSeconds int
 	Debug               bool
+	Workers             []Worker
+}
+
+// Worker is a background process that runs along with the server;
+// it's started before the server and stopped on graceful shutdown
+type Worker interface {
+	Start()
+	Stop(ctx context.Context) error
 }
 
 // New instantates new Echo server
@@ -57,6 +65,11 @@ func Start(e *echo.Echo, cfg *Config, log *log.Log) {
 	e.HideBanner = true
 	e.HidePort = true
 
+	// start background workers
+	for _, w := range cfg.Workers {
+		w.Start()
+	}
+
 	// start server
 	log.Info("starting "+cfg.ServiceName, nil)
 	go func() {
@@ -68,7 +81,7 @@ func Start(e *echo.Echo, cfg *Config, log *log.Log) {
 
 	// wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 10 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
@@ -79,4 +92,11 @@ func Start(e *echo.Echo, cfg *Config, log *log.Log) {
 	} else {
 		log.Info(cfg.ServiceName+" stoped!", nil)
 	}
+
+	// stop background workers
+	for _, w := range cfg.Workers {
+		if err := w.Stop(ctx); err != nil {
+			log.Error("error stopping worker", err, nil)
+		}
+	}
 }
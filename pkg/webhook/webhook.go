@@ -0,0 +1,154 @@
+// Package webhook signs the web hook requests sent by CronSpy and lets
+// receivers verify them.
+//
+// Every request carries the `X-CronSpy-Signature` header, in the form
+//
+//	X-CronSpy-Signature: t=1577872800,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd
+//
+// where `t` is the unix time when the request was signed and `v1` is the
+// hex encoded HMAC-SHA256 of `<t>.<body>` using the signing secret of the
+// channel. The header can contain more than one `v1` signature.
+//
+// Receivers should reject requests with old timestamps to prevent replay
+// attacks; `Verify` does it using the indicated tolerance:
+//
+//	body, err := webhook.VerifyRequest(r, secret, webhook.DefaultTolerance)
+//	if err != nil {
+//		w.WriteHeader(http.StatusUnauthorized)
+//		return
+//	}
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header that contains the signature
+	SignatureHeader = "X-CronSpy-Signature"
+
+	// SecretPrefix is the prefix of the generated secrets
+	SecretPrefix = "whsec_"
+
+	// DefaultTolerance is the recommended max age of a signature
+	DefaultTolerance = 5 * time.Minute
+
+	// signature scheme
+	schemeV1 = "v1"
+
+	// number of random bytes of the generated secrets
+	secretLength = 32
+)
+
+// Errors returned by `Verify`
+var (
+	ErrInvalidHeader      = errors.New("webhook: invalid signature header")
+	ErrNoValidSignature   = errors.New("webhook: no valid signature found")
+	ErrTimestampTolerance = errors.New("webhook: timestamp outside the tolerance zone")
+)
+
+// GenerateSecret returns a new random signing secret
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SecretPrefix + hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of the signature header for `body` signed at `t`
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := t.Unix()
+	return fmt.Sprintf("t=%d,%s=%s", ts, schemeV1, computeSignature(secret, ts, body))
+}
+
+// Verify checks the signature header of a request; signatures older (or newer)
+// than `tolerance` are rejected, unless `tolerance` is zero
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	return verify(secret, header, body, tolerance, time.Now())
+}
+
+// VerifyRequest reads the body of the request and verifies its signature; the
+// body is returned and also left in the request so it can be read again
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) (body []byte, err error) {
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err = Verify(secret, r.Header.Get(SignatureHeader), body, tolerance); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrTimestampTolerance
+		}
+	}
+
+	expected := []byte(computeSignature(secret, ts, body))
+	for _, s := range signatures {
+		if hmac.Equal(expected, []byte(s)) {
+			return nil
+		}
+	}
+
+	return ErrNoValidSignature
+}
+
+// returns the timestamp and the `v1` signatures of the header
+func parseHeader(header string) (ts int64, signatures []string, err error) {
+	if header == "" {
+		return 0, nil, ErrInvalidHeader
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return 0, nil, ErrInvalidHeader
+		}
+
+		switch kv[0] {
+		case "t":
+			if ts, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return 0, nil, ErrInvalidHeader
+			}
+		case schemeV1:
+			signatures = append(signatures, kv[1])
+		}
+	}
+
+	if ts == 0 || len(signatures) == 0 {
+		return 0, nil, ErrInvalidHeader
+	}
+	return
+}
+
+func computeSignature(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "whsec_test"
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret()
+	if assert.NoError(t, err) {
+		s2, _ := GenerateSecret()
+		assert.True(t, strings.HasPrefix(s1, SecretPrefix))
+		assert.Len(t, s1, len(SecretPrefix)+2*secretLength)
+		assert.NotEqual(t, s1, s2)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"JOB_DOWN"}`)
+	header := Sign(testSecret, now, body)
+
+	cases := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{name: "Valid", secret: testSecret, header: header, body: body, now: now},
+		{name: "Within tolerance", secret: testSecret, header: header, body: body, now: now.Add(4 * time.Minute)},
+		{name: "Multiple signatures", secret: testSecret, header: header + ",v1=abcd", body: body, now: now},
+		{name: "Old signature", secret: testSecret, header: header, body: body, now: now.Add(10 * time.Minute), want: ErrTimestampTolerance},
+		{name: "Signature from the future", secret: testSecret, header: header, body: body, now: now.Add(-10 * time.Minute), want: ErrTimestampTolerance},
+		{name: "Wrong secret", secret: "whsec_other", header: header, body: body, now: now, want: ErrNoValidSignature},
+		{name: "Modified body", secret: testSecret, header: header, body: []byte(`{"event":"JOB_RECOVERED"}`), now: now, want: ErrNoValidSignature},
+		{name: "Modified timestamp", secret: testSecret, header: strings.Replace(header, "t=1577872800", "t=1577872801", 1), body: body, now: now, want: ErrNoValidSignature},
+		{name: "Empty header", secret: testSecret, header: "", body: body, now: now, want: ErrInvalidHeader},
+		{name: "Missing signature", secret: testSecret, header: "t=1577872800", body: body, now: now, want: ErrInvalidHeader},
+		{name: "Invalid timestamp", secret: testSecret, header: "t=abc,v1=abcd", body: body, now: now, want: ErrInvalidHeader},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, verify(tt.secret, tt.header, tt.body, DefaultTolerance, tt.now))
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"event":"JOB_DOWN"}`
+
+	r := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	r.Header.Set(SignatureHeader, Sign(testSecret, time.Now(), []byte(body)))
+
+	b, err := VerifyRequest(r, testSecret, DefaultTolerance)
+	if assert.NoError(t, err) {
+		assert.Equal(t, body, string(b))
+
+		// the body can be read again
+		again, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, body, string(again))
+	}
+
+	r = httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	_, err = VerifyRequest(r, testSecret, DefaultTolerance)
+	assert.Equal(t, ErrInvalidHeader, err)
+}